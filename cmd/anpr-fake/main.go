@@ -0,0 +1,68 @@
+// anpr-fake - фейковый ANPR сервис для локальной проверки ticket-service без настоящего ANPR.
+//
+//	go run ./cmd/anpr-fake -addr :8082 -fixtures ./cmd/anpr-fake/fixtures -latency 200ms -error-rate 0.1
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"ticket-service/internal/anprfake"
+	"ticket-service/internal/logger"
+)
+
+func main() {
+	addr := flag.String("addr", ":8082", "адрес HTTP-сервера")
+	fixtures := flag.String("fixtures", "./cmd/anpr-fake/fixtures", "каталог или файл с событиями (JSON)")
+	token := flag.String("token", "", "ожидаемый X-Internal-Token (ANPR_INTERNAL_TOKEN), пусто - без проверки")
+	latency := flag.Duration("latency", 0, "задержка перед каждым ответом")
+	errorRate := flag.Float64("error-rate", 0, "доля запросов (0..1), завершающихся ошибкой")
+	failNext := flag.Int("fail-next", 0, "количество первых запросов, завершающихся ошибкой")
+	errorStatus := flag.Int("error-status", http.StatusServiceUnavailable, "HTTP статус имитируемой ошибки")
+	flag.Parse()
+
+	log := logger.New("development")
+
+	events, err := anprfake.LoadFixtures(*fixtures)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load fixtures: %v\n", err)
+		os.Exit(1)
+	}
+
+	fake := anprfake.New(events, *token)
+	fake.SetFault(anprfake.Fault{
+		Latency:     *latency,
+		ErrorRate:   *errorRate,
+		FailNext:    *failNext,
+		ErrorStatus: *errorStatus,
+	})
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: fake.Handler(),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Info().Str("addr", *addr).Int("events", len(events)).Msg("starting fake ANPR service")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("failed to start server")
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/service"
+)
+
+// runBackfillVolume пересчитывает объем всех автоматически созданных рейсов за период
+// и пишет CSV-отчет со старым и новым total_volume_m3.
+//
+// Рейсы загружаются из БД страницами, события ANPR - постранично, поэтому память не растет с длиной периода.
+//
+//	ticket-service backfill-volume -from 2025-01-01 -to 2025-02-01 -concurrency 4 -report diff.csv [-dry-run]
+func runBackfillVolume(ctx context.Context, tripService *service.TripService, args []string, log zerolog.Logger) error {
+	fs := flag.NewFlagSet("backfill-volume", flag.ContinueOnError)
+	fromRaw := fs.String("from", "", "начало периода по entry_at (YYYY-MM-DD или RFC3339), включительно")
+	toRaw := fs.String("to", "", "конец периода по entry_at (YYYY-MM-DD или RFC3339), не включительно")
+	concurrency := fs.Int("concurrency", 4, "количество одновременных запросов к ANPR")
+	batchSize := fs.Int("batch-size", 500, "количество рейсов, загружаемых из БД за один запрос")
+	reportPath := fs.String("report", "-", "файл CSV-отчета, - для stdout")
+	dryRun := fs.Bool("dry-run", false, "только рассчитать и записать отчет, не сохраняя объем")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := parseBackfillTime(*fromRaw)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	to, err := parseBackfillTime(*toRaw)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+	if !to.After(from) {
+		return errors.New("-to must be after -from")
+	}
+	if *concurrency < 1 {
+		return errors.New("-concurrency must be positive")
+	}
+	if *batchSize < 1 {
+		return errors.New("-batch-size must be positive")
+	}
+
+	var out io.Writer = os.Stdout
+	if *reportPath != "-" {
+		file, err := os.Create(*reportPath)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	log.Info().
+		Time("from", from).
+		Time("to", to).
+		Bool("dry_run", *dryRun).
+		Msg("starting volume backfill")
+
+	report := csv.NewWriter(out)
+	if err := report.Write([]string{
+		"trip_id", "assignment_id", "ticket_id", "entry_at",
+		"old_volume_m3", "new_volume_m3", "diff_m3", "result", "error",
+	}); err != nil {
+		return err
+	}
+
+	var (
+		mu                         sync.Mutex
+		wg                         sync.WaitGroup
+		changed, unchanged, failed int
+	)
+	sem := make(chan struct{}, *concurrency)
+
+	var listErr error
+	for trip, err := range tripService.TripsForVolumeBackfill(ctx, from, to, *batchSize) {
+		if err != nil {
+			listErr = fmt.Errorf("failed to list trips: %w", err)
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := tripService.RecalculateTripVolume(ctx, &trip, !*dryRun)
+			row := backfillRow(&trip, result, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed++
+			case result.Changed():
+				changed++
+			default:
+				unchanged++
+			}
+			if writeErr := report.Write(row); writeErr != nil {
+				log.Error().Err(writeErr).Msg("failed to write report row")
+			}
+		}()
+	}
+	wg.Wait()
+
+	report.Flush()
+	if err := report.Error(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if listErr != nil {
+		return listErr
+	}
+
+	log.Info().
+		Int("changed", changed).
+		Int("unchanged", unchanged).
+		Int("failed", failed).
+		Msg("volume backfill finished")
+
+	return ctx.Err()
+}
+
+func backfillRow(trip *model.Trip, result *service.VolumeRecalculation, err error) []string {
+	row := []string{
+		trip.ID.String(),
+		uuidString(trip.TicketAssignmentID),
+		uuidString(trip.TicketID),
+		trip.EntryAt.Format(time.RFC3339),
+		volumeString(trip.TotalVolumeM3),
+		"", "", "", "",
+	}
+	if err != nil {
+		row[7] = "error"
+		row[8] = err.Error()
+		return row
+	}
+
+	row[4] = volumeString(result.OldVolumeM3)
+	row[5] = volumeString(&result.NewVolumeM3)
+	old := 0.0
+	if result.OldVolumeM3 != nil {
+		old = *result.OldVolumeM3
+	}
+	row[6] = strconv.FormatFloat(result.NewVolumeM3-old, 'f', 3, 64)
+	row[7] = "unchanged"
+	if result.Changed() {
+		row[7] = "changed"
+	}
+	return row
+}
+
+func parseBackfillTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, errors.New("value is required")
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+func volumeString(volume *float64) string {
+	if volume == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*volume, 'f', 3, 64)
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
@@ -1,18 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// Часовые пояса расписаний не зависят от tzdata в образе
+	_ "time/tzdata"
 
+	"ticket-service/internal/anprfake"
 	"ticket-service/internal/auth"
 	"ticket-service/internal/client"
 	"ticket-service/internal/config"
 	"ticket-service/internal/db"
 	httphandler "ticket-service/internal/http"
 	"ticket-service/internal/http/middleware"
+	"ticket-service/internal/jobs"
 	"ticket-service/internal/logger"
+	"ticket-service/internal/model"
+	"ticket-service/internal/reconciler"
 	"ticket-service/internal/repository"
+	"ticket-service/internal/scheduler"
 	"ticket-service/internal/service"
+	"ticket-service/internal/violations"
 )
 
 func main() {
@@ -36,27 +50,145 @@ func main() {
 	appealRepo := repository.NewAppealRepository(database)
 	areaAccessRepo := repository.NewCleaningAreaAccessRepository(database)
 	polygonAccessRepo := repository.NewPolygonAccessRepository(database)
+	eventRepo := repository.NewEventRepository(database)
+	idempotencyRepo := repository.NewIdempotencyRepository(database)
+	contractSettingsRepo := repository.NewContractSettingsRepository(database)
+	violationRepo := repository.NewTripViolationRepository(database)
+	reconciliationRepo := repository.NewReconciliationRepository(database)
+	jobRepo := repository.NewJobRepository(database)
+	scheduleRepo := repository.NewScheduleRepository(database)
+	holidayRepo := repository.NewHolidayRepository(database)
 
 	// Clients
+	if cfg.ExternalServices.ANPRFakeFixtures != "" {
+		events, err := anprfake.LoadFixtures(cfg.ExternalServices.ANPRFakeFixtures)
+		if err != nil {
+			appLogger.Fatal().Err(err).Msg("failed to load fake ANPR fixtures")
+		}
+		fakeURL, stopFake, err := anprfake.New(events, cfg.ExternalServices.ANPRInternalToken).Start("127.0.0.1:0")
+		if err != nil {
+			appLogger.Fatal().Err(err).Msg("failed to start fake ANPR service")
+		}
+		defer stopFake()
+		cfg.ExternalServices.ANPRServiceURL = fakeURL
+		appLogger.Warn().Str("url", fakeURL).Int("events", len(events)).Msg("using in-process fake ANPR service")
+	}
 	anprClient := client.NewANPRClient(cfg)
+	jobQueue := jobs.NewQueue(jobRepo, cfg.Jobs.MaxAttempts)
 
 	// Services (нужно создать TripService до AssignmentService, т.к. AssignmentService зависит от TripService)
-	ticketService := service.NewTicketService(ticketRepo, tripRepo, assignmentRepo, appealRepo, areaAccessRepo, appLogger)
-	tripService := service.NewTripService(tripRepo, ticketRepo, assignmentRepo, ticketService, anprClient, polygonAccessRepo, appLogger)
+	ticketService := service.NewTicketService(ticketRepo, tripRepo, assignmentRepo, appealRepo, areaAccessRepo, violationRepo, appLogger)
+	violationThresholds := violations.Thresholds{
+		MaxTripVolumeM3:       cfg.Violations.MaxTripVolumeM3,
+		MinTripVolumeM3:       cfg.Violations.MinTripVolumeM3,
+		MaxExitVolumeM3:       cfg.Violations.MaxExitVolumeM3,
+		ContractVolumeLimitM3: cfg.Violations.ContractVolumeLimitM3,
+		RequirePolygonAccess:  cfg.Violations.RequirePolygonAccess,
+	}
+	tripService := service.NewTripService(tripRepo, ticketRepo, assignmentRepo, ticketService, anprClient, polygonAccessRepo, contractSettingsRepo, violationRepo, jobQueue, violations.NewDefaultEngine(), violationThresholds, model.VolumeStrategy(cfg.Volume.DefaultStrategy), appLogger)
 	assignmentService := service.NewAssignmentService(assignmentRepo, ticketRepo, ticketService, tripService)
-	appealService := service.NewAppealService(appealRepo, tripRepo, ticketRepo, assignmentRepo)
+	appealService := service.NewAppealService(appealRepo, tripRepo, ticketRepo, assignmentRepo, violationRepo, tripService)
+	eventService := service.NewEventService(eventRepo, tripRepo, assignmentRepo, tripService, ticketService, cfg.Pairing, appLogger)
+	scheduleService := service.NewScheduleService(scheduleRepo, holidayRepo, ticketRepo, areaAccessRepo, cfg.Scheduler, appLogger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Подкоманды обслуживания: ticket-service <command> [flags]
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill-volume":
+			if err := runBackfillVolume(ctx, tripService, os.Args[2:], appLogger); err != nil {
+				appLogger.Error().Err(err).Msg("volume backfill failed")
+				os.Exit(1)
+			}
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
 
 	tokenParser := auth.NewParser(cfg.Auth.AccessSecret)
 
-	handler := httphandler.NewHandler(ticketService, assignmentService, tripService, appealService, appLogger)
+	handler := httphandler.NewHandler(ticketService, assignmentService, tripService, appealService, eventService, scheduleService, appLogger)
 	authMiddleware := middleware.Auth(tokenParser)
-	router := httphandler.NewRouter(handler, authMiddleware, cfg.Environment)
+	serviceAuthMiddleware := middleware.ServiceAuth(cfg.Auth.ServiceToken)
+	idempotencyMiddleware := middleware.Idempotency(idempotencyRepo)
+	// Повторная доставка события LPR/volume сервисом без Idempotency-Key получает исходный ответ
+	internalIdempotencyMiddleware := middleware.Idempotency(idempotencyRepo, "external_id", "entry_lpr_event_id")
+	webhookMiddleware := middleware.WebhookSignature(cfg.ExternalServices.ANPRWebhookSecret, cfg.ExternalServices.ANPRWebhookTolerance)
+	healthChecks := []httphandler.HealthCheck{
+		{
+			Name:     "database",
+			Critical: true,
+			Check: func(ctx context.Context) (map[string]interface{}, error) {
+				return nil, db.HealthCheck(ctx, database)
+			},
+		},
+		{
+			// Недоступный ANPR задерживает только расчет объема, поэтому проверка некритичная
+			Name: "anpr",
+			Check: func(ctx context.Context) (map[string]interface{}, error) {
+				breaker := anprClient.Breaker()
+				details := map[string]interface{}{"circuit_breaker": breaker}
+				if breaker.State == client.BreakerOpen {
+					return details, client.ErrCircuitOpen
+				}
+				return details, nil
+			},
+		},
+	}
+	router := httphandler.NewRouter(handler, authMiddleware, serviceAuthMiddleware, idempotencyMiddleware, internalIdempotencyMiddleware, webhookMiddleware, cfg.Environment, healthChecks)
+
+	// Фоновое сопоставление рейсов, приехавших раньше назначения
+	if cfg.Reconciler.Enabled {
+		tripReconciler := reconciler.New(tripRepo, reconciliationRepo, tripService, cfg.Reconciler, appLogger)
+		go tripReconciler.Run(ctx)
+	}
+
+	// Создание тикетов по расписаниям
+	if cfg.Scheduler.Enabled {
+		ticketScheduler := scheduler.New(scheduleService, cfg.Scheduler, appLogger)
+		go ticketScheduler.Run(ctx)
+	}
+
+	// Фоновые задачи (расчет объема рейсов)
+	jobWorker := jobs.NewWorker(jobRepo, cfg.Jobs, appLogger)
+	jobWorker.Register(model.JobTypeTripVolume, tripService.HandleVolumeJob, tripService.OnVolumeJobDead)
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		jobWorker.Run(ctx)
+	}()
 
 	addr := fmt.Sprintf("%s:%d", cfg.HTTP.Host, cfg.HTTP.Port)
-	appLogger.Info().Str("addr", addr).Msg("starting ticket service")
+	server := &http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
 
-	if err := router.Run(addr); err != nil {
-		appLogger.Error().Err(err).Msg("failed to start server")
-		os.Exit(1)
+	go func() {
+		appLogger.Info().Str("addr", addr).Msg("starting ticket service")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			appLogger.Error().Err(err).Msg("failed to start server")
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	appLogger.Info().Msg("shutting down ticket service")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		appLogger.Error().Err(err).Msg("failed to shutdown server gracefully")
+	}
+
+	// Дожидаемся завершения текущих задач
+	select {
+	case <-workerDone:
+	case <-shutdownCtx.Done():
+		appLogger.Warn().Msg("job worker did not stop in time")
 	}
 }
@@ -0,0 +1,352 @@
+// Package anprfake - фейковый ANPR сервис для локальной проверки без настоящего ANPR.
+// Отдает /internal/anpr/events из fixture-файлов и позволяет имитировать задержки и ошибки,
+// чтобы пройти сценарий mark-completed → расчет объема целиком офлайн.
+package anprfake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ticket-service/internal/client"
+	"ticket-service/internal/utils"
+)
+
+// Fault - имитация проблем ANPR сервиса
+type Fault struct {
+	// Latency - задержка перед каждым ответом
+	Latency time.Duration
+	// ErrorRate - доля запросов (0..1), на которые возвращается ErrorStatus
+	ErrorRate float64
+	// FailNext - количество ближайших запросов, которые гарантированно завершатся ошибкой
+	FailNext int
+	// ErrorStatus - HTTP статус ошибки, по умолчанию 503
+	ErrorStatus int
+}
+
+type Server struct {
+	mu     sync.Mutex
+	events []client.ANPREvent
+	fault  Fault
+	token  string
+	rnd    *rand.Rand
+}
+
+// New создает фейковый сервер с событиями events. Непустой token требует заголовок X-Internal-Token.
+func New(events []client.ANPREvent, token string) *Server {
+	return &Server{
+		events: events,
+		token:  token,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetFault задает имитацию проблем для следующих запросов
+func (s *Server) SetFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fault = fault
+}
+
+// AddEvents добавляет события, например опоздавшие события для проверки пересчета объема
+func (s *Server) AddEvents(events ...client.ANPREvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+}
+
+// Handler возвращает HTTP-обработчик фейкового сервиса:
+//   - GET /internal/anpr/events?plate=&start_time=&end_time=&direction=&limit=&cursor= - события, как у ANPR сервиса
+//     (с limit - постранично, next_cursor в ответе)
+//   - PUT /_fake/fault - изменить Fault (JSON, latency в формате time.Duration, например "2s")
+//   - POST /_fake/events - добавить события (JSON-массив ANPREvent)
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/internal/anpr/events", s.handleEvents)
+	mux.HandleFunc("/_fake/fault", s.handleFault)
+	mux.HandleFunc("/_fake/events", s.handleAddEvents)
+	return mux
+}
+
+// Start запускает сервер на addr (":0" - свободный порт) в текущем процессе.
+// Возвращает базовый URL для ANPR_SERVICE_URL и функцию остановки.
+func (s *Server) Start(addr string) (string, func() error, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	server := &http.Server{Handler: s.Handler()}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return "http://" + listener.Addr().String(), server.Close, nil
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.token != "" && r.Header.Get("X-Internal-Token") != s.token {
+		writeError(w, http.StatusUnauthorized, "invalid internal token")
+		return
+	}
+
+	if status, failed := s.injectFault(r); failed {
+		writeError(w, status, "injected failure")
+		return
+	}
+
+	q := r.URL.Query()
+	plate := utils.NormalizePlate(q.Get("plate"))
+	if plate == "" {
+		writeError(w, http.StatusBadRequest, "plate is required")
+		return
+	}
+	start, err := time.Parse(time.RFC3339, q.Get("start_time"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid start_time")
+		return
+	}
+	end, err := time.Parse(time.RFC3339, q.Get("end_time"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid end_time")
+		return
+	}
+	direction := q.Get("direction")
+
+	events := s.find(plate, start, end, direction)
+	if q.Get("limit") == "" {
+		writeJSON(w, http.StatusOK, client.ANPREventsResponse{Data: events})
+		return
+	}
+
+	// Постраничная выдача: cursor - смещение в отсортированном списке событий
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	offset := 0
+	if cursor := q.Get("cursor"); cursor != "" {
+		offset, err = strconv.Atoi(cursor)
+		if err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	response := client.ANPREventsResponse{Data: []client.ANPREvent{}}
+	if offset < len(events) {
+		endIndex := min(offset+limit, len(events))
+		response.Data = events[offset:endIndex]
+		if endIndex < len(events) {
+			response.NextCursor = strconv.Itoa(endIndex)
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// injectFault применяет задержку и решает, нужно ли вернуть ошибку
+func (s *Server) injectFault(r *http.Request) (int, bool) {
+	s.mu.Lock()
+	fault := s.fault
+	failed := false
+	if s.fault.FailNext > 0 {
+		s.fault.FailNext--
+		failed = true
+	} else if fault.ErrorRate > 0 && s.rnd.Float64() < fault.ErrorRate {
+		failed = true
+	}
+	s.mu.Unlock()
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+		}
+	}
+
+	status := fault.ErrorStatus
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	return status, failed
+}
+
+func (s *Server) find(plate string, start, end time.Time, direction string) []client.ANPREvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]client.ANPREvent, 0)
+	for _, event := range s.events {
+		if utils.NormalizePlate(event.NormalizedPlate) != plate {
+			continue
+		}
+		if event.EventTime.Before(start) || event.EventTime.After(end) {
+			continue
+		}
+		if direction != "" && (event.Direction == nil || !strings.EqualFold(*event.Direction, direction)) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	// Порядок должен быть стабильным между запросами страниц
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].EventTime.Equal(events[j].EventTime) {
+			return events[i].EventTime.Before(events[j].EventTime)
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events
+}
+
+func (s *Server) handleFault(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		fault := s.fault
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, faultJSON(fault))
+	case http.MethodPut:
+		var req faultRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fault, err := req.toFault()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.SetFault(fault)
+		writeJSON(w, http.StatusOK, faultJSON(fault))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleAddEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	events, err := decodeEvents(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.AddEvents(events...)
+	writeJSON(w, http.StatusCreated, map[string]int{"added": len(events)})
+}
+
+// faultRequest - Fault в JSON с задержкой в виде строки ("250ms", "2s")
+type faultRequest struct {
+	Latency     string  `json:"latency"`
+	ErrorRate   float64 `json:"error_rate"`
+	FailNext    int     `json:"fail_next"`
+	ErrorStatus int     `json:"error_status"`
+}
+
+func (r faultRequest) toFault() (Fault, error) {
+	fault := Fault{
+		ErrorRate:   r.ErrorRate,
+		FailNext:    r.FailNext,
+		ErrorStatus: r.ErrorStatus,
+	}
+	if r.Latency != "" {
+		latency, err := time.ParseDuration(r.Latency)
+		if err != nil {
+			return fault, fmt.Errorf("invalid latency: %w", err)
+		}
+		fault.Latency = latency
+	}
+	if fault.ErrorRate < 0 || fault.ErrorRate > 1 {
+		return fault, errors.New("error_rate must be between 0 and 1")
+	}
+	return fault, nil
+}
+
+func faultJSON(fault Fault) faultRequest {
+	return faultRequest{
+		Latency:     fault.Latency.String(),
+		ErrorRate:   fault.ErrorRate,
+		FailNext:    fault.FailNext,
+		ErrorStatus: fault.ErrorStatus,
+	}
+}
+
+// LoadFixtures читает события из JSON-файлов каталога (или одного файла).
+// Файл содержит массив событий или ответ ANPR сервиса вида {"data": [...]}.
+func LoadFixtures(path string) ([]client.ANPREvent, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	var events []client.ANPREvent
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		batch, err := decodeEvents(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		events = append(events, batch...)
+	}
+	return events, nil
+}
+
+func decodeEvents(r io.Reader) ([]client.ANPREvent, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var events []client.ANPREvent
+	if err := json.Unmarshal(raw, &events); err == nil {
+		return events, nil
+	}
+
+	var response client.ANPREventsResponse
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,219 @@
+package anprfake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ticket-service/internal/client"
+)
+
+func newTestServer(t *testing.T, token string) *Server {
+	t.Helper()
+	events, err := LoadFixtures("../../cmd/anpr-fake/fixtures")
+	if err != nil {
+		t.Fatalf("load fixtures: %v", err)
+	}
+	if len(events) == 0 {
+		t.Fatal("fixtures are empty")
+	}
+	return New(events, token)
+}
+
+func getEvents(t *testing.T, s *Server, params url.Values) (*httptest.ResponseRecorder, client.ANPREventsResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/internal/anpr/events?"+params.Encode(), nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	var body client.ANPREventsResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return rec, body
+}
+
+func eventsQuery(plate string) url.Values {
+	return url.Values{
+		"plate":      {plate},
+		"start_time": {"2025-01-15T08:00:00Z"},
+		"end_time":   {"2025-01-15T12:00:00Z"},
+	}
+}
+
+func TestHandleEventsFilters(t *testing.T) {
+	s := newTestServer(t, "")
+
+	tests := []struct {
+		name      string
+		plate     string
+		direction string
+		want      int
+	}{
+		{name: "all directions", plate: "123ABC02", want: 3},
+		{name: "entry only", plate: "123ABC02", direction: "entry", want: 2},
+		{name: "plate is normalized", plate: "123 abc-02", direction: "ENTRY", want: 2},
+		{name: "misread plate is a different plate", plate: "123ABCO2", want: 1},
+		{name: "unknown plate", plate: "999ZZZ01", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := eventsQuery(tt.plate)
+			if tt.direction != "" {
+				params.Set("direction", tt.direction)
+			}
+			rec, body := getEvents(t, s, params)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+			}
+			if len(body.Data) != tt.want {
+				t.Errorf("got %d events, want %d", len(body.Data), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleEventsPaging(t *testing.T) {
+	s := newTestServer(t, "")
+
+	var ids []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("too many pages")
+		}
+		params := eventsQuery("123ABC02")
+		params.Set("limit", "2")
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+		rec, body := getEvents(t, s, params)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+		}
+		for _, event := range body.Data {
+			ids = append(ids, event.ID)
+		}
+		if body.NextCursor == "" {
+			break
+		}
+		cursor = body.NextCursor
+	}
+
+	want := []string{
+		"a1f0c6b2-0001-4c1e-9a51-000000000001",
+		"a1f0c6b2-0002-4c1e-9a51-000000000002",
+		"a1f0c6b2-0003-4c1e-9a51-000000000003",
+	}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("got events %v, want %v", ids, want)
+	}
+}
+
+func TestHandleEventsInvalidRequest(t *testing.T) {
+	s := newTestServer(t, "")
+
+	tests := []struct {
+		name   string
+		modify func(url.Values)
+	}{
+		{name: "no plate", modify: func(v url.Values) { v.Del("plate") }},
+		{name: "bad start_time", modify: func(v url.Values) { v.Set("start_time", "yesterday") }},
+		{name: "bad limit", modify: func(v url.Values) { v.Set("limit", "0") }},
+		{name: "bad cursor", modify: func(v url.Values) { v.Set("limit", "1"); v.Set("cursor", "-1") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := eventsQuery("123ABC02")
+			tt.modify(params)
+			rec, _ := getEvents(t, s, params)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandleEventsToken(t *testing.T) {
+	s := newTestServer(t, "secret")
+
+	rec, _ := getEvents(t, s, eventsQuery("123ABC02"))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d without token, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/anpr/events?"+eventsQuery("123ABC02").Encode(), nil)
+	req.Header.Set("X-Internal-Token", "secret")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d with token, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	s := newTestServer(t, "")
+	s.SetFault(Fault{FailNext: 2, ErrorStatus: http.StatusBadGateway})
+
+	want := []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK}
+	for i, status := range want {
+		rec, _ := getEvents(t, s, eventsQuery("123ABC02"))
+		if rec.Code != status {
+			t.Errorf("request %d: got status %d, want %d", i+1, rec.Code, status)
+		}
+	}
+}
+
+func TestFaultEndpoint(t *testing.T) {
+	s := newTestServer(t, "")
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "valid fault", body: `{"latency":"0s","fail_next":1}`, wantStatus: http.StatusOK},
+		{name: "bad latency", body: `{"latency":"soon"}`, wantStatus: http.StatusBadRequest},
+		{name: "bad error rate", body: `{"error_rate":1.5}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/_fake/fault", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+
+	// Действует последний корректный Fault: один запрос завершается ошибкой по умолчанию (503)
+	rec, _ := getEvents(t, s, eventsQuery("123ABC02"))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAddEventsEndpoint(t *testing.T) {
+	s := newTestServer(t, "")
+
+	body := `{"data":[{"id":"late-1","normalized_plate":"123ABC02","event_time":"2025-01-15T11:00:00Z","direction":"entry","snow_volume_m3":5}]}`
+	req := httptest.NewRequest(http.MethodPost, "/_fake/events", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	params := eventsQuery("123ABC02")
+	params.Set("direction", "entry")
+	_, events := getEvents(t, s, params)
+	if len(events.Data) != 3 {
+		t.Errorf("got %d events, want 3", len(events.Data))
+	}
+}
@@ -3,10 +3,15 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"ticket-service/internal/config"
@@ -23,28 +28,144 @@ type ANPREvent struct {
 	PolygonID       *string   `json:"polygon_id,omitempty"`
 }
 
+// ANPREventsResponse - страница событий. Пустой NextCursor означает последнюю страницу;
+// сервис без постраничной выдачи возвращает все события одной страницей.
 type ANPREventsResponse struct {
-	Data []ANPREvent `json:"data"`
+	Data       []ANPREvent `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
+// ANPREventSource - источник событий ANPR. Основная реализация - HTTP-клиент ANPRClient,
+// для локальной проверки без ANPR сервиса используется фейковый сервер (internal/anprfake).
+type ANPREventSource interface {
+	// Events перебирает события по номеру за период [startTime, endTime], direction (entry/exit) необязателен.
+	// События загружаются постранично по мере перебора; ошибка возвращается последним элементом.
+	Events(ctx context.Context, plate string, startTime, endTime time.Time, direction *string) iter.Seq2[ANPREvent, error]
+}
+
+var _ ANPREventSource = (*ANPRClient)(nil)
+
 type ANPRClient struct {
 	baseURL       string
 	internalToken string
 	httpClient    *http.Client
+	breaker       *CircuitBreaker
+
+	timeout          time.Duration
+	maxAttempts      int
+	retryBaseDelay   time.Duration
+	retryMaxDelay    time.Duration
+	maxResponseBytes int64
+	pageSize         int
 }
 
+// ANPRStatusError - ANPR сервис ответил статусом, отличным от 200
+type ANPRStatusError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter - задержка из заголовка Retry-After, если он был
+	RetryAfter time.Duration
+}
+
+func (e *ANPRStatusError) Error() string {
+	return fmt.Sprintf("ANPR service returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary - ошибка может пройти при повторе (перегрузка или сбой на стороне сервиса)
+func (e *ANPRStatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+var (
+	// errResponseTooLarge - страница больше MaxResponseBytes, повтор не поможет
+	errResponseTooLarge = errors.New("ANPR response is too large")
+	// errInvalidResponse - ответ не соответствует формату {"data": [...], "next_cursor": "..."}
+	errInvalidResponse = errors.New("invalid ANPR response")
+)
+
+// errorBodyLimit - сколько байт тела ошибки попадает в текст ошибки
+const errorBodyLimit = 512
+
 func NewANPRClient(cfg *config.Config) *ANPRClient {
+	services := cfg.ExternalServices
 	return &ANPRClient{
-		baseURL:       cfg.ExternalServices.ANPRServiceURL,
-		internalToken: cfg.ExternalServices.ANPRInternalToken,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		baseURL:       services.ANPRServiceURL,
+		internalToken: services.ANPRInternalToken,
+		// Таймаут задается на каждую попытку через контекст
+		httpClient:       &http.Client{},
+		breaker:          NewCircuitBreaker(services.ANPRBreakerThreshold, services.ANPRBreakerCooldown),
+		timeout:          services.ANPRTimeout,
+		maxAttempts:      max(services.ANPRMaxAttempts, 1),
+		retryBaseDelay:   services.ANPRRetryBaseDelay,
+		retryMaxDelay:    services.ANPRRetryMaxDelay,
+		maxResponseBytes: services.ANPRMaxResponseBytes,
+		pageSize:         services.ANPRPageSize,
 	}
 }
 
-// GetEventsByPlateAndTime получает события ANPR за указанный период
+// Breaker возвращает состояние предохранителя для health check
+func (c *ANPRClient) Breaker() BreakerSnapshot {
+	return c.breaker.Snapshot()
+}
+
+// GetEventsByPlateAndTime получает все события ANPR за указанный период в память.
+// Для длинных периодов используйте Events.
 func (c *ANPRClient) GetEventsByPlateAndTime(ctx context.Context, plate string, startTime, endTime time.Time, direction *string) ([]ANPREvent, error) {
+	var events []ANPREvent
+	for event, err := range c.Events(ctx, plate, startTime, endTime, direction) {
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Events перебирает события ANPR за указанный период, запрашивая их страницами по ANPRPageSize
+// (параметры limit/cursor) и разбирая ответ потоково: в памяти держится одно событие.
+// Каждая страница запрашивается с повторами (сетевые ошибки, таймауты, 429, 5xx) и через предохранитель.
+// Если страница оборвалась на середине, при повторе уже переданные события пропускаются.
+func (c *ANPRClient) Events(ctx context.Context, plate string, startTime, endTime time.Time, direction *string) iter.Seq2[ANPREvent, error] {
+	return func(yield func(ANPREvent, error) bool) {
+		u, err := c.eventsURL(plate, startTime, endTime, direction)
+		if err != nil {
+			yield(ANPREvent{}, err)
+			return
+		}
+
+		cursor := ""
+		for {
+			var (
+				nextCursor string
+				stopped    bool
+				yielded    int
+			)
+			err := c.withRetry(ctx, func(ctx context.Context) error {
+				var err error
+				nextCursor, stopped, err = c.fetchPage(ctx, pageURL(u, cursor, c.pageSize), &yielded, func(event ANPREvent) bool {
+					return yield(event, nil)
+				})
+				return err
+			})
+			if err != nil {
+				yield(ANPREvent{}, fmt.Errorf("failed to get ANPR events: %w", err))
+				return
+			}
+			if stopped || nextCursor == "" {
+				return
+			}
+			if nextCursor == cursor {
+				yield(ANPREvent{}, fmt.Errorf("%w: next_cursor %q repeats", errInvalidResponse, cursor))
+				return
+			}
+			cursor = nextCursor
+		}
+	}
+}
+
+func (c *ANPRClient) eventsURL(plate string, startTime, endTime time.Time, direction *string) (*url.URL, error) {
 	// Проверяем, что baseURL настроен
 	if c.baseURL == "" {
 		return nil, fmt.Errorf("ANPR service URL is not configured")
@@ -71,60 +192,288 @@ func (c *ANPRClient) GetEventsByPlateAndTime(ctx context.Context, plate string,
 		q.Set("direction", *direction)
 	}
 	u.RawQuery = q.Encode()
+	return u, nil
+}
 
-	// Создаем запрос
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+func pageURL(base *url.URL, cursor string, pageSize int) string {
+	u := *base
+	q := u.Query()
+	if pageSize > 0 {
+		q.Set("limit", strconv.Itoa(pageSize))
+	}
+	if cursor != "" {
+		q.Set("cursor", cursor)
 	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// withRetry выполняет attempt с повторами и учетом предохранителя.
+// Сетевые ошибки, таймауты, 429 и 5xx повторяются с экспоненциальной задержкой (с учетом Retry-After);
+// после ANPRBreakerThreshold ошибок подряд запросы не выполняются до истечения ANPRBreakerCooldown.
+func (c *ANPRClient) withRetry(ctx context.Context, attempt func(ctx context.Context) error) error {
+	var lastErr error
+	for n := 1; n <= c.maxAttempts; n++ {
+		if !c.breaker.Allow() {
+			if lastErr != nil {
+				return fmt.Errorf("%w (last error: %v)", ErrCircuitOpen, lastErr)
+			}
+			return ErrCircuitOpen
+		}
+
+		err := attempt(ctx)
+		if err == nil {
+			c.breaker.Success()
+			return nil
+		}
+		lastErr = err
+
+		// Вызывающий отменил запрос - о состоянии ANPR это ничего не говорит
+		if ctx.Err() != nil {
+			c.breaker.Release()
+			return ctx.Err()
+		}
 
+		retryable, serviceFailure := classifyError(err)
+		if serviceFailure {
+			c.breaker.Failure()
+		} else {
+			c.breaker.Release()
+		}
+		if !retryable || n == c.maxAttempts {
+			break
+		}
+
+		delay := c.backoff(n)
+		var statusErr *ANPRStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			// Сервис просит подождать дольше, чем мы готовы ждать в рамках вызова;
+			// повтор остается на вызывающем (например, очереди задач)
+			if statusErr.RetryAfter > c.retryMaxDelay {
+				break
+			}
+			delay = statusErr.RetryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return lastErr
+}
+
+// fetchPage выполняет одну попытку запроса страницы с таймаутом timeout и передает события в yield
+// по мере разбора ответа. yielded - сколько событий страницы уже передано (в том числе предыдущими
+// попытками); столько первых событий пропускается. stopped - yield попросил остановиться.
+// Таймаут попытки включает время обработки событий в yield.
+func (c *ANPRClient) fetchPage(ctx context.Context, rawURL string, yielded *int, yield func(ANPREvent) bool) (nextCursor string, stopped bool, err error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create request: %w", err)
+	}
 	// Добавляем internal token
 	if c.internalToken != "" {
 		req.Header.Set("X-Internal-Token", c.internalToken)
 	}
 
-	// Выполняем запрос с retry при сетевых ошибках
-	var resp *http.Response
-	var lastErr error
-	maxRetries := 3
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		resp, lastErr = c.httpClient.Do(req)
-		if lastErr == nil {
-			break
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyLimit))
+		return "", false, &ANPRStatusError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(body)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
 		}
-		// Если это последняя попытка, возвращаем ошибку
-		if attempt == maxRetries-1 {
-			return nil, fmt.Errorf("failed to execute request after %d attempts: %w", maxRetries, lastErr)
+	}
+
+	// Читаем страницу, не больше maxResponseBytes
+	var body io.Reader = resp.Body
+	if c.maxResponseBytes > 0 {
+		body = &limitedReader{r: resp.Body, remaining: c.maxResponseBytes}
+	}
+
+	skip := *yielded
+	index := 0
+	nextCursor, err = decodeEventsPage(json.NewDecoder(body), func(event ANPREvent) bool {
+		index++
+		if index <= skip {
+			return true
 		}
-		// Ждем перед повторной попыткой (exponential backoff)
-		time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
-		// Пересоздаем запрос для повторной попытки
-		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-		if c.internalToken != "" {
-			req.Header.Set("X-Internal-Token", c.internalToken)
+		*yielded++
+		if !yield(event) {
+			stopped = true
+			return false
 		}
+		return true
+	})
+	if err != nil {
+		return "", false, err
 	}
-	if resp == nil {
-		return nil, fmt.Errorf("failed to execute request: %w", lastErr)
+	return nextCursor, stopped, nil
+}
+
+// decodeEventsPage разбирает {"data": [...], "next_cursor": "..."} по одному событию.
+// Если onEvent вернул false, разбор прекращается без ошибки.
+func decodeEventsPage(dec *json.Decoder, onEvent func(ANPREvent) bool) (string, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return "", err
+	}
+
+	var nextCursor string
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		key, _ := token.(string)
+
+		switch key {
+		case "data":
+			token, err := dec.Token()
+			if err != nil {
+				return "", err
+			}
+			if token == nil {
+				continue
+			}
+			if delim, ok := token.(json.Delim); !ok || delim != '[' {
+				return "", fmt.Errorf("%w: data is not an array", errInvalidResponse)
+			}
+			for dec.More() {
+				var event ANPREvent
+				if err := dec.Decode(&event); err != nil {
+					return "", err
+				}
+				if !onEvent(event) {
+					return "", nil
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return "", err
+			}
+		case "next_cursor":
+			var cursor *string
+			if err := dec.Decode(&cursor); err != nil {
+				return "", err
+			}
+			if cursor != nil {
+				nextCursor = *cursor
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return "", err
+			}
+		}
 	}
-	defer resp.Body.Close()
 
-	// Читаем ответ
-	body, err := io.ReadAll(resp.Body)
+	if err := expectDelim(dec, '}'); err != nil {
+		return "", err
+	}
+	return nextCursor, nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("%w: expected %q", errInvalidResponse, want)
 	}
+	return nil
+}
 
-	// Проверяем статус код
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ANPR service returned status %d: %s", resp.StatusCode, string(body))
+// limitedReader возвращает errResponseTooLarge, если прочитано больше remaining байт
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errResponseTooLarge
+	}
+	return n, err
+}
+
+// classifyError определяет, стоит ли повторять запрос и считается ли ошибка отказом ANPR для предохранителя.
+// 429 повторяется, но не открывает предохранитель: сервис жив и просит снизить нагрузку.
+func classifyError(err error) (retryable bool, serviceFailure bool) {
+	var statusErr *ANPRStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode == http.StatusTooManyRequests {
+			return true, false
+		}
+		return statusErr.Temporary(), statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	if errors.Is(err, errResponseTooLarge) || errors.Is(err, errInvalidResponse) {
+		return false, false
+	}
+
+	// Некорректный JSON в ответе 200 - повтор не поможет
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return false, false
 	}
 
-	// Парсим ответ
-	var response ANPREventsResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	// Сетевые ошибки и таймауты попытки
+	return true, true
+}
+
+// backoff - задержка перед попыткой attempt+1: удваивается от retryBaseDelay до retryMaxDelay, ±20%
+func (c *ANPRClient) backoff(attempt int) time.Duration {
+	delay := c.retryBaseDelay
+	for i := 1; i < attempt && delay < c.retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.retryMaxDelay {
+		delay = c.retryMaxDelay
 	}
+	jitter := time.Duration((rand.Float64()*0.4 - 0.2) * float64(delay))
+	return delay + jitter
+}
 
-	return response.Data, nil
+// parseRetryAfter разбирает Retry-After в секундах или в формате HTTP-даты
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := at.Sub(now); delay > 0 {
+			return delay
+		}
+	}
+	return 0
 }
@@ -0,0 +1,284 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ticket-service/internal/anprfake"
+	"ticket-service/internal/client"
+	"ticket-service/internal/config"
+)
+
+var (
+	windowStart = time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)
+	windowEnd   = time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+)
+
+func loadFixtures(t *testing.T) []client.ANPREvent {
+	t.Helper()
+	events, err := anprfake.LoadFixtures("../../cmd/anpr-fake/fixtures")
+	if err != nil {
+		t.Fatalf("load fixtures: %v", err)
+	}
+	return events
+}
+
+// countingServer поднимает фейковый ANPR и считает запросы к нему
+func countingServer(t *testing.T, handler http.Handler) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newClient(baseURL string, configure func(*config.ExternalServicesConfig)) *client.ANPRClient {
+	cfg := &config.Config{}
+	services := &cfg.ExternalServices
+	services.ANPRServiceURL = baseURL
+	services.ANPRTimeout = 2 * time.Second
+	services.ANPRMaxAttempts = 3
+	services.ANPRRetryBaseDelay = time.Millisecond
+	services.ANPRRetryMaxDelay = 5 * time.Millisecond
+	services.ANPRMaxResponseBytes = 1 << 20
+	if configure != nil {
+		configure(services)
+	}
+	return client.NewANPRClient(cfg)
+}
+
+func collect(t *testing.T, c *client.ANPRClient, plate string, direction *string) ([]client.ANPREvent, error) {
+	t.Helper()
+	return c.GetEventsByPlateAndTime(context.Background(), plate, windowStart, windowEnd, direction)
+}
+
+func TestEventsPaging(t *testing.T) {
+	fake := anprfake.New(loadFixtures(t), "")
+	server, requests := countingServer(t, fake.Handler())
+
+	tests := []struct {
+		name         string
+		pageSize     int
+		wantRequests int32
+	}{
+		{name: "single response", pageSize: 0, wantRequests: 1},
+		{name: "page per event", pageSize: 1, wantRequests: 2},
+		{name: "one full page", pageSize: 2, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			c := newClient(server.URL, func(s *config.ExternalServicesConfig) { s.ANPRPageSize = tt.pageSize })
+
+			entry := "entry"
+			events, err := collect(t, c, "123 abc 02", &entry)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			wantIDs := []string{
+				"a1f0c6b2-0001-4c1e-9a51-000000000001",
+				"a1f0c6b2-0003-4c1e-9a51-000000000003",
+			}
+			if len(events) != len(wantIDs) {
+				t.Fatalf("got %d events, want %d", len(events), len(wantIDs))
+			}
+			for i, id := range wantIDs {
+				if events[i].ID != id {
+					t.Errorf("event %d: got %s, want %s", i, events[i].ID, id)
+				}
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestEventsStopEarly(t *testing.T) {
+	fake := anprfake.New(loadFixtures(t), "")
+	server, requests := countingServer(t, fake.Handler())
+	c := newClient(server.URL, func(s *config.ExternalServicesConfig) { s.ANPRPageSize = 1 })
+
+	for _, err := range c.Events(context.Background(), "123ABC02", windowStart, windowEnd, nil) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		break
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestEventsRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		fault        anprfake.Fault
+		wantStatus   int
+		wantRequests int32
+	}{
+		{
+			name:         "recovers after transient failures",
+			fault:        anprfake.Fault{FailNext: 2},
+			wantRequests: 3,
+		},
+		{
+			name:         "gives up after max attempts",
+			fault:        anprfake.Fault{FailNext: 5},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantRequests: 3,
+		},
+		{
+			name:         "retries too many requests",
+			fault:        anprfake.Fault{FailNext: 1, ErrorStatus: http.StatusTooManyRequests},
+			wantRequests: 2,
+		},
+		{
+			name:         "does not retry client errors",
+			fault:        anprfake.Fault{FailNext: 1, ErrorStatus: http.StatusBadRequest},
+			wantStatus:   http.StatusBadRequest,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := anprfake.New(loadFixtures(t), "")
+			fake.SetFault(tt.fault)
+			server, requests := countingServer(t, fake.Handler())
+			c := newClient(server.URL, nil)
+
+			events, err := collect(t, c, "123ABC02", nil)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(events) != 3 {
+					t.Errorf("got %d events, want 3", len(events))
+				}
+			} else {
+				var statusErr *client.ANPRStatusError
+				if !errors.As(err, &statusErr) {
+					t.Fatalf("got error %v, want ANPRStatusError", err)
+				}
+				if statusErr.StatusCode != tt.wantStatus {
+					t.Errorf("got status %d, want %d", statusErr.StatusCode, tt.wantStatus)
+				}
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestEventsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   string
+		wantErr      bool
+		wantRequests int32
+	}{
+		{name: "short delay is honoured", retryAfter: "0", wantRequests: 2},
+		{name: "long delay is left to the caller", retryAfter: "120", wantErr: true, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := anprfake.New(loadFixtures(t), "")
+			var throttled atomic.Bool
+			server, requests := countingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if throttled.CompareAndSwap(false, true) {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				fake.Handler().ServeHTTP(w, r)
+			}))
+			c := newClient(server.URL, nil)
+
+			_, err := collect(t, c, "123ABC02", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	fake := anprfake.New(loadFixtures(t), "")
+	fake.SetFault(anprfake.Fault{FailNext: 2})
+	server, requests := countingServer(t, fake.Handler())
+	c := newClient(server.URL, func(s *config.ExternalServicesConfig) {
+		s.ANPRMaxAttempts = 1
+		s.ANPRBreakerThreshold = 2
+		s.ANPRBreakerCooldown = 50 * time.Millisecond
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := collect(t, c, "123ABC02", nil); err == nil {
+			t.Fatalf("call %d: expected error", i+1)
+		}
+	}
+	if state := c.Breaker().State; state != client.BreakerOpen {
+		t.Fatalf("got breaker state %s, want %s", state, client.BreakerOpen)
+	}
+
+	// Открытый предохранитель не пропускает запросы к сервису
+	if _, err := collect(t, c, "123ABC02", nil); !errors.Is(err, client.ErrCircuitOpen) {
+		t.Fatalf("got error %v, want ErrCircuitOpen", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+
+	// После cooldown пробный запрос проходит и закрывает предохранитель
+	time.Sleep(60 * time.Millisecond)
+	events, err := collect(t, c, "123ABC02", nil)
+	if err != nil {
+		t.Fatalf("unexpected error after cooldown: %v", err)
+	}
+	if len(events) != 3 {
+		t.Errorf("got %d events, want 3", len(events))
+	}
+	if state := c.Breaker().State; state != client.BreakerClosed {
+		t.Errorf("got breaker state %s, want %s", state, client.BreakerClosed)
+	}
+}
+
+func TestEventsResponseTooLarge(t *testing.T) {
+	fake := anprfake.New(loadFixtures(t), "")
+	server, requests := countingServer(t, fake.Handler())
+	c := newClient(server.URL, func(s *config.ExternalServicesConfig) { s.ANPRMaxResponseBytes = 64 })
+
+	if _, err := collect(t, c, "123ABC02", nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1 (too large response is not retried)", got)
+	}
+}
+
+func TestEventsInternalToken(t *testing.T) {
+	fake := anprfake.New(loadFixtures(t), "secret")
+	server, _ := countingServer(t, fake.Handler())
+
+	if _, err := collect(t, newClient(server.URL, nil), "123ABC02", nil); err == nil {
+		t.Error("expected error without internal token")
+	}
+
+	c := newClient(server.URL, func(s *config.ExternalServicesConfig) { s.ANPRInternalToken = "secret" })
+	if _, err := collect(t, c, "123ABC02", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen - запрос не выполнялся, т.к. сервис считается недоступным
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerSnapshot - текущее состояние предохранителя для health check
+type BreakerSnapshot struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker перестает пропускать запросы после threshold ошибок подряд.
+// Через cooldown пропускается один пробный запрос: успех закрывает предохранитель,
+// ошибка снова открывает его на cooldown. threshold <= 0 отключает предохранитель.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow решает, можно ли выполнить запрос. После разрешенного запроса
+// обязательно вызывается Success, Failure или Release.
+func (b *CircuitBreaker) Allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		// Пока пробный запрос не завершен, остальные не пропускаем
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success закрывает предохранитель и сбрасывает счетчик ошибок
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure учитывает ошибку сервиса; неудачный пробный запрос сразу открывает предохранитель
+func (b *CircuitBreaker) Failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release завершает запрос, результат которого ничего не говорит о сервисе
+// (например, вызывающий отменил контекст)
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *CircuitBreaker) Snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := BreakerSnapshot{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		snapshot.State = BreakerHalfOpen
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		snapshot.OpenedAt = &openedAt
+	}
+	return snapshot
+}
@@ -21,6 +21,8 @@ type DBConfig struct {
 
 type AuthConfig struct {
 	AccessSecret string
+	// ServiceToken — токен для межсервисных вызовов (/internal/*), не связан с JWT пользователей
+	ServiceToken string
 }
 
 type ExternalServicesConfig struct {
@@ -30,6 +32,90 @@ type ExternalServicesConfig struct {
 	AIServiceURL         string
 	ANPRServiceURL       string
 	ANPRInternalToken    string
+	// ANPRFakeFixtures - каталог с событиями для фейкового ANPR, запускаемого внутри процесса
+	// (только не в production). Если задан, ANPR_SERVICE_URL игнорируется.
+	ANPRFakeFixtures string
+
+	// ANPRTimeout - таймаут одной попытки запроса к ANPR
+	ANPRTimeout time.Duration
+	// ANPRMaxAttempts - число попыток одного запроса (сетевые ошибки, 429, 5xx)
+	ANPRMaxAttempts int
+	// ANPRRetryBaseDelay/ANPRRetryMaxDelay - задержка между попытками растет экспоненциально.
+	// Если Retry-After больше ANPRRetryMaxDelay, запрос не повторяется.
+	ANPRRetryBaseDelay time.Duration
+	ANPRRetryMaxDelay  time.Duration
+	// ANPRMaxResponseBytes - максимальный размер одной страницы ответа ANPR
+	ANPRMaxResponseBytes int64
+	// ANPRPageSize - количество событий на странице (параметр limit), 0 - без постраничной выдачи
+	ANPRPageSize int
+	// ANPRBreakerThreshold - после скольких отказов подряд запросы к ANPR приостанавливаются (0 - никогда)
+	ANPRBreakerThreshold int
+	// ANPRBreakerCooldown - через сколько после открытия предохранителя выполняется пробный запрос
+	ANPRBreakerCooldown time.Duration
+
+	// ANPRWebhookSecret - ключ HMAC-подписи webhook ANPR, по умолчанию ANPRInternalToken
+	ANPRWebhookSecret string
+	// ANPRWebhookTolerance - допустимое расхождение X-Timestamp webhook с текущим временем
+	ANPRWebhookTolerance time.Duration
+}
+
+// PairingConfig задает окна сопоставления LPR/volume событий в рейсы
+type PairingConfig struct {
+	// VolumeMatchWindow - максимальная разница во времени между LPR и volume событием
+	VolumeMatchWindow time.Duration
+	// MaxTripDuration - максимальное время между въездом и выездом одного рейса
+	MaxTripDuration time.Duration
+}
+
+// ViolationsConfig задает пороги проверок нарушений по умолчанию.
+// Для отдельных договоров пороги переопределяются в таблице contract_settings.
+type ViolationsConfig struct {
+	MaxTripVolumeM3       float64
+	MinTripVolumeM3       float64
+	MaxExitVolumeM3       float64
+	ContractVolumeLimitM3 float64
+	RequirePolygonAccess  bool
+}
+
+// VolumeConfig задает учет принятого объема снега
+type VolumeConfig struct {
+	// DefaultStrategy - стратегия для договоров без собственной настройки в contract_settings
+	DefaultStrategy string
+}
+
+// ReconcilerConfig задает фоновое повторное сопоставление рейсов без назначения
+type ReconcilerConfig struct {
+	Enabled bool
+	// Interval - период между проходами
+	Interval time.Duration
+	// Lookback - насколько давние рейсы пересматриваются
+	Lookback time.Duration
+	// BatchSize - количество рейсов, загружаемых за один запрос
+	BatchSize int
+}
+
+// SchedulerConfig задает создание тикетов по расписаниям (ticket_schedules)
+type SchedulerConfig struct {
+	Enabled bool
+	// Interval - период между проходами планировщика
+	Interval time.Duration
+	// Horizon - на сколько вперед создаются тикеты серии
+	Horizon time.Duration
+	// DefaultTimezone - часовой пояс расписания, если он не указан при создании
+	DefaultTimezone string
+}
+
+// JobsConfig задает обработку фоновых задач из таблицы jobs
+type JobsConfig struct {
+	Workers      int
+	PollInterval time.Duration
+	// MaxAttempts - число попыток, после которого задача переводится в DEAD
+	MaxAttempts int
+	// BaseBackoff/MaxBackoff - задержка перед повтором растет экспоненциально от BaseBackoff до MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// LockTimeout - через сколько задача в RUNNING считается брошенной (упавший воркер) и возвращается в очередь
+	LockTimeout time.Duration
 }
 
 type Config struct {
@@ -38,6 +124,12 @@ type Config struct {
 	DB               DBConfig
 	Auth             AuthConfig
 	ExternalServices ExternalServicesConfig
+	Pairing          PairingConfig
+	Violations       ViolationsConfig
+	Volume           VolumeConfig
+	Reconciler       ReconcilerConfig
+	Scheduler        SchedulerConfig
+	Jobs             JobsConfig
 }
 
 func Load() (*Config, error) {
@@ -67,6 +159,7 @@ func Load() (*Config, error) {
 		},
 		Auth: AuthConfig{
 			AccessSecret: v.GetString("JWT_ACCESS_SECRET"),
+			ServiceToken: v.GetString("INTERNAL_SERVICE_TOKEN"),
 		},
 		ExternalServices: ExternalServicesConfig{
 			AuthServiceURL:       v.GetString("AUTH_SERVICE_URL"),
@@ -75,6 +168,51 @@ func Load() (*Config, error) {
 			AIServiceURL:         v.GetString("AI_SERVICE_URL"),
 			ANPRServiceURL:       v.GetString("ANPR_SERVICE_URL"),
 			ANPRInternalToken:    v.GetString("ANPR_INTERNAL_TOKEN"),
+			ANPRFakeFixtures:     v.GetString("ANPR_FAKE_FIXTURES"),
+			ANPRTimeout:          v.GetDuration("ANPR_TIMEOUT"),
+			ANPRMaxAttempts:      v.GetInt("ANPR_MAX_ATTEMPTS"),
+			ANPRRetryBaseDelay:   v.GetDuration("ANPR_RETRY_BASE_DELAY"),
+			ANPRRetryMaxDelay:    v.GetDuration("ANPR_RETRY_MAX_DELAY"),
+			ANPRMaxResponseBytes: v.GetInt64("ANPR_MAX_RESPONSE_BYTES"),
+			ANPRPageSize:         v.GetInt("ANPR_PAGE_SIZE"),
+			ANPRBreakerThreshold: v.GetInt("ANPR_BREAKER_THRESHOLD"),
+			ANPRBreakerCooldown:  v.GetDuration("ANPR_BREAKER_COOLDOWN"),
+			ANPRWebhookSecret:    v.GetString("ANPR_WEBHOOK_SECRET"),
+			ANPRWebhookTolerance: v.GetDuration("ANPR_WEBHOOK_TOLERANCE"),
+		},
+		Pairing: PairingConfig{
+			VolumeMatchWindow: v.GetDuration("PAIRING_VOLUME_MATCH_WINDOW"),
+			MaxTripDuration:   v.GetDuration("PAIRING_MAX_TRIP_DURATION"),
+		},
+		Violations: ViolationsConfig{
+			MaxTripVolumeM3:       v.GetFloat64("VIOLATIONS_MAX_TRIP_VOLUME_M3"),
+			MinTripVolumeM3:       v.GetFloat64("VIOLATIONS_MIN_TRIP_VOLUME_M3"),
+			MaxExitVolumeM3:       v.GetFloat64("VIOLATIONS_MAX_EXIT_VOLUME_M3"),
+			ContractVolumeLimitM3: v.GetFloat64("VIOLATIONS_CONTRACT_VOLUME_LIMIT_M3"),
+			RequirePolygonAccess:  v.GetBool("VIOLATIONS_REQUIRE_POLYGON_ACCESS"),
+		},
+		Volume: VolumeConfig{
+			DefaultStrategy: v.GetString("VOLUME_DEFAULT_STRATEGY"),
+		},
+		Reconciler: ReconcilerConfig{
+			Enabled:   v.GetBool("RECONCILER_ENABLED"),
+			Interval:  v.GetDuration("RECONCILER_INTERVAL"),
+			Lookback:  v.GetDuration("RECONCILER_LOOKBACK"),
+			BatchSize: v.GetInt("RECONCILER_BATCH_SIZE"),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:         v.GetBool("SCHEDULER_ENABLED"),
+			Interval:        v.GetDuration("SCHEDULER_INTERVAL"),
+			Horizon:         v.GetDuration("SCHEDULER_HORIZON"),
+			DefaultTimezone: v.GetString("SCHEDULER_DEFAULT_TIMEZONE"),
+		},
+		Jobs: JobsConfig{
+			Workers:      v.GetInt("JOBS_WORKERS"),
+			PollInterval: v.GetDuration("JOBS_POLL_INTERVAL"),
+			MaxAttempts:  v.GetInt("JOBS_MAX_ATTEMPTS"),
+			BaseBackoff:  v.GetDuration("JOBS_BASE_BACKOFF"),
+			MaxBackoff:   v.GetDuration("JOBS_MAX_BACKOFF"),
+			LockTimeout:  v.GetDuration("JOBS_LOCK_TIMEOUT"),
 		},
 	}
 
@@ -87,6 +225,90 @@ func Load() (*Config, error) {
 	if cfg.Environment == "" {
 		cfg.Environment = "development"
 	}
+	if cfg.Pairing.VolumeMatchWindow == 0 {
+		cfg.Pairing.VolumeMatchWindow = 2 * time.Minute
+	}
+	if cfg.Pairing.MaxTripDuration == 0 {
+		cfg.Pairing.MaxTripDuration = 6 * time.Hour
+	}
+	if cfg.ExternalServices.ANPRTimeout == 0 {
+		cfg.ExternalServices.ANPRTimeout = 10 * time.Second
+	}
+	if cfg.ExternalServices.ANPRMaxAttempts == 0 {
+		cfg.ExternalServices.ANPRMaxAttempts = 3
+	}
+	if cfg.ExternalServices.ANPRRetryBaseDelay == 0 {
+		cfg.ExternalServices.ANPRRetryBaseDelay = 500 * time.Millisecond
+	}
+	if cfg.ExternalServices.ANPRRetryMaxDelay == 0 {
+		cfg.ExternalServices.ANPRRetryMaxDelay = 10 * time.Second
+	}
+	if cfg.ExternalServices.ANPRMaxResponseBytes == 0 {
+		cfg.ExternalServices.ANPRMaxResponseBytes = 10 << 20
+	}
+	if !v.IsSet("ANPR_PAGE_SIZE") {
+		cfg.ExternalServices.ANPRPageSize = 500
+	}
+	if !v.IsSet("ANPR_BREAKER_THRESHOLD") {
+		cfg.ExternalServices.ANPRBreakerThreshold = 5
+	}
+	if cfg.ExternalServices.ANPRBreakerCooldown == 0 {
+		cfg.ExternalServices.ANPRBreakerCooldown = 30 * time.Second
+	}
+	if cfg.ExternalServices.ANPRWebhookSecret == "" {
+		cfg.ExternalServices.ANPRWebhookSecret = cfg.ExternalServices.ANPRInternalToken
+	}
+	if cfg.ExternalServices.ANPRWebhookTolerance == 0 {
+		cfg.ExternalServices.ANPRWebhookTolerance = 5 * time.Minute
+	}
+	if !v.IsSet("VIOLATIONS_MAX_EXIT_VOLUME_M3") {
+		cfg.Violations.MaxExitVolumeM3 = 1
+	}
+	if cfg.Volume.DefaultStrategy == "" {
+		cfg.Volume.DefaultStrategy = "NET_ENTRY_EXIT"
+	}
+	if !v.IsSet("RECONCILER_ENABLED") {
+		cfg.Reconciler.Enabled = true
+	}
+	if cfg.Reconciler.Interval == 0 {
+		cfg.Reconciler.Interval = 5 * time.Minute
+	}
+	if cfg.Reconciler.Lookback == 0 {
+		cfg.Reconciler.Lookback = 72 * time.Hour
+	}
+	if cfg.Reconciler.BatchSize == 0 {
+		cfg.Reconciler.BatchSize = 200
+	}
+	if !v.IsSet("SCHEDULER_ENABLED") {
+		cfg.Scheduler.Enabled = true
+	}
+	if cfg.Scheduler.Interval == 0 {
+		cfg.Scheduler.Interval = 10 * time.Minute
+	}
+	if cfg.Scheduler.Horizon == 0 {
+		cfg.Scheduler.Horizon = 72 * time.Hour
+	}
+	if cfg.Scheduler.DefaultTimezone == "" {
+		cfg.Scheduler.DefaultTimezone = "Asia/Almaty"
+	}
+	if cfg.Jobs.Workers == 0 {
+		cfg.Jobs.Workers = 2
+	}
+	if cfg.Jobs.PollInterval == 0 {
+		cfg.Jobs.PollInterval = 2 * time.Second
+	}
+	if cfg.Jobs.MaxAttempts == 0 {
+		cfg.Jobs.MaxAttempts = 10
+	}
+	if cfg.Jobs.BaseBackoff == 0 {
+		cfg.Jobs.BaseBackoff = 30 * time.Second
+	}
+	if cfg.Jobs.MaxBackoff == 0 {
+		cfg.Jobs.MaxBackoff = 30 * time.Minute
+	}
+	if cfg.Jobs.LockTimeout == 0 {
+		cfg.Jobs.LockTimeout = 10 * time.Minute
+	}
 
 	if err := validate(cfg); err != nil {
 		return nil, err
@@ -102,5 +324,16 @@ func validate(cfg *Config) error {
 	if cfg.Auth.AccessSecret == "" {
 		return fmt.Errorf("JWT_ACCESS_SECRET is required")
 	}
+	if cfg.ExternalServices.ANPRFakeFixtures != "" && cfg.Environment == "production" {
+		return fmt.Errorf("ANPR_FAKE_FIXTURES is not allowed in production")
+	}
+	if _, err := time.LoadLocation(cfg.Scheduler.DefaultTimezone); err != nil {
+		return fmt.Errorf("SCHEDULER_DEFAULT_TIMEZONE is invalid: %w", err)
+	}
+	switch cfg.Volume.DefaultStrategy {
+	case "NET_ENTRY_EXIT", "ANPR_ENTRY_SUM", "MAX_BODY_FILL":
+	default:
+		return fmt.Errorf("VOLUME_DEFAULT_STRATEGY must be one of NET_ENTRY_EXIT, ANPR_ENTRY_SUM, MAX_BODY_FILL")
+	}
 	return nil
 }
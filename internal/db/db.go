@@ -27,6 +27,8 @@ func New(cfg *config.Config, log zerolog.Logger) (*gorm.DB, error) {
 
 	database, err := gorm.Open(postgres.Open(dbCfg.DSN), &gorm.Config{
 		Logger: gormLog,
+		// Нарушения уникальности возвращаются как gorm.ErrDuplicatedKey (нужно для идемпотентного приёма событий)
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, err
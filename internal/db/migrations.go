@@ -336,6 +336,9 @@ var migrationStatements = []string{
 	$$;`,
 	`CREATE INDEX IF NOT EXISTS idx_volume_events_camera_id ON volume_events (camera_id);`,
 	`CREATE INDEX IF NOT EXISTS idx_volume_events_detected_at ON volume_events (detected_at);`,
+	`CREATE INDEX IF NOT EXISTS idx_trips_detected_plate_number ON trips (detected_plate_number);`,
+	`CREATE INDEX IF NOT EXISTS idx_trips_entry_volume_event_id ON trips (entry_volume_event_id);`,
+	`CREATE INDEX IF NOT EXISTS idx_trips_exit_volume_event_id ON trips (exit_volume_event_id);`,
 	`CREATE TABLE IF NOT EXISTS appeals (
 		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
 		trip_id UUID REFERENCES trips(id) ON DELETE CASCADE,
@@ -383,6 +386,265 @@ var migrationStatements = []string{
 		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 	);`,
 	`CREATE INDEX IF NOT EXISTS idx_appeal_comments_appeal_id ON appeal_comments (appeal_id);`,
+	`ALTER TABLE lpr_events ADD COLUMN IF NOT EXISTS external_id VARCHAR(128);`,
+	`ALTER TABLE volume_events ADD COLUMN IF NOT EXISTS external_id VARCHAR(128);`,
+	`ALTER TABLE trips ADD COLUMN IF NOT EXISTS external_id VARCHAR(128);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uq_lpr_events_external_id ON lpr_events (external_id) WHERE external_id IS NOT NULL;`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uq_volume_events_external_id ON volume_events (external_id) WHERE external_id IS NOT NULL;`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uq_trips_external_id ON trips (external_id) WHERE external_id IS NOT NULL;`,
+	// Одно LPR/volume событие может принадлежать только одному рейсу
+	`CREATE UNIQUE INDEX IF NOT EXISTS uq_trips_entry_lpr_event_id ON trips (entry_lpr_event_id) WHERE entry_lpr_event_id IS NOT NULL;`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS uq_trips_exit_lpr_event_id ON trips (exit_lpr_event_id) WHERE exit_lpr_event_id IS NOT NULL;`,
+	`CREATE TABLE IF NOT EXISTS idempotency_keys (
+		scope VARCHAR(128) NOT NULL,
+		key VARCHAR(255) NOT NULL,
+		method VARCHAR(10) NOT NULL,
+		path TEXT NOT NULL,
+		request_hash VARCHAR(64) NOT NULL,
+		status_code INTEGER,
+		response_body BYTEA,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		completed_at TIMESTAMPTZ,
+		PRIMARY KEY (scope, key)
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys (created_at);`,
+	`ALTER TABLE trips ADD COLUMN IF NOT EXISTS violation_reason TEXT;`,
+	`ALTER TABLE trips ADD COLUMN IF NOT EXISTS reported_status trip_status;`,
+	`CREATE TABLE IF NOT EXISTS contract_settings (
+		contract_id UUID PRIMARY KEY,
+		max_trip_volume_m3 DOUBLE PRECISION,
+		min_trip_volume_m3 DOUBLE PRECISION,
+		max_exit_volume_m3 DOUBLE PRECISION,
+		volume_limit_m3 DOUBLE PRECISION,
+		require_polygon_access BOOLEAN,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	// Таблица создается вместе с переносом нарушений из trips.status для рейсов, созданных
+	// до появления trip_violations: перенос выполняется один раз и не восстанавливает
+	// нарушения, удаленные позже
+	`DO $$
+	BEGIN
+		IF to_regclass('trip_violations') IS NULL THEN
+			CREATE TABLE trip_violations (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+				type trip_status NOT NULL,
+				severity VARCHAR(16) NOT NULL,
+				reason TEXT NOT NULL,
+				detected_by VARCHAR(64) NOT NULL,
+				resolved_by_appeal_id UUID REFERENCES appeals(id) ON DELETE SET NULL,
+				resolved_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				UNIQUE (trip_id, type)
+			);
+
+			INSERT INTO trip_violations (trip_id, type, severity, reason, detected_by)
+			SELECT id, status, 'HIGH', COALESCE(violation_reason, status::text), 'migration'
+			FROM trips
+			WHERE status <> 'OK';
+		END IF;
+	END
+	$$;`,
+	`CREATE INDEX IF NOT EXISTS idx_trip_violations_unresolved ON trip_violations (trip_id) WHERE resolved_at IS NULL;`,
+	`ALTER TABLE appeals ADD COLUMN IF NOT EXISTS trip_violation_id UUID REFERENCES trip_violations(id) ON DELETE SET NULL;`,
+	`CREATE INDEX IF NOT EXISTS idx_appeals_trip_violation_id ON appeals (trip_violation_id);`,
+	`CREATE TABLE IF NOT EXISTS trip_reconciliation_log (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+		action VARCHAR(32) NOT NULL,
+		ticket_id UUID,
+		ticket_assignment_id UUID,
+		previous_status trip_status NOT NULL,
+		new_status trip_status NOT NULL,
+		details JSONB,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_trip_reconciliation_log_trip_id ON trip_reconciliation_log (trip_id);`,
+	`CREATE INDEX IF NOT EXISTS idx_trips_unassigned_entry_at ON trips (entry_at) WHERE ticket_id IS NULL;`,
+	`ALTER TABLE trips ADD COLUMN IF NOT EXISTS volume_calculation_status VARCHAR(16);`,
+	`ALTER TABLE trips ADD COLUMN IF NOT EXISTS volume_calculation_error TEXT;`,
+	`UPDATE trips SET volume_calculation_status = 'CALCULATED'
+		WHERE auto_created = TRUE AND total_volume_m3 IS NOT NULL AND volume_calculation_status IS NULL;`,
+	`CREATE TABLE IF NOT EXISTS jobs (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		type VARCHAR(64) NOT NULL,
+		payload JSONB NOT NULL,
+		dedup_key VARCHAR(128),
+		status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		locked_at TIMESTAMPTZ,
+		last_error TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_jobs_pending_run_at ON jobs (run_at) WHERE status = 'PENDING';`,
+	`CREATE INDEX IF NOT EXISTS idx_jobs_running_locked_at ON jobs (locked_at) WHERE status = 'RUNNING';`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_active_dedup_key ON jobs (type, dedup_key)
+		WHERE dedup_key IS NOT NULL AND status IN ('PENDING', 'RUNNING');`,
+	`ALTER TABLE trips ADD COLUMN IF NOT EXISTS accepted_volume_m3 DOUBLE PRECISION;`,
+	`ALTER TABLE trips ADD COLUMN IF NOT EXISTS volume_strategy VARCHAR(32);`,
+	`ALTER TABLE contract_settings ADD COLUMN IF NOT EXISTS volume_strategy VARCHAR(32);`,
+	`UPDATE trips SET
+		accepted_volume_m3 = CASE
+			WHEN detected_volume_entry IS NOT NULL THEN GREATEST(detected_volume_entry - COALESCE(detected_volume_exit, 0), 0)
+			ELSE GREATEST(total_volume_m3, 0)
+		END,
+		volume_strategy = CASE
+			WHEN detected_volume_entry IS NOT NULL THEN 'NET_ENTRY_EXIT'
+			ELSE 'ANPR_ENTRY_SUM'
+		END
+	WHERE volume_strategy IS NULL AND (detected_volume_entry IS NOT NULL OR total_volume_m3 IS NOT NULL);`,
+	`CREATE TABLE IF NOT EXISTS ticket_status_history (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+		from_status ticket_status,
+		to_status ticket_status NOT NULL,
+		event VARCHAR(32) NOT NULL,
+		actor_user_id UUID,
+		actor_org_id UUID,
+		actor_role VARCHAR(64),
+		reason TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_ticket_status_history_ticket_id ON ticket_status_history (ticket_id, created_at);`,
+	`CREATE TABLE IF NOT EXISTS ticket_change_history (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+		field VARCHAR(64) NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		ticket_status ticket_status NOT NULL,
+		actor_user_id UUID NOT NULL,
+		actor_org_id UUID NOT NULL,
+		actor_role VARCHAR(64) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_ticket_change_history_ticket_id ON ticket_change_history (ticket_id, created_at);`,
+	`CREATE TABLE IF NOT EXISTS ticket_schedules (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		created_by_org_id UUID NOT NULL,
+		cleaning_area_id UUID NOT NULL,
+		contractor_id UUID NOT NULL,
+		contract_id UUID NOT NULL,
+		rrule TEXT NOT NULL,
+		timezone VARCHAR(64) NOT NULL,
+		start_time VARCHAR(5) NOT NULL,
+		end_time VARCHAR(5) NOT NULL,
+		starts_on DATE NOT NULL,
+		ends_on DATE,
+		skip_holidays BOOLEAN NOT NULL DEFAULT TRUE,
+		description TEXT,
+		status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE',
+		materialized_until TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_ticket_schedules_org ON ticket_schedules (created_by_org_id);`,
+	`CREATE INDEX IF NOT EXISTS idx_ticket_schedules_active ON ticket_schedules (status) WHERE status = 'ACTIVE';`,
+	`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS schedule_id UUID REFERENCES ticket_schedules(id) ON DELETE SET NULL;`,
+	`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS schedule_date DATE;`,
+	// Одна дата серии - один тикет, даже если планировщик запущен в нескольких экземплярах или перезапущен
+	`CREATE UNIQUE INDEX IF NOT EXISTS uq_tickets_schedule_date ON tickets (schedule_id, schedule_date) WHERE schedule_id IS NOT NULL;`,
+	`CREATE TABLE IF NOT EXISTS holidays (
+		org_id UUID NOT NULL,
+		date DATE NOT NULL,
+		name TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (org_id, date)
+	);`,
+	// Пересечения тикетов одного участка: btree_gist нужен для uuid в GiST-ограничении
+	`CREATE EXTENSION IF NOT EXISTS btree_gist;`,
+	`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS allow_overlap BOOLEAN NOT NULL DEFAULT FALSE;`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'ex_tickets_area_overlap') THEN
+			-- Уже существующие пересечения помечаются как допущенные, иначе ограничение не создать:
+			-- флаг получает более поздний из пересекающихся тикетов
+			UPDATE tickets t SET allow_overlap = TRUE
+			WHERE t.status IN ('PLANNED', 'IN_PROGRESS')
+				AND NOT t.allow_overlap
+				AND EXISTS (
+					SELECT 1 FROM tickets o
+					WHERE o.cleaning_area_id = t.cleaning_area_id
+						AND o.id <> t.id
+						AND o.status IN ('PLANNED', 'IN_PROGRESS')
+						AND NOT o.allow_overlap
+						AND tstzrange(o.planned_start_at, o.planned_end_at) && tstzrange(t.planned_start_at, t.planned_end_at)
+						AND (o.created_at, o.id) < (t.created_at, t.id)
+				);
+
+			ALTER TABLE tickets ADD CONSTRAINT ex_tickets_area_overlap
+				EXCLUDE USING gist (
+					cleaning_area_id WITH =,
+					tstzrange(planned_start_at, planned_end_at) WITH &&
+				)
+				WHERE (status IN ('PLANNED', 'IN_PROGRESS') AND NOT allow_overlap);
+		END IF;
+	END
+	$$;`,
+	// Рейсы назначения: за смену водитель делает несколько рейсов, по каждому - свой trip.
+	// Назначения, начатые до появления рейсов, получают рейс №1 из trip_started_at/trip_finished_at;
+	// перенос выполняется один раз, при создании таблицы
+	`DO $$
+	BEGIN
+		IF to_regclass('assignment_rounds') IS NULL THEN
+			CREATE TABLE assignment_rounds (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				assignment_id UUID NOT NULL REFERENCES ticket_assignments(id) ON DELETE CASCADE,
+				number INT NOT NULL,
+				started_at TIMESTAMPTZ NOT NULL,
+				finished_at TIMESTAMPTZ,
+				trip_id UUID REFERENCES trips(id) ON DELETE SET NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				UNIQUE (assignment_id, number)
+			);
+
+			INSERT INTO assignment_rounds (assignment_id, number, started_at, finished_at, trip_id)
+			SELECT ta.id, 1, ta.trip_started_at, ta.trip_finished_at,
+				(SELECT tr.id FROM trips tr WHERE tr.ticket_assignment_id = ta.id ORDER BY tr.created_at DESC LIMIT 1)
+			FROM ticket_assignments ta
+			WHERE ta.trip_started_at IS NOT NULL;
+		END IF;
+	END
+	$$;`,
+	// Открытым может быть только один рейс назначения
+	`CREATE UNIQUE INDEX IF NOT EXISTS uq_assignment_rounds_open ON assignment_rounds (assignment_id) WHERE finished_at IS NULL;`,
+	// Паузы и завершение смены водителя
+	`ALTER TYPE driver_mark_status ADD VALUE IF NOT EXISTS 'PAUSED';`,
+	`ALTER TYPE driver_mark_status ADD VALUE IF NOT EXISTS 'SHIFT_ENDED';`,
+	`ALTER TABLE ticket_assignments ADD COLUMN IF NOT EXISTS paused_at TIMESTAMPTZ;`,
+	`ALTER TABLE ticket_assignments ADD COLUMN IF NOT EXISTS shift_ended_at TIMESTAMPTZ;`,
+	`CREATE TABLE IF NOT EXISTS driver_status_events (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		assignment_id UUID NOT NULL REFERENCES ticket_assignments(id) ON DELETE CASCADE,
+		driver_id UUID NOT NULL,
+		event VARCHAR(32) NOT NULL,
+		from_status driver_mark_status NOT NULL,
+		to_status driver_mark_status NOT NULL,
+		pause_reason VARCHAR(32),
+		old_vehicle_id UUID,
+		new_vehicle_id UUID,
+		comment TEXT,
+		actor_user_id UUID NOT NULL,
+		actor_role VARCHAR(64) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS idx_driver_status_events_assignment_id ON driver_status_events (assignment_id, created_at);`,
+	// Переназначение: новое назначение ссылается на снятое, которое оно заменило
+	`ALTER TABLE ticket_assignments ADD COLUMN IF NOT EXISTS previous_assignment_id UUID REFERENCES ticket_assignments(id);`,
+	`CREATE INDEX IF NOT EXISTS idx_ticket_assignments_previous_assignment_id ON ticket_assignments (previous_assignment_id);`,
+	// Даты серий, тикеты которых KGU удалил вручную: планировщик их больше не создает
+	`CREATE TABLE IF NOT EXISTS ticket_schedule_exceptions (
+		schedule_id UUID NOT NULL REFERENCES ticket_schedules(id) ON DELETE CASCADE,
+		date DATE NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (schedule_id, date)
+	);`,
 	`CREATE OR REPLACE FUNCTION set_updated_at()
 	RETURNS TRIGGER AS $$
 	BEGIN
@@ -430,6 +692,56 @@ var migrationStatements = []string{
 		END IF;
 	END
 	$$;`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'trg_contract_settings_updated_at') THEN
+			CREATE TRIGGER trg_contract_settings_updated_at
+				BEFORE UPDATE ON contract_settings
+				FOR EACH ROW
+				EXECUTE PROCEDURE set_updated_at();
+		END IF;
+	END
+	$$;`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'trg_trip_violations_updated_at') THEN
+			CREATE TRIGGER trg_trip_violations_updated_at
+				BEFORE UPDATE ON trip_violations
+				FOR EACH ROW
+				EXECUTE PROCEDURE set_updated_at();
+		END IF;
+	END
+	$$;`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'trg_jobs_updated_at') THEN
+			CREATE TRIGGER trg_jobs_updated_at
+				BEFORE UPDATE ON jobs
+				FOR EACH ROW
+				EXECUTE PROCEDURE set_updated_at();
+		END IF;
+	END
+	$$;`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'trg_ticket_schedules_updated_at') THEN
+			CREATE TRIGGER trg_ticket_schedules_updated_at
+				BEFORE UPDATE ON ticket_schedules
+				FOR EACH ROW
+				EXECUTE PROCEDURE set_updated_at();
+		END IF;
+	END
+	$$;`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'trg_assignment_rounds_updated_at') THEN
+			CREATE TRIGGER trg_assignment_rounds_updated_at
+				BEFORE UPDATE ON assignment_rounds
+				FOR EACH ROW
+				EXECUTE PROCEDURE set_updated_at();
+		END IF;
+	END
+	$$;`,
 }
 
 func runMigrations(db *gorm.DB) error {
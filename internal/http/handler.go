@@ -2,6 +2,7 @@ package http
 
 import (
 	"errors"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -21,6 +22,8 @@ type Handler struct {
 	assignmentService *service.AssignmentService
 	tripService       *service.TripService
 	appealService     *service.AppealService
+	eventService      *service.EventService
+	scheduleService   *service.ScheduleService
 	log               zerolog.Logger
 }
 
@@ -29,6 +32,8 @@ func NewHandler(
 	assignmentService *service.AssignmentService,
 	tripService *service.TripService,
 	appealService *service.AppealService,
+	eventService *service.EventService,
+	scheduleService *service.ScheduleService,
 	log zerolog.Logger,
 ) *Handler {
 	return &Handler{
@@ -36,18 +41,41 @@ func NewHandler(
 		assignmentService: assignmentService,
 		tripService:       tripService,
 		appealService:     appealService,
+		eventService:      eventService,
+		scheduleService:   scheduleService,
 		log:               log,
 	}
 }
 
-func (h *Handler) Register(r *gin.Engine, authMiddleware gin.HandlerFunc) {
+func (h *Handler) Register(r *gin.Engine, authMiddleware, serviceAuthMiddleware, idempotencyMiddleware, internalIdempotencyMiddleware, webhookMiddleware gin.HandlerFunc) {
+	// Внутренние маршруты для LPR/volume сервисов - авторизация по сервисному токену, не по JWT
+	internal := r.Group("/internal")
+	internal.Use(serviceAuthMiddleware, internalIdempotencyMiddleware)
+	{
+		internal.POST("/trips", h.ingestTrip)
+		internal.POST("/trips/batch", h.ingestTripBatch)
+		internal.POST("/events/lpr", h.ingestLprEvent)
+		internal.POST("/events/volume", h.ingestVolumeEvent)
+	}
+
+	// Webhook ANPR - авторизация по HMAC-подписи тела запроса
+	webhooks := r.Group("/webhooks")
+	webhooks.Use(webhookMiddleware)
+	{
+		webhooks.POST("/anpr/events", h.receiveANPREvents)
+	}
+
 	protected := r.Group("/")
-	protected.Use(authMiddleware)
+	protected.Use(authMiddleware, idempotencyMiddleware)
 
 	akimat := protected.Group("/akimat")
 	{
 		akimat.GET("/tickets", h.listTickets)
 		akimat.GET("/tickets/:id", h.getTicketDetails)
+		akimat.GET("/tickets/:id/history", h.getTicketHistory)
+		akimat.GET("/tickets/:id/changes", h.getTicketChanges)
+		akimat.GET("/tickets/:id/assignments/history", h.getAssignmentHistory)
+		akimat.POST("/volume/recalculate", h.recalculateVolume)
 	}
 
 	// KGU ZKH (TOO) - создание и управление тикетами
@@ -55,30 +83,66 @@ func (h *Handler) Register(r *gin.Engine, authMiddleware gin.HandlerFunc) {
 	{
 		kgu.GET("/tickets", h.listTickets)
 		kgu.POST("/tickets", h.createTicket)
+		kgu.POST("/tickets/import", h.importTickets)
 		kgu.GET("/tickets/:id", h.getTicketDetails)
+		kgu.GET("/tickets/:id/history", h.getTicketHistory)
+		kgu.GET("/tickets/:id/changes", h.getTicketChanges)
+		kgu.GET("/tickets/:id/assignments/history", h.getAssignmentHistory)
 		kgu.PUT("/tickets/:id/cancel", h.cancelTicket)
 		kgu.PUT("/tickets/:id/close", h.closeTicket)
+		kgu.PUT("/tickets/:id/reopen", h.reopenTicket)
+		kgu.PATCH("/tickets/:id", h.updateTicket)
 		kgu.DELETE("/tickets/:id", h.deleteTicket)
+
+		// Расписания повторяющихся тикетов
+		kgu.POST("/schedules", h.createSchedule)
+		kgu.GET("/schedules", h.listSchedules)
+		kgu.GET("/schedules/:id", h.getSchedule)
+		kgu.PATCH("/schedules/:id", h.updateSchedule)
+		kgu.PUT("/schedules/:id/pause", h.pauseSchedule)
+		kgu.PUT("/schedules/:id/resume", h.resumeSchedule)
+		kgu.PUT("/schedules/:id/end", h.endSchedule)
+		kgu.GET("/holidays", h.listHolidays)
+		kgu.PUT("/holidays/:date", h.putHoliday)
+		kgu.DELETE("/holidays/:date", h.deleteHoliday)
+
+		kgu.PUT("/appeals/:id/status", h.updateAppealStatus)
+
+		kgu.POST("/volume/recalculate", h.recalculateVolume)
 	}
 
 	contractor := protected.Group("/contractor")
 	{
 		contractor.GET("/tickets", h.listTickets)
 		contractor.GET("/tickets/:id", h.getTicketDetails)
+		contractor.GET("/tickets/:id/history", h.getTicketHistory)
+		contractor.GET("/tickets/:id/changes", h.getTicketChanges)
 		contractor.PUT("/tickets/:id/complete", h.completeTicket)
 		// Назначения
 		contractor.POST("/tickets/:id/assignments", h.createAssignment)
 		contractor.DELETE("/assignments/:id", h.deleteAssignment)
 		contractor.GET("/tickets/:id/assignments", h.listAssignments)
+		contractor.GET("/tickets/:id/assignments/history", h.getAssignmentHistory)
+		contractor.POST("/assignments/:id/reassign", h.reassignAssignment)
+		contractor.GET("/availability", h.getAvailability)
+		contractor.PUT("/assignments/:id/vehicle", h.changeAssignmentVehicle)
+		contractor.GET("/assignments/:id/status-events", h.listAssignmentStatusEvents)
 	}
 
 	driver := protected.Group("/driver")
 	{
 		driver.GET("/tickets", h.listTickets)
 		driver.GET("/tickets/:id", h.getTicketDetails)
+		driver.GET("/tickets/:id/history", h.getTicketHistory)
+		driver.GET("/tickets/:id/changes", h.getTicketChanges)
+		driver.GET("/tickets/:id/assignments/history", h.getAssignmentHistory)
 		// Обновление статуса водителя
 		driver.PUT("/assignments/:id/mark-in-work", h.markAssignmentInWork)
 		driver.PUT("/assignments/:id/mark-completed", h.markAssignmentCompleted)
+		driver.PUT("/assignments/:id/pause", h.pauseAssignment)
+		driver.PUT("/assignments/:id/resume", h.resumeAssignment)
+		driver.PUT("/assignments/:id/end-shift", h.endAssignmentShift)
+		driver.GET("/assignments/:id/status-events", h.listAssignmentStatusEvents)
 		// Обжалования
 		driver.POST("/appeals", h.createAppeal)
 		driver.GET("/appeals", h.listMyAppeals)
@@ -108,6 +172,7 @@ func (h *Handler) createTicket(c *gin.Context) {
 		PlannedStartAt string `json:"planned_start_at" binding:"required"`
 		PlannedEndAt   string `json:"planned_end_at" binding:"required"`
 		Description    string `json:"description"`
+		AllowOverlap   bool   `json:"allow_overlap"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -122,6 +187,7 @@ func (h *Handler) createTicket(c *gin.Context) {
 		PlannedStartAt: req.PlannedStartAt,
 		PlannedEndAt:   req.PlannedEndAt,
 		Description:    req.Description,
+		AllowOverlap:   req.AllowOverlap,
 	})
 	if err != nil {
 		h.handleError(c, err)
@@ -131,6 +197,51 @@ func (h *Handler) createTicket(c *gin.Context) {
 	c.JSON(http.StatusCreated, successResponse(ticket))
 }
 
+func (h *Handler) updateTicket(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid ticket id"))
+		return
+	}
+
+	var req struct {
+		CleaningAreaID *string `json:"cleaning_area_id"`
+		ContractorID   *string `json:"contractor_id"`
+		ContractID     *string `json:"contract_id"`
+		PlannedStartAt *string `json:"planned_start_at"`
+		PlannedEndAt   *string `json:"planned_end_at"`
+		Description    *string `json:"description"`
+		AllowOverlap   *bool   `json:"allow_overlap"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	ticket, err := h.ticketService.Update(c.Request.Context(), principal, id, service.UpdateTicketInput{
+		CleaningAreaID: req.CleaningAreaID,
+		ContractorID:   req.ContractorID,
+		ContractID:     req.ContractID,
+		PlannedStartAt: req.PlannedStartAt,
+		PlannedEndAt:   req.PlannedEndAt,
+		Description:    req.Description,
+		AllowOverlap:   req.AllowOverlap,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(ticket))
+}
+
 func (h *Handler) getTicketDetails(c *gin.Context) {
 	principal, ok := middleware.MustPrincipal(c)
 	if !ok {
@@ -183,6 +294,11 @@ func (h *Handler) listTickets(c *gin.Context) {
 		filter.ContractID = &contractID
 	}
 
+	scheduleID := strings.TrimSpace(c.Query("schedule_id"))
+	if scheduleID != "" {
+		filter.ScheduleID = &scheduleID
+	}
+
 	plannedStartFrom := strings.TrimSpace(c.Query("planned_start_from"))
 	if plannedStartFrom != "" {
 		filter.PlannedStartFrom = &plannedStartFrom
@@ -245,7 +361,12 @@ func (h *Handler) cancelTicket(c *gin.Context) {
 		return
 	}
 
-	if err := h.ticketService.Cancel(c.Request.Context(), principal, id); err != nil {
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	if err := h.ticketService.Cancel(c.Request.Context(), principal, id, reason); err != nil {
 		h.handleError(c, err)
 		return
 	}
@@ -266,7 +387,12 @@ func (h *Handler) closeTicket(c *gin.Context) {
 		return
 	}
 
-	if err := h.ticketService.Close(c.Request.Context(), principal, id); err != nil {
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	if err := h.ticketService.Close(c.Request.Context(), principal, id, reason); err != nil {
 		h.handleError(c, err)
 		return
 	}
@@ -274,6 +400,98 @@ func (h *Handler) closeTicket(c *gin.Context) {
 	c.JSON(http.StatusOK, successResponse(gin.H{"message": "ticket closed"}))
 }
 
+type reopenTicketRequest struct {
+	Reason       string `json:"reason" binding:"required"`
+	PlannedEndAt string `json:"planned_end_at"`
+}
+
+func (h *Handler) reopenTicket(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid ticket id"))
+		return
+	}
+
+	var req reopenTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	ticket, err := h.ticketService.Reopen(c.Request.Context(), principal, id, service.ReopenTicketInput{
+		Reason:       req.Reason,
+		PlannedEndAt: req.PlannedEndAt,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(ticket))
+}
+
+// bindReason читает необязательное тело {"reason": "..."} - причину смены статуса для истории
+func bindReason(c *gin.Context) (string, bool) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return "", false
+	}
+	return req.Reason, true
+}
+
+func (h *Handler) getTicketHistory(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid ticket id"))
+		return
+	}
+
+	history, err := h.ticketService.GetStatusHistory(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(history))
+}
+
+func (h *Handler) getTicketChanges(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid ticket id"))
+		return
+	}
+
+	changes, err := h.ticketService.GetChangeHistory(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(changes))
+}
+
 func (h *Handler) deleteTicket(c *gin.Context) {
 	principal, ok := middleware.MustPrincipal(c)
 	if !ok {
@@ -308,7 +526,12 @@ func (h *Handler) completeTicket(c *gin.Context) {
 		return
 	}
 
-	if err := h.ticketService.Complete(c.Request.Context(), principal, id); err != nil {
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	if err := h.ticketService.Complete(c.Request.Context(), principal, id, reason); err != nil {
 		h.handleError(c, err)
 		return
 	}
@@ -353,6 +576,35 @@ func (h *Handler) createAssignment(c *gin.Context) {
 	c.JSON(http.StatusCreated, successResponse(assignment))
 }
 
+func (h *Handler) getAvailability(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	splitIDs := func(raw string) []string {
+		if raw == "" {
+			return nil
+		}
+		return strings.Split(raw, ",")
+	}
+
+	result, err := h.assignmentService.Availability(c.Request.Context(), principal, service.AvailabilityInput{
+		TicketID:   c.Query("ticket_id"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+		DriverIDs:  splitIDs(c.Query("driver_ids")),
+		VehicleIDs: splitIDs(c.Query("vehicle_ids")),
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(result))
+}
+
 func (h *Handler) deleteAssignment(c *gin.Context) {
 	principal, ok := middleware.MustPrincipal(c)
 	if !ok {
@@ -396,6 +648,65 @@ func (h *Handler) listAssignments(c *gin.Context) {
 	c.JSON(http.StatusOK, successResponse(assignments))
 }
 
+func (h *Handler) getAssignmentHistory(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	ticketID := strings.TrimSpace(c.Param("id"))
+	if ticketID == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid ticket id"))
+		return
+	}
+
+	assignments, err := h.assignmentService.History(c.Request.Context(), principal, ticketID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(assignments))
+}
+
+func (h *Handler) reassignAssignment(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid assignment id"))
+		return
+	}
+
+	var req struct {
+		DriverID  string `json:"driver_id"`
+		VehicleID string `json:"vehicle_id"`
+		Comment   string `json:"comment"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	assignment, err := h.assignmentService.Reassign(c.Request.Context(), principal, id, service.ReassignInput{
+		DriverID:  req.DriverID,
+		VehicleID: req.VehicleID,
+		Comment:   req.Comment,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, successResponse(assignment))
+}
+
 func (h *Handler) markAssignmentInWork(c *gin.Context) {
 	principal, ok := middleware.MustPrincipal(c)
 	if !ok {
@@ -438,6 +749,139 @@ func (h *Handler) markAssignmentCompleted(c *gin.Context) {
 	c.JSON(http.StatusOK, successResponse(gin.H{"message": "marked as completed"}))
 }
 
+func (h *Handler) endAssignmentShift(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid assignment id"))
+		return
+	}
+
+	if err := h.assignmentService.EndShift(c.Request.Context(), principal, id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(gin.H{"message": "shift ended"}))
+}
+
+func (h *Handler) pauseAssignment(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid assignment id"))
+		return
+	}
+
+	var req struct {
+		Reason  string `json:"reason" binding:"required"`
+		Comment string `json:"comment"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	if err := h.assignmentService.Pause(c.Request.Context(), principal, id, service.PauseInput{
+		Reason:  req.Reason,
+		Comment: req.Comment,
+	}); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(gin.H{"message": "paused"}))
+}
+
+func (h *Handler) resumeAssignment(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid assignment id"))
+		return
+	}
+
+	if err := h.assignmentService.Resume(c.Request.Context(), principal, id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(gin.H{"message": "resumed"}))
+}
+
+func (h *Handler) changeAssignmentVehicle(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid assignment id"))
+		return
+	}
+
+	var req struct {
+		VehicleID string `json:"vehicle_id" binding:"required"`
+		Comment   string `json:"comment"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	assignment, err := h.assignmentService.ChangeVehicle(c.Request.Context(), principal, id, service.ChangeVehicleInput{
+		VehicleID: req.VehicleID,
+		Comment:   req.Comment,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(assignment))
+}
+
+func (h *Handler) listAssignmentStatusEvents(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid assignment id"))
+		return
+	}
+
+	events, err := h.assignmentService.StatusEvents(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(events))
+}
+
 // Appeal handlers
 func (h *Handler) createAppeal(c *gin.Context) {
 	principal, ok := middleware.MustPrincipal(c)
@@ -448,6 +892,7 @@ func (h *Handler) createAppeal(c *gin.Context) {
 
 	var req struct {
 		TripID           string `json:"trip_id" binding:"required"`
+		TripViolationID  string `json:"trip_violation_id"`
 		AppealReasonType string `json:"appeal_reason_type" binding:"required"`
 		Comment          string `json:"comment" binding:"required"`
 	}
@@ -459,6 +904,7 @@ func (h *Handler) createAppeal(c *gin.Context) {
 
 	appeal, err := h.appealService.Create(c.Request.Context(), principal, service.CreateAppealInput{
 		TripID:           req.TripID,
+		TripViolationID:  req.TripViolationID,
 		AppealReasonType: req.AppealReasonType,
 		Comment:          req.Comment,
 	})
@@ -514,6 +960,37 @@ func (h *Handler) getAppeal(c *gin.Context) {
 	c.JSON(http.StatusOK, successResponse(appeal))
 }
 
+func (h *Handler) updateAppealStatus(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid appeal id"))
+		return
+	}
+
+	var req struct {
+		Status        model.AppealStatus `json:"status" binding:"required"`
+		AdminResponse *string            `json:"admin_response"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	if err := h.appealService.UpdateStatus(c.Request.Context(), principal, id, req.Status, req.AdminResponse); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(gin.H{"message": "appeal status updated"}))
+}
+
 func (h *Handler) addAppealComment(c *gin.Context) {
 	principal, ok := middleware.MustPrincipal(c)
 	if !ok {
@@ -567,7 +1044,20 @@ func (h *Handler) getAppealComments(c *gin.Context) {
 }
 
 func (h *Handler) handleError(c *gin.Context, err error) {
+	var overlapErr *service.TicketOverlapError
+	var assignmentOverlapErr *service.AssignmentOverlapError
 	switch {
+	case errors.As(err, &overlapErr):
+		// Пересечение тикетов: клиент получает список тикетов, с которыми случился конфликт
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     err.Error(),
+			"conflicts": overlapErr.Overlaps,
+		})
+	case errors.As(err, &assignmentOverlapErr):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     err.Error(),
+			"conflicts": assignmentOverlapErr.Conflicts,
+		})
 	case errors.Is(err, service.ErrPermissionDenied):
 		c.JSON(http.StatusForbidden, errorResponse(err.Error()))
 	case errors.Is(err, service.ErrNotFound):
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthCheckTimeout - сколько ждать одну проверку /healthz
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck - проверка зависимости сервиса для /healthz
+type HealthCheck struct {
+	Name string
+	// Critical - без зависимости сервис не работает: ошибка переводит /healthz в 503.
+	// Ошибка некритичной проверки дает статус degraded.
+	Critical bool
+	// Check возвращает подробности для ответа и ошибку, если зависимость недоступна
+	Check func(ctx context.Context) (map[string]interface{}, error)
+}
+
+func healthHandler(checks []HealthCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := "ok"
+		code := http.StatusOK
+		results := make(gin.H, len(checks))
+
+		for _, check := range checks {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+			details, err := check.Check(ctx)
+			cancel()
+
+			result := gin.H{"status": "ok"}
+			for key, value := range details {
+				result[key] = value
+			}
+			if err != nil {
+				result["status"] = "error"
+				result["error"] = err.Error()
+				if check.Critical {
+					status = "unavailable"
+					code = http.StatusServiceUnavailable
+				} else if status == "ok" {
+					status = "degraded"
+				}
+			}
+			results[check.Name] = result
+		}
+
+		c.JSON(code, gin.H{"status": status, "checks": results})
+	}
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"ticket-service/internal/http/middleware"
+	"ticket-service/internal/importfile"
+	"ticket-service/internal/service"
+)
+
+// maxImportFileBytes ограничивает размер загружаемого файла импорта
+const maxImportFileBytes = 5 << 20
+
+// ticketImportColumns - обязательные колонки файла импорта (как поля POST /kgu/tickets)
+var ticketImportColumns = []string{"cleaning_area_id", "contractor_id", "contract_id", "planned_start_at", "planned_end_at"}
+
+func (h *Handler) importTickets(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	dryRun := false
+	if value := strings.TrimSpace(c.DefaultQuery("dry_run", c.PostForm("dry_run"))); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse("invalid dry_run"))
+			return
+		}
+		dryRun = parsed
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileBytes+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, errorResponse("file is too large"))
+			return
+		}
+		c.JSON(http.StatusBadRequest, errorResponse("multipart field \"file\" is required"))
+		return
+	}
+	if fileHeader.Size > maxImportFileBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, errorResponse("file is too large"))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	table, err := importfile.Read(fileHeader.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	var missing []string
+	for _, column := range ticketImportColumns {
+		if _, ok := table.Get(importfile.Row{}, column); !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, errorResponse("missing columns: "+strings.Join(missing, ", ")))
+		return
+	}
+
+	rows := make([]service.ImportTicketRowInput, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		value := func(column string) string {
+			v, _ := table.Get(row, column)
+			return v
+		}
+		// Необязательная колонка allow_overlap: пусто - пересечения запрещены
+		allowOverlap := false
+		if raw := strings.TrimSpace(value("allow_overlap")); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, errorResponse("row "+strconv.Itoa(row.Number)+": invalid allow_overlap"))
+				return
+			}
+			allowOverlap = parsed
+		}
+		rows = append(rows, service.ImportTicketRowInput{
+			Row: row.Number,
+			CreateTicketInput: service.CreateTicketInput{
+				CleaningAreaID: value("cleaning_area_id"),
+				ContractorID:   value("contractor_id"),
+				ContractID:     value("contract_id"),
+				PlannedStartAt: value("planned_start_at"),
+				PlannedEndAt:   value("planned_end_at"),
+				Description:    value("description"),
+				AllowOverlap:   allowOverlap,
+			},
+		})
+	}
+
+	result, err := h.ticketService.ImportTickets(c.Request.Context(), principal, rows, dryRun)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created > 0 {
+		status = http.StatusCreated
+	}
+	c.JSON(status, successResponse(result))
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/service"
+)
+
+// tripRequest - рейс, переданный LPR/volume сервисом
+type tripRequest struct {
+	ExternalID          *string          `json:"external_id"`
+	TicketID            *string          `json:"ticket_id"`
+	TicketAssignmentID  *string          `json:"ticket_assignment_id"`
+	DriverID            *string          `json:"driver_id"`
+	VehicleID           *string          `json:"vehicle_id"`
+	CameraID            *string          `json:"camera_id"`
+	PolygonID           *string          `json:"polygon_id"`
+	VehiclePlateNumber  string           `json:"vehicle_plate_number"`
+	DetectedPlateNumber string           `json:"detected_plate_number"`
+	EntryLprEventID     *string          `json:"entry_lpr_event_id"`
+	ExitLprEventID      *string          `json:"exit_lpr_event_id"`
+	EntryVolumeEventID  *string          `json:"entry_volume_event_id"`
+	ExitVolumeEventID   *string          `json:"exit_volume_event_id"`
+	DetectedVolumeEntry *float64         `json:"detected_volume_entry"`
+	DetectedVolumeExit  *float64         `json:"detected_volume_exit"`
+	EntryAt             string           `json:"entry_at" binding:"required"`
+	ExitAt              *string          `json:"exit_at"`
+	Status              model.TripStatus `json:"status"`
+}
+
+func (r tripRequest) toInput() service.CreateTripInput {
+	status := r.Status
+	if status == "" {
+		status = model.TripStatusOK
+	}
+
+	return service.CreateTripInput{
+		ExternalID:          r.ExternalID,
+		TicketID:            r.TicketID,
+		TicketAssignmentID:  r.TicketAssignmentID,
+		DriverID:            r.DriverID,
+		VehicleID:           r.VehicleID,
+		CameraID:            r.CameraID,
+		PolygonID:           r.PolygonID,
+		VehiclePlateNumber:  r.VehiclePlateNumber,
+		DetectedPlateNumber: r.DetectedPlateNumber,
+		EntryLprEventID:     r.EntryLprEventID,
+		ExitLprEventID:      r.ExitLprEventID,
+		EntryVolumeEventID:  r.EntryVolumeEventID,
+		ExitVolumeEventID:   r.ExitVolumeEventID,
+		DetectedVolumeEntry: r.DetectedVolumeEntry,
+		DetectedVolumeExit:  r.DetectedVolumeExit,
+		EntryAt:             r.EntryAt,
+		ExitAt:              r.ExitAt,
+		Status:              status,
+	}
+}
+
+func (h *Handler) ingestTrip(c *gin.Context) {
+	var req tripRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	result, err := h.tripService.Ingest(c.Request.Context(), req.toInput())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, successResponse(result))
+}
+
+func (h *Handler) ingestTripBatch(c *gin.Context) {
+	var req struct {
+		Trips []tripRequest `json:"trips" binding:"required,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	inputs := make([]service.CreateTripInput, 0, len(req.Trips))
+	for _, t := range req.Trips {
+		inputs = append(inputs, t.toInput())
+	}
+
+	results, err := h.tripService.IngestBatch(c.Request.Context(), inputs)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(gin.H{"results": results}))
+}
+
+func (h *Handler) ingestLprEvent(c *gin.Context) {
+	var req struct {
+		ExternalID  *string  `json:"external_id"`
+		CameraID    string   `json:"camera_id" binding:"required"`
+		PolygonID   *string  `json:"polygon_id"`
+		PlateNumber string   `json:"plate_number" binding:"required"`
+		DetectedAt  string   `json:"detected_at" binding:"required"`
+		Direction   string   `json:"direction" binding:"required"`
+		Confidence  *float64 `json:"confidence"`
+		PhotoURL    *string  `json:"photo_url"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	result, err := h.eventService.IngestLprEvent(c.Request.Context(), service.IngestLprEventInput{
+		ExternalID:  req.ExternalID,
+		CameraID:    req.CameraID,
+		PolygonID:   req.PolygonID,
+		PlateNumber: req.PlateNumber,
+		DetectedAt:  req.DetectedAt,
+		Direction:   req.Direction,
+		Confidence:  req.Confidence,
+		PhotoURL:    req.PhotoURL,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, successResponse(result))
+}
+
+func (h *Handler) ingestVolumeEvent(c *gin.Context) {
+	var req struct {
+		ExternalID     *string  `json:"external_id"`
+		CameraID       string   `json:"camera_id" binding:"required"`
+		PolygonID      *string  `json:"polygon_id"`
+		DetectedVolume *float64 `json:"detected_volume" binding:"required"`
+		DetectedAt     string   `json:"detected_at" binding:"required"`
+		Direction      string   `json:"direction" binding:"required"`
+		PhotoURL       *string  `json:"photo_url"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	result, err := h.eventService.IngestVolumeEvent(c.Request.Context(), service.IngestVolumeEventInput{
+		ExternalID:     req.ExternalID,
+		CameraID:       req.CameraID,
+		PolygonID:      req.PolygonID,
+		DetectedVolume: *req.DetectedVolume,
+		DetectedAt:     req.DetectedAt,
+		Direction:      req.Direction,
+		PhotoURL:       req.PhotoURL,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, successResponse(result))
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyKeyTTL         = 24 * time.Hour
+	serviceIdempotencyScope   = "service"
+	anonymousIdempotencyScope = "anonymous"
+	// maxIdempotentBodyBytes ограничивает тело, которое читается целиком для расчета хэша;
+	// должен покрывать файл импорта тикетов (5 МБ) с multipart-обвязкой
+	maxIdempotentBodyBytes = 8 << 20
+)
+
+// Idempotency повторяет сохраненный ответ для POST-запросов с заголовком Idempotency-Key.
+// Ключ действует в рамках пользователя (или сервисного токена) и привязан к методу, пути и телу запроса.
+// Ответы 5xx не сохраняются, чтобы запрос можно было повторить.
+//
+// upstreamIDFields - поля JSON-тела с идентификатором события вышестоящей системы (например, external_id).
+// Если заголовка нет, ключом служит первое заполненное из этих полей: повторная доставка того же
+// события получает исходный ответ, а не текущее состояние рейса. Для таких ключей сохраняются только
+// успешные ответы, а запрос с тем же идентификатором, но другим телом обрабатывается заново.
+func Idempotency(repo *repository.IdempotencyRepository, upstreamIDFields ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || (key == "" && len(upstreamIDFields) == 0) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body is too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		upstream := false
+		if key == "" {
+			key = upstreamIdempotencyKey(c.Request.URL.Path, body, upstreamIDFields)
+			if key == "" {
+				c.Next()
+				return
+			}
+			upstream = true
+		}
+
+		scope := idempotencyScope(c)
+		hash := requestHash(c.Request.Method, c.Request.URL.Path, body)
+		// Ответ сохраняется даже если клиент отключился, не дождавшись его
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		reserved, existing, err := repo.Reserve(ctx, &model.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: hash,
+		}, idempotencyKeyTTL)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+
+		if !reserved {
+			switch {
+			case existing.RequestHash != hash && upstream:
+				// Событие с тем же идентификатором, но другими данными - дедупликацию выполнит сервис
+				c.Next()
+			case existing.RequestHash != hash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was used with a different request"})
+			case existing.StatusCode == nil:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this idempotency key is still being processed"})
+			default:
+				c.Header(idempotentReplayedHeader, "true")
+				c.Data(*existing.StatusCode, "application/json; charset=utf-8", existing.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || (upstream && status >= http.StatusMultipleChoices) {
+			_ = repo.Release(ctx, scope, key)
+			return
+		}
+		_ = repo.Complete(ctx, scope, key, status, writer.body.Bytes())
+	}
+}
+
+// upstreamIdempotencyKey строит ключ из первого заполненного строкового поля fields JSON-тела.
+// Ключ включает путь, чтобы один идентификатор на разных маршрутах не пересекался.
+// Пустая строка - тело не JSON-объект, поля не заполнены или ключ слишком длинный.
+func upstreamIdempotencyKey(path string, body []byte, fields []string) string {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(body, &values); err != nil {
+		return ""
+	}
+	for _, field := range fields {
+		raw, ok := values[field]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil || value == "" {
+			continue
+		}
+		key := path + ":" + field + "=" + value
+		if len(key) > maxIdempotencyKeyLength {
+			return ""
+		}
+		return key
+	}
+	return ""
+}
+
+func idempotencyScope(c *gin.Context) string {
+	if principal, ok := MustPrincipal(c); ok {
+		return principal.UserID.String()
+	}
+	if c.GetHeader(internalTokenHeader) != "" {
+		return serviceIdempotencyScope
+	}
+	return anonymousIdempotencyScope
+}
+
+func requestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingWriter дублирует тело ответа в буфер, чтобы сохранить его для повторов
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const internalTokenHeader = "X-Internal-Token"
+
+// ServiceAuth защищает внутренние маршруты, которые вызываются другими сервисами
+// (LPR/volume камеры, ANPR). Проверяет заголовок X-Internal-Token и не пересекается с JWT пользователей.
+// Если токен не настроен, все запросы отклоняются.
+func ServiceAuth(token string) gin.HandlerFunc {
+	expected := []byte(token)
+
+	return func(c *gin.Context) {
+		if len(expected) == 0 {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "internal api is not configured"})
+			return
+		}
+
+		provided := c.GetHeader(internalTokenHeader)
+		if provided == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "internal token missing"})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(provided), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid internal token"})
+			return
+		}
+
+		c.Next()
+	}
+}
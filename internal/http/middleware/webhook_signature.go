@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	signatureHeader = "X-Signature"
+	timestampHeader = "X-Timestamp"
+	signaturePrefix = "sha256="
+	// maxWebhookBodyBytes - максимальный размер тела webhook
+	maxWebhookBodyBytes = 5 << 20
+)
+
+// WebhookSignature проверяет подпись webhook от ANPR:
+//
+//	X-Timestamp: <unix-время в секундах>
+//	X-Signature: sha256=<hex(HMAC-SHA256(secret, X-Timestamp + "." + тело))>
+//
+// Запросы с временем отправки вне окна tolerance отклоняются (защита от повторной отправки
+// перехваченного запроса). Если секрет не настроен, все запросы отклоняются.
+func WebhookSignature(secret string, tolerance time.Duration) gin.HandlerFunc {
+	key := []byte(secret)
+
+	return func(c *gin.Context) {
+		if len(key) == 0 {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "webhook is not configured"})
+			return
+		}
+
+		rawTimestamp := c.GetHeader(timestampHeader)
+		rawSignature := c.GetHeader(signatureHeader)
+		if rawTimestamp == "" || rawSignature == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "signature missing"})
+			return
+		}
+
+		seconds, err := strconv.ParseInt(rawTimestamp, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid timestamp"})
+			return
+		}
+		if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "timestamp is outside the allowed window"})
+			return
+		}
+
+		signature, err := hex.DecodeString(strings.TrimPrefix(rawSignature, signaturePrefix))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body is too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !hmac.Equal(signature, webhookSignature(key, rawTimestamp, body)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func webhookSignature(key []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
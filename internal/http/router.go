@@ -1,14 +1,13 @@
 package http
 
 import (
-	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(handler *Handler, authMiddleware gin.HandlerFunc, env string) *gin.Engine {
+func NewRouter(handler *Handler, authMiddleware, serviceAuthMiddleware, idempotencyMiddleware, internalIdempotencyMiddleware, webhookMiddleware gin.HandlerFunc, env string, healthChecks []HealthCheck) *gin.Engine {
 	if env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -23,11 +22,9 @@ func NewRouter(handler *Handler, authMiddleware gin.HandlerFunc, env string) *gi
 		MaxAge:          12 * time.Hour,
 	}))
 
-	router.GET("/healthz", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	router.GET("/healthz", healthHandler(healthChecks))
 
-	handler.Register(router, authMiddleware)
+	handler.Register(router, authMiddleware, serviceAuthMiddleware, idempotencyMiddleware, internalIdempotencyMiddleware, webhookMiddleware)
 
 	return router
 }
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"ticket-service/internal/http/middleware"
+	"ticket-service/internal/model"
+	"ticket-service/internal/service"
+)
+
+type createScheduleRequest struct {
+	CleaningAreaID string `json:"cleaning_area_id" binding:"required"`
+	ContractorID   string `json:"contractor_id" binding:"required"`
+	ContractID     string `json:"contract_id" binding:"required"`
+	RRule          string `json:"rrule" binding:"required"`
+	Timezone       string `json:"timezone"`
+	StartTime      string `json:"start_time" binding:"required"`
+	EndTime        string `json:"end_time" binding:"required"`
+	StartsOn       string `json:"starts_on" binding:"required"`
+	EndsOn         string `json:"ends_on"`
+	SkipHolidays   *bool  `json:"skip_holidays"`
+	Description    string `json:"description"`
+}
+
+func (h *Handler) createSchedule(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	var req createScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	schedule, err := h.scheduleService.Create(c.Request.Context(), principal, service.CreateScheduleInput{
+		CleaningAreaID: req.CleaningAreaID,
+		ContractorID:   req.ContractorID,
+		ContractID:     req.ContractID,
+		RRule:          req.RRule,
+		Timezone:       req.Timezone,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		StartsOn:       req.StartsOn,
+		EndsOn:         req.EndsOn,
+		SkipHolidays:   req.SkipHolidays,
+		Description:    req.Description,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, successResponse(schedule))
+}
+
+func (h *Handler) listSchedules(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	schedules, err := h.scheduleService.List(c.Request.Context(), principal, strings.TrimSpace(c.Query("status")))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(schedules))
+}
+
+func (h *Handler) getSchedule(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid schedule id"))
+		return
+	}
+
+	schedule, err := h.scheduleService.Get(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(schedule))
+}
+
+type updateScheduleRequest struct {
+	CleaningAreaID *string `json:"cleaning_area_id"`
+	ContractorID   *string `json:"contractor_id"`
+	ContractID     *string `json:"contract_id"`
+	RRule          *string `json:"rrule"`
+	Timezone       *string `json:"timezone"`
+	StartTime      *string `json:"start_time"`
+	EndTime        *string `json:"end_time"`
+	StartsOn       *string `json:"starts_on"`
+	EndsOn         *string `json:"ends_on"`
+	SkipHolidays   *bool   `json:"skip_holidays"`
+	Description    *string `json:"description"`
+}
+
+func (h *Handler) updateSchedule(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid schedule id"))
+		return
+	}
+
+	var req updateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	schedule, err := h.scheduleService.Update(c.Request.Context(), principal, id, service.UpdateScheduleInput{
+		CleaningAreaID: req.CleaningAreaID,
+		ContractorID:   req.ContractorID,
+		ContractID:     req.ContractID,
+		RRule:          req.RRule,
+		Timezone:       req.Timezone,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		StartsOn:       req.StartsOn,
+		EndsOn:         req.EndsOn,
+		SkipHolidays:   req.SkipHolidays,
+		Description:    req.Description,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(schedule))
+}
+
+func (h *Handler) pauseSchedule(c *gin.Context) {
+	h.changeScheduleStatus(c, h.scheduleService.Pause)
+}
+
+func (h *Handler) resumeSchedule(c *gin.Context) {
+	h.changeScheduleStatus(c, h.scheduleService.Resume)
+}
+
+func (h *Handler) endSchedule(c *gin.Context) {
+	h.changeScheduleStatus(c, h.scheduleService.End)
+}
+
+func (h *Handler) changeScheduleStatus(c *gin.Context, change func(ctx context.Context, principal model.Principal, id string) (*model.TicketSchedule, error)) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, errorResponse("invalid schedule id"))
+		return
+	}
+
+	schedule, err := change(c.Request.Context(), principal, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(schedule))
+}
+
+func (h *Handler) listHolidays(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	holidays, err := h.scheduleService.ListHolidays(c.Request.Context(), principal,
+		strings.TrimSpace(c.Query("from")), strings.TrimSpace(c.Query("to")))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(holidays))
+}
+
+func (h *Handler) putHoliday(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	holiday, err := h.scheduleService.AddHoliday(c.Request.Context(), principal, strings.TrimSpace(c.Param("date")), req.Name)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, successResponse(holiday))
+}
+
+func (h *Handler) deleteHoliday(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	if err := h.scheduleService.DeleteHoliday(c.Request.Context(), principal, strings.TrimSpace(c.Param("date"))); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
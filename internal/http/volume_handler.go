@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ticket-service/internal/http/middleware"
+	"ticket-service/internal/service"
+)
+
+func (h *Handler) recalculateVolume(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, errorResponse("missing principal"))
+		return
+	}
+
+	var req struct {
+		AssignmentID *string `json:"assignment_id"`
+		TicketID     *string `json:"ticket_id"`
+		DateFrom     *string `json:"date_from"`
+		DateTo       *string `json:"date_to"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	result, err := h.tripService.RequestVolumeRecalculation(c.Request.Context(), principal, service.RecalculateVolumeInput{
+		AssignmentID: req.AssignmentID,
+		TicketID:     req.TicketID,
+		DateFrom:     req.DateFrom,
+		DateTo:       req.DateTo,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, successResponse(result))
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ticket-service/internal/service"
+)
+
+// anprWebhookEvent - событие ANPR в формате ответа /internal/anpr/events
+type anprWebhookEvent struct {
+	ID              string   `json:"id" binding:"required"`
+	NormalizedPlate string   `json:"normalized_plate" binding:"required"`
+	EventTime       string   `json:"event_time" binding:"required"`
+	Direction       string   `json:"direction" binding:"required"`
+	SnowVolumeM3    *float64 `json:"snow_volume_m3"`
+	CameraID        string   `json:"camera_id" binding:"required"`
+	PolygonID       *string  `json:"polygon_id"`
+}
+
+// receiveANPREvents принимает события, которые ANPR отправляет сам (push), и сразу обновляет рейсы.
+// Если хотя бы одно событие не обработано из-за внутренней ошибки, отвечает 503, чтобы ANPR
+// повторил доставку; уже сохраненные события при повторе не дублируются.
+func (h *Handler) receiveANPREvents(c *gin.Context) {
+	var req struct {
+		Events []anprWebhookEvent `json:"events" binding:"required,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err.Error()))
+		return
+	}
+
+	inputs := make([]service.IngestANPREventInput, 0, len(req.Events))
+	for _, event := range req.Events {
+		inputs = append(inputs, service.IngestANPREventInput{
+			ID:           event.ID,
+			PlateNumber:  event.NormalizedPlate,
+			EventTime:    event.EventTime,
+			Direction:    event.Direction,
+			SnowVolumeM3: event.SnowVolumeM3,
+			CameraID:     event.CameraID,
+			PolygonID:    event.PolygonID,
+		})
+	}
+
+	results, err := h.eventService.IngestANPREvents(c.Request.Context(), inputs)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Status == service.TripIngestStatusError {
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	c.JSON(status, successResponse(gin.H{"results": results}))
+}
@@ -0,0 +1,126 @@
+// Package importfile читает табличные файлы (CSV, XLSX) для массового импорта.
+// Первая непустая строка - заголовок; значения возвращаются как текст.
+package importfile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported file format, expected CSV or XLSX")
+
+// Table - заголовок и строки данных. Row - номер строки в файле (с 1, заголовок тоже считается),
+// чтобы ошибки можно было показать пользователю в привычной нумерации.
+type Table struct {
+	Header []string
+	Rows   []Row
+}
+
+type Row struct {
+	Number int
+	Values []string
+}
+
+// Get возвращает значение колонки column (по имени из заголовка, без учета регистра)
+func (t *Table) Get(row Row, column string) (string, bool) {
+	for i, name := range t.Header {
+		if name == strings.ToLower(column) {
+			if i < len(row.Values) {
+				return strings.TrimSpace(row.Values[i]), true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// Read разбирает файл по расширению имени, а если оно неизвестно - по содержимому
+// (XLSX - zip-архив, начинается с "PK").
+func Read(filename string, data []byte) (*Table, error) {
+	var records [][]string
+	var err error
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); {
+	case ext == ".xlsx" || (ext != ".csv" && bytes.HasPrefix(data, []byte("PK\x03\x04"))):
+		records, err = readXLSX(data)
+	case ext == ".csv" || ext == "" || ext == ".txt":
+		records, err = readCSV(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{}
+	for i, record := range records {
+		if isBlank(record) {
+			continue
+		}
+		if table.Header == nil {
+			table.Header = make([]string, len(record))
+			for j, name := range record {
+				table.Header[j] = strings.ToLower(strings.TrimSpace(name))
+			}
+			continue
+		}
+		table.Rows = append(table.Rows, Row{Number: i + 1, Values: record})
+	}
+	if table.Header == nil {
+		return nil, errors.New("file is empty")
+	}
+	return table, nil
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	// Excel с русской локалью сохраняет CSV с разделителем ";"
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	return records, nil
+}
+
+// readXLSX читает первый лист книги. Значения берутся в том виде, как их показывает Excel.
+func readXLSX(data []byte) ([][]string, error) {
+	book, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX: %w", err)
+	}
+	defer book.Close()
+
+	sheets := book.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("invalid XLSX: no sheets")
+	}
+	rows, err := book.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX: %w", err)
+	}
+	return rows, nil
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
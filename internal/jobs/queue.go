@@ -0,0 +1,61 @@
+// Package jobs - очередь фоновых задач на базе Postgres с повторами, экспоненциальной
+// задержкой и состоянием DEAD для задач, исчерпавших попытки.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+// Queue ставит задачи в очередь
+type Queue struct {
+	repo        *repository.JobRepository
+	maxAttempts int
+}
+
+func NewQueue(repo *repository.JobRepository, maxAttempts int) *Queue {
+	return &Queue{repo: repo, maxAttempts: maxAttempts}
+}
+
+// Enqueue ставит задачу с payload в очередь на немедленное выполнение.
+// Непустой dedupKey гарантирует, что в очереди будет не больше одной невыполненной задачи с этим ключом;
+// в этом случае возвращается false.
+func (q *Queue) Enqueue(ctx context.Context, jobType, dedupKey string, payload interface{}) (bool, error) {
+	job, err := q.NewJob(jobType, dedupKey, payload)
+	if err != nil {
+		return false, err
+	}
+	return q.Add(ctx, job)
+}
+
+// Add ставит в очередь задачу, собранную NewJob. Возвращает false, если задача с тем же
+// dedupKey еще не выполнена.
+func (q *Queue) Add(ctx context.Context, job *model.Job) (bool, error) {
+	return q.repo.Enqueue(ctx, job)
+}
+
+// NewJob собирает задачу, не сохраняя ее. Используется, когда задачу нужно поставить в очередь
+// в одной транзакции с изменением данных, которые она обрабатывает.
+func (q *Queue) NewJob(jobType, dedupKey string, payload interface{}) (*model.Job, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	job := &model.Job{
+		Type:        jobType,
+		Payload:     string(body),
+		Status:      model.JobStatusPending,
+		MaxAttempts: q.maxAttempts,
+		RunAt:       time.Now(),
+	}
+	if dedupKey != "" {
+		job.DedupKey = &dedupKey
+	}
+	return job, nil
+}
@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"ticket-service/internal/config"
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+// HandlerFunc выполняет задачу. Ошибка приводит к повтору, ошибка, обернутая в Permanent, -
+// к немедленному переводу задачи в DEAD.
+type HandlerFunc func(ctx context.Context, job *model.Job) error
+
+// DeadLetterFunc вызывается, когда задача переведена в DEAD
+type DeadLetterFunc func(ctx context.Context, job *model.Job, err error)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку как неустранимую: повторять такую задачу бессмысленно
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent проверяет, что ошибка помечена как неустранимая
+func IsPermanent(err error) bool {
+	var target *permanentError
+	return errors.As(err, &target)
+}
+
+type handler struct {
+	handle HandlerFunc
+	onDead DeadLetterFunc
+}
+
+// Worker забирает задачи из очереди и выполняет их зарегистрированными обработчиками
+type Worker struct {
+	repo     *repository.JobRepository
+	cfg      config.JobsConfig
+	handlers map[string]handler
+	log      zerolog.Logger
+}
+
+func NewWorker(repo *repository.JobRepository, cfg config.JobsConfig, log zerolog.Logger) *Worker {
+	return &Worker{
+		repo:     repo,
+		cfg:      cfg,
+		handlers: make(map[string]handler),
+		log:      log,
+	}
+}
+
+// Register задает обработчик задач типа jobType. onDead может быть nil.
+// Регистрировать обработчики нужно до вызова Run.
+func (w *Worker) Register(jobType string, handle HandlerFunc, onDead DeadLetterFunc) {
+	w.handlers[jobType] = handler{handle: handle, onDead: onDead}
+}
+
+// Run запускает cfg.Workers воркеров и блокируется до отмены контекста и завершения текущих задач
+func (w *Worker) Run(ctx context.Context) {
+	types := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		types = append(types, jobType)
+	}
+	if len(types) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx, types)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.requeueStale(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func (w *Worker) loop(ctx context.Context, types []string) {
+	for {
+		processed, err := w.processNext(ctx, types)
+		if err != nil && ctx.Err() == nil {
+			w.log.Error().Err(err).Msg("failed to process job")
+		}
+		if processed {
+			// Сразу берем следующую задачу, пока очередь не опустеет
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+// processNext выполняет одну задачу. Возвращает false, если готовых задач нет.
+func (w *Worker) processNext(ctx context.Context, types []string) (bool, error) {
+	job, err := w.repo.Claim(ctx, types, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	h := w.handlers[job.Type]
+	handleErr := h.handle(ctx, job)
+	if handleErr == nil {
+		return true, w.repo.Complete(context.WithoutCancel(ctx), job.ID)
+	}
+
+	// Состояние задачи сохраняем даже при остановке сервиса
+	saveCtx := context.WithoutCancel(ctx)
+	logEvent := w.log.Warn().
+		Err(handleErr).
+		Str("job_id", job.ID.String()).
+		Str("job_type", job.Type).
+		Int("attempt", job.Attempts)
+
+	if IsPermanent(handleErr) || job.Attempts >= job.MaxAttempts {
+		logEvent.Msg("job moved to dead letter")
+		if err := w.repo.MarkDead(saveCtx, job.ID, handleErr.Error()); err != nil {
+			return true, err
+		}
+		if h.onDead != nil {
+			h.onDead(saveCtx, job, handleErr)
+		}
+		return true, nil
+	}
+
+	delay := Backoff(job.Attempts, w.cfg.BaseBackoff, w.cfg.MaxBackoff)
+	if ctx.Err() != nil {
+		// Задача прервана остановкой сервиса - повторяем сразу после запуска
+		delay = 0
+	}
+	logEvent.Dur("retry_in", delay).Msg("job failed, will retry")
+	if err := w.repo.Retry(saveCtx, job.ID, time.Now().Add(delay), handleErr.Error()); err != nil {
+		return true, fmt.Errorf("failed to reschedule job: %w", err)
+	}
+	return true, nil
+}
+
+func (w *Worker) requeueStale(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.LockTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		count, err := w.repo.RequeueStale(ctx, time.Now().Add(-w.cfg.LockTimeout))
+		if err != nil {
+			if ctx.Err() == nil {
+				w.log.Error().Err(err).Msg("failed to requeue stale jobs")
+			}
+			continue
+		}
+		if count > 0 {
+			w.log.Warn().Int64("count", count).Msg("requeued stale jobs")
+		}
+	}
+}
+
+// Backoff возвращает задержку перед попыткой attempt+1: base * 2^(attempt-1), но не больше maxDelay,
+// со случайным разбросом ±20%, чтобы повторы разных задач не совпадали по времени
+func Backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := base
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5*2+1)) - delay/5
+	return delay + jitter
+}
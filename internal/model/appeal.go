@@ -22,6 +22,8 @@ type Appeal struct {
 	ID              uuid.UUID    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
 	TripID          *uuid.UUID   `gorm:"type:uuid;index" json:"trip_id"`
 	TicketID        *uuid.UUID   `gorm:"type:uuid;index" json:"ticket_id"`
+	// TripViolationID - обжалуемое нарушение; NULL - обжалуется рейс целиком
+	TripViolationID *uuid.UUID   `gorm:"type:uuid;index" json:"trip_violation_id"`
 	CreatedByUserID uuid.UUID    `gorm:"type:uuid;not null" json:"created_by_user_id"`
 	Status          AppealStatus `gorm:"type:appeal_status;not null;default:SUBMITTED" json:"status"`
 	Reason          string       `gorm:"type:text;not null" json:"reason"`
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AssignmentRound - один рейс водителя в рамках назначения: от отметки «В работе» до отметки
+// «Рейс завершен». За смену у назначения может быть много рейсов, по каждому создается свой Trip.
+type AssignmentRound struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	AssignmentID uuid.UUID  `gorm:"type:uuid;not null;index" json:"assignment_id"`
+	Number       int        `gorm:"not null" json:"number"`
+	StartedAt    time.Time  `gorm:"type:timestamptz;not null" json:"started_at"`
+	FinishedAt   *time.Time `gorm:"type:timestamptz" json:"finished_at"`
+	TripID       *uuid.UUID `gorm:"type:uuid" json:"trip_id"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (AssignmentRound) TableName() string {
+	return "assignment_rounds"
+}
+
+func (r *AssignmentRound) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ContractSettings - пороги проверок нарушений и стратегия учета объема для конкретного договора.
+// NULL в поле означает, что используется значение по умолчанию из конфигурации.
+type ContractSettings struct {
+	ContractID           uuid.UUID       `gorm:"type:uuid;primaryKey" json:"contract_id"`
+	MaxTripVolumeM3      *float64        `gorm:"type:double precision" json:"max_trip_volume_m3"`
+	MinTripVolumeM3      *float64        `gorm:"type:double precision" json:"min_trip_volume_m3"`
+	MaxExitVolumeM3      *float64        `gorm:"type:double precision" json:"max_exit_volume_m3"`
+	VolumeLimitM3        *float64        `gorm:"column:volume_limit_m3;type:double precision" json:"volume_limit_m3"`
+	RequirePolygonAccess *bool           `json:"require_polygon_access"`
+	VolumeStrategy       *VolumeStrategy `gorm:"type:varchar(32)" json:"volume_strategy"`
+	CreatedAt            time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ContractSettings) TableName() string {
+	return "contract_settings"
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DriverStatusEventType - действие водителя или подрядчика по назначению
+type DriverStatusEventType string
+
+const (
+	// DriverStatusEventRoundStarted - водитель начал рейс (mark-in-work)
+	DriverStatusEventRoundStarted DriverStatusEventType = "ROUND_STARTED"
+	// DriverStatusEventRoundFinished - водитель завершил рейс (mark-completed)
+	DriverStatusEventRoundFinished DriverStatusEventType = "ROUND_FINISHED"
+	// DriverStatusEventPaused - начало паузы
+	DriverStatusEventPaused DriverStatusEventType = "PAUSED"
+	// DriverStatusEventResumed - конец паузы
+	DriverStatusEventResumed DriverStatusEventType = "RESUMED"
+	// DriverStatusEventShiftEnded - водитель завершил смену
+	DriverStatusEventShiftEnded DriverStatusEventType = "SHIFT_ENDED"
+	// DriverStatusEventVehicleChanged - подрядчик заменил машину назначения
+	DriverStatusEventVehicleChanged DriverStatusEventType = "VEHICLE_CHANGED"
+	// DriverStatusEventReassigned - подрядчик снял назначение и передал работу новому назначению
+	DriverStatusEventReassigned DriverStatusEventType = "REASSIGNED"
+)
+
+// PauseReason - причина паузы водителя
+type PauseReason string
+
+const (
+	PauseReasonRefuelling PauseReason = "REFUELLING"
+	PauseReasonBreakdown  PauseReason = "BREAKDOWN"
+	PauseReasonBreak      PauseReason = "BREAK"
+	PauseReasonOther      PauseReason = "OTHER"
+)
+
+// IsValid проверяет, что причина входит в перечисление
+func (r PauseReason) IsValid() bool {
+	switch r {
+	case PauseReasonRefuelling, PauseReasonBreakdown, PauseReasonBreak, PauseReasonOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// DriverStatusEvent - запись журнала driver_status_events: что произошло с назначением, статусы
+// отметки водителя до и после и кто выполнил действие. По журналу считается рабочее время и паузы.
+type DriverStatusEvent struct {
+	ID           uuid.UUID             `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	AssignmentID uuid.UUID             `gorm:"type:uuid;not null;index" json:"assignment_id"`
+	DriverID     uuid.UUID             `gorm:"type:uuid;not null" json:"driver_id"`
+	Event        DriverStatusEventType `gorm:"type:varchar(32);not null" json:"event"`
+	FromStatus   DriverMarkStatus      `gorm:"type:driver_mark_status;not null" json:"from_status"`
+	ToStatus     DriverMarkStatus      `gorm:"type:driver_mark_status;not null" json:"to_status"`
+	// PauseReason заполнен у события PAUSED
+	PauseReason *PauseReason `gorm:"type:varchar(32)" json:"pause_reason,omitempty"`
+	// OldVehicleID/NewVehicleID заполнены у события VEHICLE_CHANGED и у REASSIGNED с заменой машины
+	OldVehicleID *uuid.UUID `gorm:"type:uuid" json:"old_vehicle_id,omitempty"`
+	NewVehicleID *uuid.UUID `gorm:"type:uuid" json:"new_vehicle_id,omitempty"`
+	Comment      *string    `gorm:"type:text" json:"comment,omitempty"`
+	ActorUserID  uuid.UUID  `gorm:"type:uuid;not null" json:"actor_user_id"`
+	ActorRole    UserRole   `gorm:"type:varchar(64);not null" json:"actor_role"`
+	CreatedAt    time.Time  `gorm:"not null" json:"created_at"`
+}
+
+func (DriverStatusEvent) TableName() string {
+	return "driver_status_events"
+}
+
+func (e *DriverStatusEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
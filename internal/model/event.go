@@ -7,8 +7,15 @@ import (
 	"gorm.io/gorm"
 )
 
+// Направления проезда, которые передают LPR и volume камеры
+const (
+	EventDirectionEntry = "entry"
+	EventDirectionExit  = "exit"
+)
+
 type LprEvent struct {
 	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ExternalID  *string    `gorm:"type:varchar(128);uniqueIndex" json:"external_id,omitempty"`
 	CameraID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"camera_id"`
 	PolygonID   *uuid.UUID `gorm:"type:uuid" json:"polygon_id"`
 	PlateNumber string     `gorm:"type:varchar(32);not null;index" json:"plate_number"`
@@ -32,6 +39,7 @@ func (e *LprEvent) BeforeCreate(tx *gorm.DB) error {
 
 type VolumeEvent struct {
 	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ExternalID    *string    `gorm:"type:varchar(128);uniqueIndex" json:"external_id,omitempty"`
 	CameraID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"camera_id"`
 	PolygonID     *uuid.UUID `gorm:"type:uuid" json:"polygon_id"`
 	DetectedVolume float64   `gorm:"not null" json:"detected_volume"`
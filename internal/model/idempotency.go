@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// IdempotencyKey хранит результат POST-запроса с заголовком Idempotency-Key,
+// чтобы повторный запрос с тем же ключом вернул тот же ответ
+type IdempotencyKey struct {
+	Scope        string     `gorm:"type:varchar(128);primaryKey" json:"scope"`
+	Key          string     `gorm:"type:varchar(255);primaryKey" json:"key"`
+	Method       string     `gorm:"type:varchar(10);not null" json:"method"`
+	Path         string     `gorm:"type:text;not null" json:"path"`
+	RequestHash  string     `gorm:"type:varchar(64);not null" json:"request_hash"`
+	StatusCode   *int       `json:"status_code"`
+	ResponseBody []byte     `gorm:"type:bytea" json:"-"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
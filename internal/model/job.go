@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending JobStatus = "PENDING"
+	JobStatusRunning JobStatus = "RUNNING"
+	JobStatusDone    JobStatus = "DONE"
+	// JobStatusDead - задача исчерпала попытки или завершилась неустранимой ошибкой
+	JobStatusDead JobStatus = "DEAD"
+)
+
+// Типы фоновых задач
+const (
+	JobTypeTripVolume = "trip_volume"
+)
+
+// Job - фоновая задача в очереди на базе Postgres
+type Job struct {
+	ID      uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	Type    string    `gorm:"type:varchar(64);not null" json:"type"`
+	Payload string    `gorm:"type:jsonb;not null" json:"payload"`
+	// DedupKey - ключ, по которому в очереди может быть только одна незавершенная задача
+	DedupKey    *string    `gorm:"type:varchar(128)" json:"dedup_key,omitempty"`
+	Status      JobStatus  `gorm:"type:varchar(16);not null;default:PENDING" json:"status"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null" json:"max_attempts"`
+	RunAt       time.Time  `gorm:"not null" json:"run_at"`
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	LastError   *string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
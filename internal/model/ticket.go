@@ -32,8 +32,13 @@ type Ticket struct {
 	PhotoURL       *string      `gorm:"type:text" json:"photo_url"`
 	Latitude       *float64     `json:"latitude"`
 	Longitude      *float64     `json:"longitude"`
-	CreatedAt      time.Time    `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt      time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+	// AllowOverlap - KGU намеренно допустил пересечение с другими активными тикетами участка
+	AllowOverlap bool `gorm:"not null;default:false" json:"allow_overlap"`
+	// ScheduleID/ScheduleDate заполнены у тикетов, созданных по расписанию
+	ScheduleID   *uuid.UUID `gorm:"type:uuid" json:"schedule_id,omitempty"`
+	ScheduleDate *time.Time `gorm:"type:date" json:"schedule_date,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Ticket) TableName() string {
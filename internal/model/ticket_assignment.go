@@ -12,9 +12,19 @@ type DriverMarkStatus string
 const (
 	DriverMarkStatusNotStarted DriverMarkStatus = "NOT_STARTED"
 	DriverMarkStatusInWork     DriverMarkStatus = "IN_WORK"
-	DriverMarkStatusCompleted  DriverMarkStatus = "COMPLETED"
+	// DriverMarkStatusPaused - перерыв в смене (заправка, поломка); время паузы не считается рабочим
+	DriverMarkStatusPaused DriverMarkStatus = "PAUSED"
+	// DriverMarkStatusShiftEnded - водитель завершил смену
+	DriverMarkStatusShiftEnded DriverMarkStatus = "SHIFT_ENDED"
+	// DriverMarkStatusCompleted - рейс завершен (назначения, завершенные до появления смен)
+	DriverMarkStatusCompleted DriverMarkStatus = "COMPLETED"
 )
 
+// IsFinished проверяет, что водитель закончил работу по назначению
+func (s DriverMarkStatus) IsFinished() bool {
+	return s == DriverMarkStatusShiftEnded || s == DriverMarkStatusCompleted
+}
+
 type TicketAssignment struct {
 	ID               uuid.UUID        `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
 	TicketID         uuid.UUID        `gorm:"type:uuid;not null;index" json:"ticket_id"`
@@ -25,9 +35,18 @@ type TicketAssignment struct {
 	UnassignedAt     *time.Time       `json:"unassigned_at"`
 	TripStartedAt    *time.Time       `gorm:"type:timestamptz" json:"trip_started_at,omitempty"`
 	TripFinishedAt   *time.Time       `gorm:"type:timestamptz" json:"trip_finished_at,omitempty"`
+	PausedAt         *time.Time       `gorm:"type:timestamptz" json:"paused_at,omitempty"`
+	ShiftEndedAt     *time.Time       `gorm:"type:timestamptz" json:"shift_ended_at,omitempty"`
 	IsActive         bool             `gorm:"not null;default:true" json:"is_active"`
-	CreatedAt        time.Time        `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt        time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
+	// PreviousAssignmentID - снятое назначение, вместо которого создано это (переназначение, возврат на доработку)
+	PreviousAssignmentID *uuid.UUID `gorm:"type:uuid;index" json:"previous_assignment_id,omitempty"`
+	CreatedAt            time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Rounds - рейсы назначения; TripStartedAt - начало первого рейса, TripFinishedAt - конец смены,
+	// PausedAt - начало текущей паузы. WorkingSeconds - время в IN_WORK без пауз (по driver_status_events).
+	Rounds         []AssignmentRound `gorm:"-" json:"rounds,omitempty"`
+	WorkingSeconds *int64            `gorm:"-" json:"working_seconds,omitempty"`
 }
 
 func (TicketAssignment) TableName() string {
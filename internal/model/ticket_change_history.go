@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TicketChangeHistory - изменение одного поля тикета через PATCH /kgu/tickets/:id.
+// Значения хранятся в текстовом виде: UUID, время в RFC3339, описание как есть.
+type TicketChangeHistory struct {
+	ID           uuid.UUID    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	TicketID     uuid.UUID    `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	Field        string       `gorm:"type:varchar(64);not null" json:"field"`
+	OldValue     *string      `gorm:"type:text" json:"old_value"`
+	NewValue     *string      `gorm:"type:text" json:"new_value"`
+	TicketStatus TicketStatus `gorm:"type:ticket_status;not null" json:"ticket_status"`
+	ActorUserID  uuid.UUID    `gorm:"type:uuid;not null" json:"actor_user_id"`
+	ActorOrgID   uuid.UUID    `gorm:"type:uuid;not null" json:"actor_org_id"`
+	ActorRole    UserRole     `gorm:"type:varchar(64);not null" json:"actor_role"`
+	CreatedAt    time.Time    `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (TicketChangeHistory) TableName() string {
+	return "ticket_change_history"
+}
+
+func (h *TicketChangeHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
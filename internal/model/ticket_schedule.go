@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TicketScheduleStatus string
+
+const (
+	// TicketScheduleStatusActive - планировщик создает тикеты серии
+	TicketScheduleStatusActive TicketScheduleStatus = "ACTIVE"
+	// TicketScheduleStatusPaused - серия приостановлена, новые тикеты не создаются
+	TicketScheduleStatusPaused TicketScheduleStatus = "PAUSED"
+	// TicketScheduleStatusEnded - серия завершена окончательно
+	TicketScheduleStatusEnded TicketScheduleStatus = "ENDED"
+)
+
+// TicketSchedule - расписание повторяющихся тикетов: правило повторения (RRULE) задает даты,
+// StartTime/EndTime - окно работ в часовом поясе Timezone. Окно, у которого EndTime не позже
+// StartTime, заканчивается на следующий день (ночная уборка 22:00-06:00).
+type TicketSchedule struct {
+	ID             uuid.UUID            `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	CreatedByOrgID uuid.UUID            `gorm:"type:uuid;not null;index" json:"created_by_org_id"`
+	CleaningAreaID uuid.UUID            `gorm:"type:uuid;not null" json:"cleaning_area_id"`
+	ContractorID   uuid.UUID            `gorm:"type:uuid;not null" json:"contractor_id"`
+	ContractID     uuid.UUID            `gorm:"type:uuid;not null" json:"contract_id"`
+	RRule          string               `gorm:"column:rrule;type:text;not null" json:"rrule"`
+	Timezone       string               `gorm:"type:varchar(64);not null" json:"timezone"`
+	StartTime      string               `gorm:"type:varchar(5);not null" json:"start_time"`
+	EndTime        string               `gorm:"type:varchar(5);not null" json:"end_time"`
+	StartsOn       time.Time            `gorm:"type:date;not null" json:"starts_on"`
+	EndsOn         *time.Time           `gorm:"type:date" json:"ends_on"`
+	SkipHolidays   bool                 `gorm:"not null;default:true" json:"skip_holidays"`
+	Description    string               `gorm:"type:text" json:"description"`
+	Status         TicketScheduleStatus `gorm:"type:varchar(16);not null;default:ACTIVE" json:"status"`
+	// MaterializedUntil - до какого момента (по началу окна) тикеты серии уже созданы
+	MaterializedUntil *time.Time `json:"materialized_until"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (TicketSchedule) TableName() string {
+	return "ticket_schedules"
+}
+
+func (s *TicketSchedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// Holiday - нерабочий день организации KGU; расписания с SkipHolidays пропускают такие даты
+type Holiday struct {
+	OrgID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"org_id"`
+	Date      time.Time `gorm:"type:date;primaryKey" json:"date"`
+	Name      string    `gorm:"type:text" json:"name"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (Holiday) TableName() string {
+	return "holidays"
+}
+
+// ScheduleException - дата серии, тикет которой удален вручную; планировщик ее пропускает
+type ScheduleException struct {
+	ScheduleID uuid.UUID `gorm:"type:uuid;primaryKey" json:"schedule_id"`
+	Date       time.Time `gorm:"type:date;primaryKey" json:"date"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (ScheduleException) TableName() string {
+	return "ticket_schedule_exceptions"
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TicketEvent - событие, переводящее тикет из одного статуса в другой
+type TicketEvent string
+
+const (
+	// TicketEventCreate - создание тикета (PLANNED)
+	TicketEventCreate TicketEvent = "CREATE"
+	// TicketEventStart - первый рейс или отметка водителя «В работе»
+	TicketEventStart TicketEvent = "START"
+	// TicketEventComplete - подрядчик завершил работы
+	TicketEventComplete TicketEvent = "COMPLETE"
+	// TicketEventAutoComplete - все рейсы и назначения завершены
+	TicketEventAutoComplete TicketEvent = "AUTO_COMPLETE"
+	// TicketEventClose - KGU принял работы
+	TicketEventClose TicketEvent = "CLOSE"
+	// TicketEventCancel - KGU отменил тикет до начала работ
+	TicketEventCancel TicketEvent = "CANCEL"
+	// TicketEventReopen - KGU вернул завершенный тикет на доработку
+	TicketEventReopen TicketEvent = "REOPEN"
+)
+
+// TicketStatusHistory - запись о смене статуса тикета. Actor* пустые для переходов,
+// выполненных сервисом автоматически (рейсы, события камер).
+type TicketStatusHistory struct {
+	ID          uuid.UUID     `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	TicketID    uuid.UUID     `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	FromStatus  *TicketStatus `gorm:"type:ticket_status" json:"from_status"`
+	ToStatus    TicketStatus  `gorm:"type:ticket_status;not null" json:"to_status"`
+	Event       TicketEvent   `gorm:"type:varchar(32);not null" json:"event"`
+	ActorUserID *uuid.UUID    `gorm:"type:uuid" json:"actor_user_id"`
+	ActorOrgID  *uuid.UUID    `gorm:"type:uuid" json:"actor_org_id"`
+	ActorRole   *UserRole     `gorm:"type:varchar(64)" json:"actor_role"`
+	Reason      *string       `gorm:"type:text" json:"reason"`
+	CreatedAt   time.Time     `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (TicketStatusHistory) TableName() string {
+	return "ticket_status_history"
+}
+
+func (h *TicketStatusHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
@@ -21,30 +21,65 @@ const (
 	TripStatusOverContractLimit TripStatus = "OVER_CONTRACT_LIMIT"
 )
 
+// IsValid проверяет, что статус входит в перечисление trip_status
+func (s TripStatus) IsValid() bool {
+	switch s {
+	case TripStatusOK,
+		TripStatusRouteViolation,
+		TripStatusForeignArea,
+		TripStatusMismatchPlate,
+		TripStatusOverCapacity,
+		TripStatusNoAreaWork,
+		TripStatusNoAssignment,
+		TripStatusSuspiciousVolume,
+		TripStatusOverContractLimit:
+		return true
+	default:
+		return false
+	}
+}
+
+// VolumeCalculationStatus - состояние асинхронного расчета объема рейса по событиям ANPR
+type VolumeCalculationStatus string
+
+const (
+	VolumeCalculationPending    VolumeCalculationStatus = "PENDING"
+	VolumeCalculationCalculated VolumeCalculationStatus = "CALCULATED"
+	VolumeCalculationFailed     VolumeCalculationStatus = "FAILED"
+)
+
 type Trip struct {
-	ID                  uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
-	TicketID            *uuid.UUID `gorm:"type:uuid;index" json:"ticket_id"`
-	TicketAssignmentID  *uuid.UUID `gorm:"type:uuid;index" json:"ticket_assignment_id"`
-	DriverID            *uuid.UUID `gorm:"type:uuid;index" json:"driver_id"`
-	VehicleID           *uuid.UUID `gorm:"type:uuid;index" json:"vehicle_id"`
-	CameraID            *uuid.UUID `gorm:"type:uuid" json:"camera_id"`
-	PolygonID           *uuid.UUID `gorm:"type:uuid" json:"polygon_id"`
-	VehiclePlateNumber  string     `gorm:"type:varchar(32)" json:"vehicle_plate_number"`
-	DetectedPlateNumber string     `gorm:"type:varchar(32)" json:"detected_plate_number"`
-	EntryLprEventID     *uuid.UUID `gorm:"type:uuid" json:"entry_lpr_event_id"`
-	ExitLprEventID      *uuid.UUID `gorm:"type:uuid" json:"exit_lpr_event_id"`
-	EntryVolumeEventID  *uuid.UUID `gorm:"type:uuid" json:"entry_volume_event_id"`
-	ExitVolumeEventID   *uuid.UUID `gorm:"type:uuid" json:"exit_volume_event_id"`
-	DetectedVolumeEntry *float64   `json:"detected_volume_entry"`
-	DetectedVolumeExit  *float64   `json:"detected_volume_exit"`
-	TotalVolumeM3       *float64   `gorm:"type:double precision" json:"total_volume_m3,omitempty"`
-	AutoCreated         bool       `gorm:"default:true" json:"auto_created"`
-	EntryAt             time.Time  `gorm:"not null" json:"entry_at"`
-	ExitAt              *time.Time `json:"exit_at"`
-	Status              TripStatus `gorm:"type:trip_status;not null;default:OK" json:"status"`
-	ViolationReason     *string    `gorm:"column:violation_reason" json:"violation_reason,omitempty"`
-	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt           time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                      uuid.UUID                `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	ExternalID              *string                  `gorm:"type:varchar(128);uniqueIndex" json:"external_id,omitempty"`
+	TicketID                *uuid.UUID               `gorm:"type:uuid;index" json:"ticket_id"`
+	TicketAssignmentID      *uuid.UUID               `gorm:"type:uuid;index" json:"ticket_assignment_id"`
+	DriverID                *uuid.UUID               `gorm:"type:uuid;index" json:"driver_id"`
+	VehicleID               *uuid.UUID               `gorm:"type:uuid;index" json:"vehicle_id"`
+	CameraID                *uuid.UUID               `gorm:"type:uuid" json:"camera_id"`
+	PolygonID               *uuid.UUID               `gorm:"type:uuid" json:"polygon_id"`
+	VehiclePlateNumber      string                   `gorm:"type:varchar(32)" json:"vehicle_plate_number"`
+	DetectedPlateNumber     string                   `gorm:"type:varchar(32)" json:"detected_plate_number"`
+	EntryLprEventID         *uuid.UUID               `gorm:"type:uuid" json:"entry_lpr_event_id"`
+	ExitLprEventID          *uuid.UUID               `gorm:"type:uuid" json:"exit_lpr_event_id"`
+	EntryVolumeEventID      *uuid.UUID               `gorm:"type:uuid" json:"entry_volume_event_id"`
+	ExitVolumeEventID       *uuid.UUID               `gorm:"type:uuid" json:"exit_volume_event_id"`
+	DetectedVolumeEntry     *float64                 `json:"detected_volume_entry"`
+	DetectedVolumeExit      *float64                 `json:"detected_volume_exit"`
+	TotalVolumeM3           *float64                 `gorm:"type:double precision" json:"total_volume_m3,omitempty"`
+	AcceptedVolumeM3        *float64                 `gorm:"type:double precision" json:"accepted_volume_m3"`
+	VolumeStrategy          *VolumeStrategy          `gorm:"type:varchar(32)" json:"volume_strategy"`
+	AutoCreated             bool                     `gorm:"default:true" json:"auto_created"`
+	VolumeCalculationStatus *VolumeCalculationStatus `gorm:"type:varchar(16)" json:"volume_calculation_status,omitempty"`
+	VolumeCalculationError  *string                  `json:"volume_calculation_error,omitempty"`
+	EntryAt                 time.Time                `gorm:"not null" json:"entry_at"`
+	ExitAt                  *time.Time               `json:"exit_at"`
+	Status                  TripStatus               `gorm:"type:trip_status;not null;default:OK" json:"status"`
+	ViolationReason         *string                  `gorm:"column:violation_reason" json:"violation_reason,omitempty"`
+	ReportedStatus          *TripStatus              `gorm:"type:trip_status" json:"reported_status,omitempty"`
+	CreatedAt               time.Time                `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt               time.Time                `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Violations []TripViolation `gorm:"-" json:"violations,omitempty"`
 }
 
 func (Trip) TableName() string {
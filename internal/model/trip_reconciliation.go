@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Действия фонового сопоставления рейсов без назначения
+const (
+	ReconciliationActionAttached  = "ATTACHED"
+	ReconciliationActionAmbiguous = "AMBIGUOUS"
+	ReconciliationActionSkipped   = "SKIPPED"
+)
+
+// TripReconciliationLog - запись о том, что фоновое сопоставление сделало с рейсом
+type TripReconciliationLog struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	TripID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"trip_id"`
+	Action             string     `gorm:"type:varchar(32);not null" json:"action"`
+	TicketID           *uuid.UUID `gorm:"type:uuid" json:"ticket_id"`
+	TicketAssignmentID *uuid.UUID `gorm:"type:uuid" json:"ticket_assignment_id"`
+	PreviousStatus     TripStatus `gorm:"type:trip_status;not null" json:"previous_status"`
+	NewStatus          TripStatus `gorm:"type:trip_status;not null" json:"new_status"`
+	Details            *string    `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (TripReconciliationLog) TableName() string {
+	return "trip_reconciliation_log"
+}
+
+func (l *TripReconciliationLog) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
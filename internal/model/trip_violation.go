@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ViolationSeverity string
+
+const (
+	ViolationSeverityLow    ViolationSeverity = "LOW"
+	ViolationSeverityMedium ViolationSeverity = "MEDIUM"
+	ViolationSeverityHigh   ViolationSeverity = "HIGH"
+)
+
+// Weight возвращает вес серьезности для сравнения нарушений
+func (s ViolationSeverity) Weight() int {
+	switch s {
+	case ViolationSeverityHigh:
+		return 3
+	case ViolationSeverityMedium:
+		return 2
+	case ViolationSeverityLow:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// TripViolation - отдельное нарушение рейса. У рейса может быть несколько нарушений разных типов,
+// каждое обжалуется и снимается независимо.
+type TripViolation struct {
+	ID                 uuid.UUID         `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	TripID             uuid.UUID         `gorm:"type:uuid;not null;index" json:"trip_id"`
+	Type               TripStatus        `gorm:"type:trip_status;not null" json:"type"`
+	Severity           ViolationSeverity `gorm:"type:varchar(16);not null" json:"severity"`
+	Reason             string            `gorm:"type:text;not null" json:"reason"`
+	DetectedBy         string            `gorm:"type:varchar(64);not null" json:"detected_by"`
+	ResolvedByAppealID *uuid.UUID        `gorm:"type:uuid" json:"resolved_by_appeal_id"`
+	ResolvedAt         *time.Time        `json:"resolved_at"`
+	CreatedAt          time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (TripViolation) TableName() string {
+	return "trip_violations"
+}
+
+func (v *TripViolation) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsResolved - нарушение снято по результатам обжалования
+func (v *TripViolation) IsResolved() bool {
+	return v.ResolvedAt != nil
+}
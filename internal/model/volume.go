@@ -0,0 +1,23 @@
+package model
+
+// VolumeStrategy - способ учета принятого объема снега по рейсу
+type VolumeStrategy string
+
+const (
+	// VolumeStrategyNetEntryExit - объем на въезде за вычетом остатка в кузове на выезде
+	VolumeStrategyNetEntryExit VolumeStrategy = "NET_ENTRY_EXIT"
+	// VolumeStrategyANPREntrySum - сумма объемов событий въезда ANPR за период рейса
+	VolumeStrategyANPREntrySum VolumeStrategy = "ANPR_ENTRY_SUM"
+	// VolumeStrategyMaxBodyFill - максимальное зафиксированное заполнение кузова без учета остатка
+	VolumeStrategyMaxBodyFill VolumeStrategy = "MAX_BODY_FILL"
+)
+
+// IsValid проверяет, что стратегия поддерживается
+func (s VolumeStrategy) IsValid() bool {
+	switch s {
+	case VolumeStrategyNetEntryExit, VolumeStrategyANPREntrySum, VolumeStrategyMaxBodyFill:
+		return true
+	default:
+		return false
+	}
+}
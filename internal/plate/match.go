@@ -0,0 +1,121 @@
+package plate
+
+// MatchThreshold - минимальное сходство, при котором номера считаются одним и тем же.
+// Одна замена из пары путаемых символов проходит порог, замена любого другого символа - нет.
+const MatchThreshold = 0.9
+
+const (
+	confusableCost = 0.3
+	editCost       = 1.0
+)
+
+// Similarity возвращает сходство двух номеров от 0 до 1. Номера сравниваются в каноническом виде,
+// замена путаемых символов (O/0, B/8, I/1) стоит дешевле обычной.
+func Similarity(a, b string) float64 {
+	ca, _ := Canonical(a)
+	cb, _ := Canonical(b)
+	if ca == "" || cb == "" {
+		return 0
+	}
+	if ca == cb {
+		return 1
+	}
+
+	ra, rb := []rune(ca), []rune(cb)
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+
+	similarity := 1 - distance(ra, rb)/float64(maxLen)
+	if similarity < 0 {
+		return 0
+	}
+	return similarity
+}
+
+// Matches проверяет, что номера совпадают с точностью до ошибок распознавания.
+// Нечеткое сравнение применяется только к номерам форматов РК: для номера другого формата
+// порог пропускал бы длинные строки с одной-двумя заменами, поэтому требуется точное
+// совпадение канонического вида.
+func Matches(a, b string) bool {
+	ca, fa := Canonical(a)
+	cb, fb := Canonical(b)
+	if fa == nil || fb == nil {
+		return ca != "" && ca == cb
+	}
+	return Similarity(a, b) >= MatchThreshold
+}
+
+// Variants возвращает канонический номер и его написания с одной заменой путаемого символа
+// (O/0, B/8, I/1), а также исходное написание. Используется для поиска во внешних системах,
+// которые сравнивают номера строго. Для номера не в формате РК замены не добавляются (см. Matches).
+func Variants(raw string) []string {
+	canonical, format := Canonical(raw)
+	if canonical == "" {
+		return nil
+	}
+
+	variants := []string{canonical}
+	seen := map[string]bool{canonical: true}
+	add := func(value string) {
+		if !seen[value] {
+			seen[value] = true
+			variants = append(variants, value)
+		}
+	}
+
+	chars := []rune(canonical)
+	if format == nil {
+		chars = nil
+	}
+	for i, r := range chars {
+		alt, ok := toLetter[r]
+		if !ok {
+			alt, ok = toDigit[r]
+		}
+		if !ok {
+			continue
+		}
+		variant := make([]rune, len(chars))
+		copy(variant, chars)
+		variant[i] = alt
+		add(string(variant))
+	}
+
+	add(Normalize(raw))
+
+	return variants
+}
+
+// distance - расстояние Левенштейна, в котором замена путаемых символов стоит confusableCost
+func distance(a, b []rune) float64 {
+	prev := make([]float64, len(b)+1)
+	curr := make([]float64, len(b)+1)
+	for j := range prev {
+		prev[j] = float64(j) * editCost
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = float64(i) * editCost
+		for j := 1; j <= len(b); j++ {
+			substitution := prev[j-1] + substitutionCost(a[i-1], b[j-1])
+			deletion := prev[j] + editCost
+			insertion := curr[j-1] + editCost
+			curr[j] = min(substitution, deletion, insertion)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func substitutionCost(a, b rune) float64 {
+	if a == b {
+		return 0
+	}
+	if toDigit[a] == b || toLetter[a] == b {
+		return confusableCost
+	}
+	return editCost
+}
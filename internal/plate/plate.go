@@ -0,0 +1,137 @@
+// Package plate - нормализация и нечеткое сравнение номерных знаков Республики Казахстан
+// с учетом типичных ошибок распознавания (кириллица/латиница, O/0, B/8, I/1).
+package plate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Format - формат номерного знака. В шаблоне D - цифра, L - латинская буква, R - цифра кода региона.
+type Format struct {
+	Name    string
+	Pattern string
+}
+
+// Formats - поддерживаемые форматы номеров РК, порядок важен при одинаковой длине
+var Formats = []Format{
+	{Name: "individual", Pattern: "DDDLLLRR"}, // 123ABC02 - физические лица (с 2012 г.)
+	{Name: "legal", Pattern: "DDDLLRR"},       // 123AB02 - юридические лица
+	{Name: "trailer", Pattern: "DDDDLLRR"},    // 1234AB02 - прицепы
+	{Name: "legacy", Pattern: "LDDDLLL"},      // A123BCD - образец до 2012 г.
+	{Name: "legacy_legal", Pattern: "LDDDLL"}, // A123BC - юр. лица, образец до 2012 г.
+}
+
+const (
+	minRegionCode = 1
+	maxRegionCode = 20
+	countryPrefix = "KZ"
+)
+
+// homoglyphs - кириллические буквы, совпадающие по начертанию с латинскими
+var homoglyphs = map[rune]rune{
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+	'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X', 'І': 'I', 'Ү': 'Y',
+}
+
+// toDigit/toLetter - пары символов, которые OCR путает между собой
+var (
+	toDigit  = map[rune]rune{'O': '0', 'B': '8', 'I': '1'}
+	toLetter = map[rune]rune{'0': 'O', '8': 'B', '1': 'I'}
+)
+
+// Normalize приводит номер к верхнему регистру, удаляет пробелы и разделители
+// и заменяет кириллические буквы на латинские аналоги
+func Normalize(raw string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(raw)) {
+		if folded, ok := homoglyphs[r]; ok {
+			r = folded
+		}
+		if r == ' ' || r == '-' || r == '.' || r == '_' {
+			continue
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Canonical возвращает номер в каноническом виде и его формат. Символы, которые OCR путает
+// (O/0, B/8, I/1), исправляются в зависимости от позиции: в цифровых позициях - на цифру,
+// в буквенных - на букву. Если номер не подходит ни под один формат, возвращается
+// нормализованная строка и nil.
+func Canonical(raw string) (string, *Format) {
+	normalized := Normalize(raw)
+	if normalized == "" {
+		return "", nil
+	}
+
+	if canonical, format := matchFormat(normalized); format != nil {
+		return canonical, format
+	}
+
+	// Номер может быть прочитан вместе с кодом страны с полосы слева
+	if strings.HasPrefix(normalized, countryPrefix) {
+		if canonical, format := matchFormat(strings.TrimPrefix(normalized, countryPrefix)); format != nil {
+			return canonical, format
+		}
+	}
+
+	return normalized, nil
+}
+
+// Valid проверяет, что номер соответствует одному из форматов РК
+func Valid(raw string) bool {
+	_, format := Canonical(raw)
+	return format != nil
+}
+
+func matchFormat(normalized string) (string, *Format) {
+	chars := []rune(normalized)
+	for i := range Formats {
+		format := &Formats[i]
+		if len(chars) != len(format.Pattern) {
+			continue
+		}
+		if canonical, ok := coerce(chars, format.Pattern); ok {
+			return canonical, format
+		}
+	}
+	return "", nil
+}
+
+func coerce(chars []rune, pattern string) (string, bool) {
+	out := make([]rune, len(chars))
+	region := 0
+	for i, class := range pattern {
+		r := chars[i]
+		switch class {
+		case 'D', 'R':
+			if d, ok := toDigit[r]; ok {
+				r = d
+			}
+			if r < '0' || r > '9' {
+				return "", false
+			}
+			if class == 'R' {
+				region = region*10 + int(r-'0')
+			}
+		case 'L':
+			if l, ok := toLetter[r]; ok {
+				r = l
+			}
+			if r < 'A' || r > 'Z' {
+				return "", false
+			}
+		}
+		out[i] = r
+	}
+
+	if strings.ContainsRune(pattern, 'R') && (region < minRegionCode || region > maxRegionCode) {
+		return "", false
+	}
+	return string(out), true
+}
@@ -0,0 +1,165 @@
+package plate
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "123ABC02", want: "123ABC02"},
+		{raw: " 123 abc 02 ", want: "123ABC02"},
+		{raw: "123-ABC.02_", want: "123ABC02"},
+		{raw: "123\tABC 02", want: "123ABC02"},
+		// Кириллические А, В, С
+		{raw: "123 АВС 02", want: "123ABC02"},
+		{raw: "а123вс", want: "A123BC"},
+		{raw: "123 КМН 05", want: "123KMH05"},
+		{raw: "ІҮ", want: "IY"},
+		// Буквы без латинского аналога не заменяются
+		{raw: "123ЖЖЖ02", want: "123ЖЖЖ02"},
+		{raw: "", want: ""},
+		{raw: "  ", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.raw); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		want       string
+		wantFormat string
+	}{
+		{name: "individual", raw: "123ABC02", want: "123ABC02", wantFormat: "individual"},
+		{name: "legal", raw: "123 AB 02", want: "123AB02", wantFormat: "legal"},
+		{name: "trailer", raw: "1234AB02", want: "1234AB02", wantFormat: "trailer"},
+		{name: "legacy", raw: "A123BCD", want: "A123BCD", wantFormat: "legacy"},
+		{name: "legacy legal", raw: "A123BC", want: "A123BC", wantFormat: "legacy_legal"},
+		{name: "cyrillic letters", raw: "123 АВС 02", want: "123ABC02", wantFormat: "individual"},
+		{name: "letter in digit position", raw: "I23ABCO2", want: "123ABC02", wantFormat: "individual"},
+		{name: "digit in letter position", raw: "123A8C02", want: "123ABC02", wantFormat: "individual"},
+		{name: "all confusables", raw: "IO8OBIO8", want: "108OBI08", wantFormat: "individual"},
+		{name: "legacy confusables", raw: "0I23BCI", want: "O123BCI", wantFormat: "legacy"},
+		{name: "country prefix", raw: "KZ 123ABC02", want: "123ABC02", wantFormat: "individual"},
+		{name: "region out of range", raw: "123ABC99", want: "123ABC99", wantFormat: ""},
+		{name: "region zero", raw: "123ABC00", want: "123ABC00", wantFormat: ""},
+		{name: "unknown format", raw: "12AB", want: "12AB", wantFormat: ""},
+		{name: "empty", raw: " ", want: "", wantFormat: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, format := Canonical(tt.raw)
+			if got != tt.want {
+				t.Errorf("Canonical(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+			gotFormat := ""
+			if format != nil {
+				gotFormat = format.Name
+			}
+			if gotFormat != tt.wantFormat {
+				t.Errorf("Canonical(%q) format = %q, want %q", tt.raw, gotFormat, tt.wantFormat)
+			}
+			if valid := Valid(tt.raw); valid != (tt.wantFormat != "") {
+				t.Errorf("Valid(%q) = %v", tt.raw, valid)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		want      float64
+		wantMatch bool
+	}{
+		{name: "identical", a: "123ABC02", b: "123ABC02", want: 1, wantMatch: true},
+		{name: "spacing and case", a: "123ABC02", b: "123 abc 02", want: 1, wantMatch: true},
+		{name: "cyrillic and latin", a: "123ABC02", b: "123 АВС 02", want: 1, wantMatch: true},
+		{name: "confusable fixed by format", a: "123ABC02", b: "123ABCO2", want: 1, wantMatch: true},
+		// Вне известного формата путаемая замена стоит confusableCost: 1 - 0.3/4,
+		// но номера сравниваются только точно
+		{name: "confusable outside format", a: "ABC0", b: "ABCO", want: 0.925, wantMatch: false},
+		{name: "identical outside format", a: "abc-0", b: "ABC0", want: 1, wantMatch: true},
+		// Длинная строка не в формате РК с двумя путаемыми заменами: 1 - 0.6/12 проходит порог
+		{name: "long plate outside format", a: "ABCDEFGHIJ0O", b: "ABCDEFGHIJO0", want: 0.95, wantMatch: false},
+		// Один номер в формате РК, другой нет
+		{name: "one plate outside format", a: "123ABC02", b: "KZ123ABC02X", want: 1 - 3.0/11, wantMatch: false},
+		// Обычная замена одного символа из восьми: 1 - 1/8
+		{name: "other substitution", a: "123ABC02", b: "123ABD02", want: 0.875, wantMatch: false},
+		{name: "missing character", a: "123ABC02", b: "123AB02", want: 0.875, wantMatch: false},
+		{name: "different plates", a: "123ABC02", b: "999ZZZ01", want: 0.125, wantMatch: false},
+		{name: "empty", a: "123ABC02", b: "", want: 0, wantMatch: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(tt.a, tt.b)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if reverse := Similarity(tt.b, tt.a); math.Abs(reverse-got) > 1e-9 {
+				t.Errorf("Similarity is not symmetric: %v vs %v", got, reverse)
+			}
+			if match := Matches(tt.a, tt.b); match != tt.wantMatch {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tt.a, tt.b, match, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{
+			name: "one substitution per confusable position",
+			raw:  "123ABC02",
+			want: []string{"123ABC02", "I23ABC02", "123A8C02", "123ABCO2"},
+		},
+		{
+			name: "misread input gives the same variants",
+			raw:  "123 abc o2",
+			want: []string{"123ABC02", "I23ABC02", "123A8C02", "123ABCO2"},
+		},
+		{
+			name: "no confusable characters",
+			raw:  "456KZA05",
+			want: []string{"456KZA05", "456KZAO5"},
+		},
+		{
+			name: "unknown format has no substitutions",
+			raw:  "abc-0",
+			want: []string{"ABC0"},
+		},
+		{
+			name: "original spelling is kept",
+			raw:  "KZ123ABC02",
+			want: []string{"123ABC02", "I23ABC02", "123A8C02", "123ABCO2", "KZ123ABC02"},
+		},
+		{name: "empty", raw: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Variants(tt.raw)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Variants(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+			for _, variant := range got {
+				if !Matches(variant, tt.raw) {
+					t.Errorf("variant %q does not match %q", variant, tt.raw)
+				}
+			}
+		})
+	}
+}
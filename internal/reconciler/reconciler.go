@@ -0,0 +1,157 @@
+// Package reconciler - фоновое повторное сопоставление рейсов без назначения.
+// Рейс может приехать раньше, чем диспетчер создаст назначение; такие рейсы
+// периодически пересматриваются и привязываются к тикетам задним числом.
+package reconciler
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"ticket-service/internal/config"
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+	"ticket-service/internal/service"
+)
+
+type Reconciler struct {
+	tripRepo           *repository.TripRepository
+	reconciliationRepo *repository.ReconciliationRepository
+	tripService        *service.TripService
+	cfg                config.ReconcilerConfig
+	log                zerolog.Logger
+}
+
+func New(
+	tripRepo *repository.TripRepository,
+	reconciliationRepo *repository.ReconciliationRepository,
+	tripService *service.TripService,
+	cfg config.ReconcilerConfig,
+	log zerolog.Logger,
+) *Reconciler {
+	return &Reconciler{
+		tripRepo:           tripRepo,
+		reconciliationRepo: reconciliationRepo,
+		tripService:        tripService,
+		cfg:                cfg,
+		log:                log,
+	}
+}
+
+// Stats - итоги одного прохода
+type Stats struct {
+	Scanned   int
+	Attached  int
+	Ambiguous int
+	Skipped   int
+	Failed    int
+}
+
+// Run выполняет проходы с периодом Interval до отмены контекста
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			r.log.Error().Err(err).Msg("trip reconciliation failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce пересматривает рейсы без назначения, въехавшие в пределах окна Lookback
+func (r *Reconciler) RunOnce(ctx context.Context) (Stats, error) {
+	var stats Stats
+
+	afterEntryAt := time.Now().Add(-r.cfg.Lookback)
+	afterID := uuid.Nil
+
+	for {
+		trips, err := r.tripRepo.ListUnassigned(ctx, afterEntryAt, afterID, r.cfg.BatchSize)
+		if err != nil {
+			return stats, err
+		}
+
+		for i := range trips {
+			trip := &trips[i]
+			afterEntryAt, afterID = trip.EntryAt, trip.ID
+			stats.Scanned++
+
+			if err := r.reconcile(ctx, trip, &stats); err != nil {
+				if ctx.Err() != nil {
+					return stats, ctx.Err()
+				}
+				stats.Failed++
+				r.log.Warn().Err(err).Str("trip_id", trip.ID.String()).Msg("failed to reconcile trip")
+			}
+		}
+
+		if len(trips) < r.cfg.BatchSize {
+			break
+		}
+	}
+
+	if stats.Attached > 0 || stats.Ambiguous > 0 || stats.Failed > 0 {
+		r.log.Info().
+			Int("scanned", stats.Scanned).
+			Int("attached", stats.Attached).
+			Int("ambiguous", stats.Ambiguous).
+			Int("skipped", stats.Skipped).
+			Int("failed", stats.Failed).
+			Msg("trip reconciliation finished")
+	}
+
+	return stats, nil
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, trip *model.Trip, stats *Stats) error {
+	entry, err := r.tripService.ReconcileTrip(ctx, trip)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+
+	switch entry.Action {
+	case model.ReconciliationActionAttached:
+		stats.Attached++
+	case model.ReconciliationActionAmbiguous:
+		stats.Ambiguous++
+	case model.ReconciliationActionSkipped:
+		stats.Skipped++
+	}
+
+	// Неоднозначные и пропущенные рейсы пересматриваются на каждом проходе,
+	// в журнал они попадают один раз
+	if entry.Action != model.ReconciliationActionAttached {
+		logged, err := r.reconciliationRepo.HasAction(ctx, trip.ID, entry.Action)
+		if err != nil {
+			return err
+		}
+		if logged {
+			return nil
+		}
+	}
+
+	if err := r.reconciliationRepo.Create(ctx, entry); err != nil {
+		return err
+	}
+
+	if entry.Action == model.ReconciliationActionAttached {
+		r.log.Info().
+			Str("trip_id", trip.ID.String()).
+			Str("ticket_id", trip.TicketID.String()).
+			Str("status", string(entry.NewStatus)).
+			Msg("trip attached to assignment by reconciler")
+	}
+	return nil
+}
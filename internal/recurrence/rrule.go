@@ -0,0 +1,304 @@
+// Package recurrence - правила повторения в духе RFC 5545 RRULE для расписаний тикетов.
+// Поддерживается подмножество, достаточное для уборки по расписанию:
+// FREQ=DAILY|WEEKLY|MONTHLY, INTERVAL, BYDAY, BYMONTHDAY, COUNT, UNTIL.
+// Правило порождает даты (полночь в часовом поясе начала серии), время суток задает расписание.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxEmptyPeriods - сколько периодов подряд без дат допускается, прежде чем считать,
+// что правило больше ничего не породит (например, BYMONTHDAY=31 с INTERVAL=12 от февраля)
+const maxEmptyPeriods = 1000
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// ByDay - дни недели; для MONTHLY - все такие дни месяца
+	ByDay []time.Weekday
+	// ByMonthDay - дни месяца 1..31 или -31..-1 (от конца месяца)
+	ByMonthDay []int
+	// Count - сколько дат породить всего (0 - без ограничения)
+	Count int
+	// Until - последняя допустимая дата (включительно)
+	Until *time.Time
+}
+
+// Parse разбирает правило вида "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,WE,FR".
+// Префикс "RRULE:" допускается. UNTIL - дата YYYYMMDD (или YYYYMMDDTHHMMSSZ, время игнорируется).
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1}
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return rule, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || val == "" {
+			return rule, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		key = strings.ToUpper(key)
+		if seen[key] {
+			return rule, fmt.Errorf("%w: duplicate %s", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(val))
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				return rule, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRule, val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > 366 {
+				return rule, fmt.Errorf("%w: invalid INTERVAL %q", ErrInvalidRule, val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("%w: invalid COUNT %q", ErrInvalidRule, val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			datePart, _, _ := strings.Cut(val, "T")
+			until, err := time.Parse("20060102", datePart)
+			if err != nil {
+				return rule, fmt.Errorf("%w: invalid UNTIL %q", ErrInvalidRule, val)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, ok := weekdayCodes[strings.ToUpper(strings.TrimSpace(code))]
+				if !ok {
+					return rule, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRule, code)
+				}
+				if !slices.Contains(rule.ByDay, day) {
+					rule.ByDay = append(rule.ByDay, day)
+				}
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(item))
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return rule, fmt.Errorf("%w: invalid BYMONTHDAY %q", ErrInvalidRule, item)
+				}
+				if !slices.Contains(rule.ByMonthDay, n) {
+					rule.ByMonthDay = append(rule.ByMonthDay, n)
+				}
+			}
+		default:
+			return rule, fmt.Errorf("%w: unsupported %s", ErrInvalidRule, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return rule, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+// String возвращает правило в каноническом виде
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, 0, len(r.ByDay))
+		for _, day := range r.sortedByDay() {
+			codes = append(codes, weekdayCode(day))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Dates перечисляет даты серии по возрастанию, начиная с даты start (включительно, если подходит).
+// Даты возвращаются как полночь в часовом поясе start. COUNT отсчитывается от start.
+func (r Rule) Dates(start time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		first := dateOf(start)
+		produced := 0
+		emptyPeriods := 0
+
+		for period := 0; ; period++ {
+			dates := r.periodDates(first, period)
+			if len(dates) == 0 {
+				emptyPeriods++
+				if emptyPeriods > maxEmptyPeriods {
+					return
+				}
+				continue
+			}
+			emptyPeriods = 0
+
+			for _, date := range dates {
+				if date.Before(first) {
+					continue
+				}
+				if r.Until != nil && date.After(dateIn(*r.Until, first.Location())) {
+					return
+				}
+				if !yield(date) {
+					return
+				}
+				produced++
+				if r.Count > 0 && produced >= r.Count {
+					return
+				}
+			}
+		}
+	}
+}
+
+// periodDates - подходящие даты периода с номером period (день, неделя или месяц от начала серии)
+func (r Rule) periodDates(first time.Time, period int) []time.Time {
+	step := period * max(r.Interval, 1)
+
+	switch r.Freq {
+	case Daily:
+		date := first.AddDate(0, 0, step)
+		if r.matchesDay(date) && r.matchesMonthDay(date) {
+			return []time.Time{date}
+		}
+		return nil
+
+	case Weekly:
+		// Неделя начинается с понедельника (WKST=MO)
+		offset := (int(first.Weekday()) + 6) % 7
+		weekStart := first.AddDate(0, 0, -offset+7*step)
+		days := r.sortedByDay()
+		if len(days) == 0 {
+			days = []time.Weekday{first.Weekday()}
+		}
+		dates := make([]time.Time, 0, len(days))
+		for _, day := range days {
+			date := weekStart.AddDate(0, 0, (int(day)+6)%7)
+			if r.matchesMonthDay(date) {
+				dates = append(dates, date)
+			}
+		}
+		return dates
+
+	case Monthly:
+		monthStart := time.Date(first.Year(), first.Month()+time.Month(step), 1, 0, 0, 0, 0, first.Location())
+		daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+
+		var dates []time.Time
+		switch {
+		case len(r.ByMonthDay) > 0:
+			for day := 1; day <= daysInMonth; day++ {
+				date := monthStart.AddDate(0, 0, day-1)
+				if r.matchesMonthDay(date) && r.matchesDay(date) {
+					dates = append(dates, date)
+				}
+			}
+		case len(r.ByDay) > 0:
+			for day := 1; day <= daysInMonth; day++ {
+				date := monthStart.AddDate(0, 0, day-1)
+				if r.matchesDay(date) {
+					dates = append(dates, date)
+				}
+			}
+		default:
+			// Тот же день месяца, что и у начала серии; в коротких месяцах пропускается
+			if first.Day() <= daysInMonth {
+				dates = append(dates, monthStart.AddDate(0, 0, first.Day()-1))
+			}
+		}
+		return dates
+	}
+	return nil
+}
+
+func (r Rule) matchesDay(date time.Time) bool {
+	return len(r.ByDay) == 0 || slices.Contains(r.ByDay, date.Weekday())
+}
+
+func (r Rule) matchesMonthDay(date time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, date.Location()).Day()
+	for _, day := range r.ByMonthDay {
+		if day == date.Day() || (day < 0 && daysInMonth+day+1 == date.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) sortedByDay() []time.Weekday {
+	days := slices.Clone(r.ByDay)
+	// Понедельник - первый день недели
+	slices.SortFunc(days, func(a, b time.Weekday) int {
+		return (int(a)+6)%7 - (int(b)+6)%7
+	})
+	return days
+}
+
+func weekdayCode(day time.Weekday) string {
+	for code, d := range weekdayCodes {
+		if d == day {
+			return code
+		}
+	}
+	return ""
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dateIn переносит календарную дату t в часовой пояс loc
+func dateIn(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
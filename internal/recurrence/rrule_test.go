@@ -0,0 +1,182 @@
+package recurrence
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{value: "RRULE:freq=weekly;byday=fr,mo;interval=2", want: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{value: "FREQ=WEEKLY;BYDAY=MO,MO,SU", want: "FREQ=WEEKLY;BYDAY=MO,SU"},
+		{value: "FREQ=MONTHLY;BYMONTHDAY=-1,15;COUNT=3", want: "FREQ=MONTHLY;BYMONTHDAY=-1,15;COUNT=3"},
+		{value: "FREQ=DAILY;INTERVAL=1", want: "FREQ=DAILY"},
+		// Время в UNTIL игнорируется
+		{value: "FREQ=DAILY;UNTIL=20250110T235959Z", want: "FREQ=DAILY;UNTIL=20250110"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.value)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", tt.value, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"FREQ",
+		"FREQ=YEARLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=DAILY;UNTIL=2025-01-01",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;BYSETPOS=1",
+	}
+	for _, value := range tests {
+		if _, err := Parse(value); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q): got error %v, want ErrInvalidRule", value, err)
+		}
+	}
+}
+
+func TestDates(t *testing.T) {
+	almaty, err := time.LoadLocation("Asia/Almaty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		// limit - сколько дат взять у правила без COUNT/UNTIL
+		limit int
+		want  []string
+	}{
+		{
+			name:  "daily interval with until",
+			rule:  "FREQ=DAILY;INTERVAL=3;UNTIL=20250110",
+			start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-01", "2025-01-04", "2025-01-07", "2025-01-10"},
+		},
+		{
+			name:  "daily by day",
+			rule:  "FREQ=DAILY;BYDAY=SA,SU;UNTIL=20250112",
+			start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-04", "2025-01-05", "2025-01-11", "2025-01-12"},
+		},
+		{
+			name:  "time of day of start is ignored",
+			rule:  "FREQ=DAILY;COUNT=2",
+			start: time.Date(2025, 1, 1, 23, 30, 0, 0, time.UTC),
+			want:  []string{"2025-01-01", "2025-01-02"},
+		},
+		{
+			// Серия начинается в среду: понедельник той же недели раньше начала и в COUNT не входит,
+			// следующие недели серии отсчитываются по две от недели начала
+			name:  "weekly interval aligned to week of start",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=5",
+			start: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-17", "2025-01-27", "2025-01-31", "2025-02-10", "2025-02-14"},
+		},
+		{
+			// Неделя начинается с понедельника: воскресенье - последний день недели начала
+			name:  "weekly interval from sunday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2",
+			start: time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC),
+			limit: 3,
+			want:  []string{"2025-01-19", "2025-02-02", "2025-02-16"},
+		},
+		{
+			name:  "weekly by day with until",
+			rule:  "FREQ=WEEKLY;BYDAY=SU,MO;UNTIL=20250120",
+			start: time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-13", "2025-01-19", "2025-01-20"},
+		},
+		{
+			name:  "monthly last day",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=4",
+			start: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-31", "2025-02-28", "2025-03-31", "2025-04-30"},
+		},
+		{
+			name:  "monthly negative day in leap year",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=1,-2;COUNT=4",
+			start: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2024-02-01", "2024-02-28", "2024-03-01", "2024-03-30"},
+		},
+		{
+			name:  "monthly day of start skips short months",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-31", "2025-03-31", "2025-05-31"},
+		},
+		{
+			name:  "monthly by day",
+			rule:  "FREQ=MONTHLY;INTERVAL=2;BYDAY=MO;COUNT=6",
+			start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-06", "2025-01-13", "2025-01-20", "2025-01-27", "2025-03-03", "2025-03-10"},
+		},
+		{
+			name:  "until before start",
+			rule:  "FREQ=DAILY;UNTIL=20241231",
+			start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  nil,
+		},
+		{
+			// В феврале нет 30-го числа, а другие месяцы правило не выбирает
+			name:  "rule without dates terminates",
+			rule:  "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30",
+			start: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+			limit: 1,
+			want:  nil,
+		},
+		{
+			// UNTIL - календарная дата в часовом поясе серии
+			name:  "until in time zone of start",
+			rule:  "FREQ=DAILY;UNTIL=20250102",
+			start: time.Date(2025, 1, 1, 0, 0, 0, 0, almaty),
+			want:  []string{"2025-01-01", "2025-01-02"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+
+			var got []string
+			for date := range rule.Dates(tt.start) {
+				if date.Location() != tt.start.Location() || date.Hour() != 0 || date.Minute() != 0 {
+					t.Errorf("date %v is not midnight in %v", date, tt.start.Location())
+				}
+				got = append(got, date.Format("2006-01-02"))
+				if tt.limit > 0 && len(got) >= tt.limit {
+					break
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got dates %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -56,6 +56,34 @@ func (r *AppealRepository) ListByTripID(ctx context.Context, tripID uuid.UUID) (
 	return appeals, err
 }
 
+// FindOpenByTripAndUser находит нерассмотренное обжалование пользователя по рейсу
+// (по конкретному нарушению, если violationID указан, иначе по рейсу целиком)
+func (r *AppealRepository) FindOpenByTripAndUser(ctx context.Context, tripID, userID uuid.UUID, violationID *uuid.UUID) (*model.Appeal, error) {
+	var appeal model.Appeal
+	query := r.db.WithContext(ctx).
+		Where("trip_id = ? AND created_by_user_id = ?", tripID, userID)
+	if violationID != nil {
+		query = query.Where("trip_violation_id = ?", *violationID)
+	} else {
+		query = query.Where("trip_violation_id IS NULL")
+	}
+	err := query.
+		Where("status IN ?", []model.AppealStatus{
+			model.AppealStatusSubmitted,
+			model.AppealStatusUnderReview,
+			model.AppealStatusNeedInfo,
+		}).
+		Order("created_at DESC").
+		First(&appeal).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &appeal, nil
+}
+
 func (r *AppealRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]model.Appeal, error) {
 	var appeals []model.Appeal
 	err := r.db.WithContext(ctx).
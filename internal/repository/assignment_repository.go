@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"ticket-service/internal/model"
 )
@@ -60,30 +63,343 @@ func (r *AssignmentRepository) ListByTicketID(ctx context.Context, ticketID uuid
 	return assignments, err
 }
 
+// ListHistoryByTicketID возвращает все назначения тикета, включая снятые, в порядке назначения
+func (r *AssignmentRepository) ListHistoryByTicketID(ctx context.Context, ticketID uuid.UUID) ([]model.TicketAssignment, error) {
+	var assignments []model.TicketAssignment
+	err := r.db.WithContext(ctx).
+		Where("ticket_id = ?", ticketID).
+		Order("assigned_at ASC, created_at ASC").
+		Find(&assignments).Error
+	return assignments, err
+}
+
 func (r *AssignmentRepository) UpdateDriverMarkStatus(ctx context.Context, id string, status model.DriverMarkStatus) error {
 	return r.db.WithContext(ctx).Model(&model.TicketAssignment{}).
 		Where("id = ?", id).
 		Update("driver_mark_status", status).Error
 }
 
-// UpdateTripStartedAt обновляет время начала рейса и статус
-func (r *AssignmentRepository) UpdateTripStartedAt(ctx context.Context, id string, startedAt time.Time, status model.DriverMarkStatus) error {
-	return r.db.WithContext(ctx).Model(&model.TicketAssignment{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"trip_started_at":    startedAt,
-			"driver_mark_status": status,
-		}).Error
+// lockAssignment читает назначение с блокировкой строки до конца транзакции
+func lockAssignment(tx *gorm.DB, assignmentID uuid.UUID) (*model.TicketAssignment, error) {
+	var assignment model.TicketAssignment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", assignmentID).First(&assignment).Error; err != nil {
+		return nil, err
+	}
+	return &assignment, nil
 }
 
-// UpdateTripFinishedAt обновляет время окончания рейса и статус
-func (r *AssignmentRepository) UpdateTripFinishedAt(ctx context.Context, id string, finishedAt time.Time, status model.DriverMarkStatus) error {
-	return r.db.WithContext(ctx).Model(&model.TicketAssignment{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"trip_finished_at":   finishedAt,
-			"driver_mark_status": status,
-		}).Error
+// changeDriverStatus переводит заблокированное назначение в статус to с дополнительными полями
+// updates и записывает событие журнала driver_status_events (время события - event.CreatedAt)
+func changeDriverStatus(tx *gorm.DB, assignment *model.TicketAssignment, to model.DriverMarkStatus, updates map[string]interface{}, event *model.DriverStatusEvent) error {
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["driver_mark_status"] = to
+	if err := tx.Model(&model.TicketAssignment{}).Where("id = ?", assignment.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	event.AssignmentID = assignment.ID
+	event.FromStatus = assignment.DriverMarkStatus
+	event.ToStatus = to
+	return tx.Create(event).Error
+}
+
+// StartRound открывает новый рейс назначения и переводит назначение в IN_WORK
+// (trip_started_at - время первого рейса смены). Рейс можно начать из NOT_STARTED или IN_WORK без
+// открытого рейса; иначе (рейс уже идет, пауза, смена завершена) ничего не делает и возвращает nil.
+func (r *AssignmentRepository) StartRound(ctx context.Context, assignmentID uuid.UUID, event *model.DriverStatusEvent) (*model.AssignmentRound, error) {
+	now := event.CreatedAt
+	var round *model.AssignmentRound
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		assignment, err := lockAssignment(tx, assignmentID)
+		if err != nil {
+			return err
+		}
+		if assignment.DriverMarkStatus != model.DriverMarkStatusNotStarted && assignment.DriverMarkStatus != model.DriverMarkStatusInWork {
+			return nil
+		}
+
+		var open int64
+		if err := tx.Model(&model.AssignmentRound{}).
+			Where("assignment_id = ? AND finished_at IS NULL", assignmentID).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return nil
+		}
+
+		var last int
+		if err := tx.Model(&model.AssignmentRound{}).
+			Where("assignment_id = ?", assignmentID).
+			Select("COALESCE(MAX(number), 0)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+
+		round = &model.AssignmentRound{AssignmentID: assignmentID, Number: last + 1, StartedAt: now}
+		if err := tx.Create(round).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		if assignment.TripStartedAt == nil {
+			updates["trip_started_at"] = now
+		}
+		return changeDriverStatus(tx, assignment, model.DriverMarkStatusInWork, updates, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// FinishRound закрывает открытый рейс назначения (в IN_WORK или на паузе). Статус назначения
+// не меняется до конца смены. Возвращает nil, если открытого рейса нет.
+func (r *AssignmentRepository) FinishRound(ctx context.Context, assignmentID uuid.UUID, event *model.DriverStatusEvent) (*model.AssignmentRound, error) {
+	var round *model.AssignmentRound
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		assignment, err := lockAssignment(tx, assignmentID)
+		if err != nil {
+			return err
+		}
+		if assignment.DriverMarkStatus != model.DriverMarkStatusInWork && assignment.DriverMarkStatus != model.DriverMarkStatusPaused {
+			return nil
+		}
+
+		if round, err = finishOpenRound(tx, assignmentID, event.CreatedAt); err != nil || round == nil {
+			return err
+		}
+		return changeDriverStatus(tx, assignment, assignment.DriverMarkStatus, nil, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+func finishOpenRound(db *gorm.DB, assignmentID uuid.UUID, now time.Time) (*model.AssignmentRound, error) {
+	var round model.AssignmentRound
+	result := db.Model(&round).
+		Clauses(clause.Returning{}).
+		Where("assignment_id = ? AND finished_at IS NULL", assignmentID).
+		Update("finished_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &round, nil
+}
+
+// Pause переводит назначение из IN_WORK в PAUSED (paused_at - начало паузы).
+// Возвращает false, если назначение не в IN_WORK.
+func (r *AssignmentRepository) Pause(ctx context.Context, assignmentID uuid.UUID, event *model.DriverStatusEvent) (bool, error) {
+	paused := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		assignment, err := lockAssignment(tx, assignmentID)
+		if err != nil {
+			return err
+		}
+		if assignment.DriverMarkStatus != model.DriverMarkStatusInWork {
+			return nil
+		}
+		paused = true
+		return changeDriverStatus(tx, assignment, model.DriverMarkStatusPaused,
+			map[string]interface{}{"paused_at": event.CreatedAt}, event)
+	})
+	return paused, err
+}
+
+// Resume возвращает назначение с паузы в IN_WORK. Возвращает false, если назначение не на паузе.
+func (r *AssignmentRepository) Resume(ctx context.Context, assignmentID uuid.UUID, event *model.DriverStatusEvent) (bool, error) {
+	resumed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		assignment, err := lockAssignment(tx, assignmentID)
+		if err != nil {
+			return err
+		}
+		if assignment.DriverMarkStatus != model.DriverMarkStatusPaused {
+			return nil
+		}
+		resumed = true
+		return changeDriverStatus(tx, assignment, model.DriverMarkStatusInWork,
+			map[string]interface{}{"paused_at": nil}, event)
+	})
+	return resumed, err
+}
+
+// EndShift завершает смену водителя по назначению (из IN_WORK или с паузы): закрывает открытый рейс,
+// если он есть, и переводит назначение в SHIFT_ENDED (shift_ended_at и trip_finished_at - время
+// события). Возвращает закрытый рейс (nil, если открытого не было) и false, если смена не идет.
+func (r *AssignmentRepository) EndShift(ctx context.Context, assignmentID uuid.UUID, event *model.DriverStatusEvent) (*model.AssignmentRound, bool, error) {
+	now := event.CreatedAt
+	var round *model.AssignmentRound
+	ended := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		assignment, err := lockAssignment(tx, assignmentID)
+		if err != nil {
+			return err
+		}
+		if assignment.DriverMarkStatus != model.DriverMarkStatusInWork && assignment.DriverMarkStatus != model.DriverMarkStatusPaused {
+			return nil
+		}
+
+		if round, err = finishOpenRound(tx, assignmentID, now); err != nil {
+			return err
+		}
+		ended = true
+		return changeDriverStatus(tx, assignment, model.DriverMarkStatusShiftEnded, map[string]interface{}{
+			"shift_ended_at":   now,
+			"trip_finished_at": now,
+			"paused_at":        nil,
+		}, event)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return round, ended, nil
+}
+
+// ChangeVehicle заменяет машину назначения (например, после поломки), если новая машина не назначена
+// на другие активные тикеты с окном, пересекающимся с [start, end); иначе ничего не меняет и
+// возвращает пересечения. Открытый рейс закрывается: он выполнен на прежней машине, и по нему
+// создается свой trip. Статус отметки водителя не меняется. Уже созданные рейсы сохраняют прежнюю машину.
+func (r *AssignmentRepository) ChangeVehicle(ctx context.Context, assignmentID, vehicleID uuid.UUID, start, end time.Time, event *model.DriverStatusEvent) (*model.AssignmentRound, []AssignmentBooking, error) {
+	var round *model.AssignmentRound
+	var conflicts []AssignmentBooking
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "vehicle:"+vehicleID.String()).Error; err != nil {
+			return err
+		}
+		assignment, err := lockAssignment(tx, assignmentID)
+		if err != nil {
+			return err
+		}
+
+		if conflicts, err = listBookings(tx, nil, []uuid.UUID{vehicleID}, start, end, assignment.TicketID); err != nil || len(conflicts) > 0 {
+			return err
+		}
+
+		if round, err = finishOpenRound(tx, assignmentID, event.CreatedAt); err != nil {
+			return err
+		}
+		oldVehicleID := assignment.VehicleID
+		event.OldVehicleID = &oldVehicleID
+		event.NewVehicleID = &vehicleID
+		return changeDriverStatus(tx, assignment, assignment.DriverMarkStatus,
+			map[string]interface{}{"vehicle_id": vehicleID}, event)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return round, conflicts, nil
+}
+
+// Reassign снимает активное назначение и создает его преемника successor (тот же тикет, новые водитель
+// и/или машина, previous_assignment_id - снятое назначение), если водитель и машина преемника не заняты
+// на других активных тикетах с окном, пересекающимся с [start, end); иначе ничего не меняет и возвращает
+// пересечения. Открытый рейс закрывается и остается за снятым назначением, начатая смена завершается
+// (SHIFT_ENDED). Преемник начинает с NOT_STARTED. Возвращает false, если назначение уже снято или
+// смена по нему завершена.
+func (r *AssignmentRepository) Reassign(ctx context.Context, assignmentID uuid.UUID, successor *model.TicketAssignment, start, end time.Time, event *model.DriverStatusEvent) (*model.AssignmentRound, []AssignmentBooking, bool, error) {
+	now := event.CreatedAt
+	var round *model.AssignmentRound
+	var conflicts []AssignmentBooking
+	reassigned := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Тот же порядок блокировок, что и в CreateIfFree
+		for _, key := range []string{"driver:" + successor.DriverID.String(), "vehicle:" + successor.VehicleID.String()} {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", key).Error; err != nil {
+				return err
+			}
+		}
+		assignment, err := lockAssignment(tx, assignmentID)
+		if err != nil {
+			return err
+		}
+		if !assignment.IsActive || assignment.DriverMarkStatus.IsFinished() {
+			return nil
+		}
+
+		if conflicts, err = listBookings(tx, []uuid.UUID{successor.DriverID}, []uuid.UUID{successor.VehicleID}, start, end, assignment.TicketID); err != nil || len(conflicts) > 0 {
+			return err
+		}
+
+		if round, err = finishOpenRound(tx, assignmentID, now); err != nil {
+			return err
+		}
+
+		to := assignment.DriverMarkStatus
+		updates := map[string]interface{}{
+			"is_active":     false,
+			"unassigned_at": now,
+			"paused_at":     nil,
+		}
+		if to == model.DriverMarkStatusInWork || to == model.DriverMarkStatusPaused {
+			to = model.DriverMarkStatusShiftEnded
+			updates["shift_ended_at"] = now
+			updates["trip_finished_at"] = now
+		}
+		if assignment.VehicleID != successor.VehicleID {
+			oldVehicleID := assignment.VehicleID
+			event.OldVehicleID = &oldVehicleID
+			event.NewVehicleID = &successor.VehicleID
+		}
+		if err := changeDriverStatus(tx, assignment, to, updates, event); err != nil {
+			return err
+		}
+
+		successor.TicketID = assignment.TicketID
+		successor.PreviousAssignmentID = &assignment.ID
+		successor.DriverMarkStatus = model.DriverMarkStatusNotStarted
+		successor.AssignedAt = now
+		successor.IsActive = true
+		if err := tx.Create(successor).Error; err != nil {
+			return err
+		}
+		reassigned = true
+		return nil
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return round, conflicts, reassigned, nil
+}
+
+// ListStatusEvents возвращает журнал driver_status_events назначений в хронологическом порядке
+func (r *AssignmentRepository) ListStatusEvents(ctx context.Context, assignmentIDs []uuid.UUID) ([]model.DriverStatusEvent, error) {
+	if len(assignmentIDs) == 0 {
+		return nil, nil
+	}
+	var events []model.DriverStatusEvent
+	err := r.db.WithContext(ctx).
+		Where("assignment_id IN ?", assignmentIDs).
+		Order("created_at ASC, id ASC").
+		Find(&events).Error
+	return events, err
+}
+
+// SetRoundTrip связывает рейс назначения с созданным по нему trip
+func (r *AssignmentRepository) SetRoundTrip(ctx context.Context, roundID, tripID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&model.AssignmentRound{}).
+		Where("id = ?", roundID).
+		Update("trip_id", tripID).Error
+}
+
+// ListRounds возвращает рейсы назначений по порядку номеров
+func (r *AssignmentRepository) ListRounds(ctx context.Context, assignmentIDs []uuid.UUID) ([]model.AssignmentRound, error) {
+	if len(assignmentIDs) == 0 {
+		return nil, nil
+	}
+	var rounds []model.AssignmentRound
+	err := r.db.WithContext(ctx).
+		Where("assignment_id IN ?", assignmentIDs).
+		Order("assignment_id, number ASC").
+		Find(&rounds).Error
+	return rounds, err
 }
 
 func (r *AssignmentRepository) HasActiveAssignment(ctx context.Context, ticketID, driverID uuid.UUID) (bool, error) {
@@ -97,6 +413,21 @@ func (r *AssignmentRepository) HasActiveAssignment(ctx context.Context, ticketID
 	return count > 0, nil
 }
 
+// FindActiveDuplicate находит активное назначение с теми же тикетом, водителем и машиной
+func (r *AssignmentRepository) FindActiveDuplicate(ctx context.Context, ticketID, driverID, vehicleID uuid.UUID) (*model.TicketAssignment, error) {
+	var assignment model.TicketAssignment
+	err := r.db.WithContext(ctx).
+		Where("ticket_id = ? AND driver_id = ? AND vehicle_id = ? AND is_active = ?", ticketID, driverID, vehicleID, true).
+		First(&assignment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &assignment, nil
+}
+
 func (r *AssignmentRepository) FindActiveByDriver(ctx context.Context, driverID uuid.UUID) (*model.TicketAssignment, error) {
 	var assignment model.TicketAssignment
 	err := r.db.WithContext(ctx).
@@ -127,6 +458,213 @@ func (r *AssignmentRepository) FindActiveByVehicle(ctx context.Context, vehicleI
 	return &assignment, nil
 }
 
+// AssignmentBooking - активное назначение водителя и машины вместе с плановым окном тикета
+type AssignmentBooking struct {
+	AssignmentID   uuid.UUID `json:"assignment_id"`
+	TicketID       uuid.UUID `json:"ticket_id"`
+	DriverID       uuid.UUID `json:"driver_id"`
+	VehicleID      uuid.UUID `json:"vehicle_id"`
+	PlannedStartAt time.Time `json:"planned_start_at"`
+	PlannedEndAt   time.Time `json:"planned_end_at"`
+}
+
+// ListBookings возвращает активные назначения водителей driverIDs или машин vehicleIDs на активные
+// тикеты (PLANNED, IN_PROGRESS), плановое окно которых пересекается с [start, end).
+// Назначения на тикет excludeTicketID не учитываются (uuid.Nil - учитывать все).
+func (r *AssignmentRepository) ListBookings(ctx context.Context, driverIDs, vehicleIDs []uuid.UUID, start, end time.Time, excludeTicketID uuid.UUID) ([]AssignmentBooking, error) {
+	return listBookings(r.db.WithContext(ctx), driverIDs, vehicleIDs, start, end, excludeTicketID)
+}
+
+func listBookings(db *gorm.DB, driverIDs, vehicleIDs []uuid.UUID, start, end time.Time, excludeTicketID uuid.UUID) ([]AssignmentBooking, error) {
+	if len(driverIDs) == 0 && len(vehicleIDs) == 0 {
+		return nil, nil
+	}
+
+	query := db.Table("ticket_assignments ta").
+		Select("ta.id AS assignment_id, ta.ticket_id, ta.driver_id, ta.vehicle_id, t.planned_start_at, t.planned_end_at").
+		Joins("JOIN tickets t ON t.id = ta.ticket_id").
+		Where("ta.is_active = ?", true).
+		Where("t.status IN ?", []model.TicketStatus{model.TicketStatusPlanned, model.TicketStatusInProgress}).
+		Where("t.planned_start_at < ? AND t.planned_end_at > ?", end, start).
+		Where("ta.ticket_id <> ?", excludeTicketID)
+
+	switch {
+	case len(driverIDs) > 0 && len(vehicleIDs) > 0:
+		query = query.Where("(ta.driver_id IN ? OR ta.vehicle_id IN ?)", driverIDs, vehicleIDs)
+	case len(driverIDs) > 0:
+		query = query.Where("ta.driver_id IN ?", driverIDs)
+	default:
+		query = query.Where("ta.vehicle_id IN ?", vehicleIDs)
+	}
+
+	var bookings []AssignmentBooking
+	err := query.Order("t.planned_start_at ASC").Scan(&bookings).Error
+	return bookings, err
+}
+
+// ErrAssignmentOverlap - водитель или машина активного назначения тикета назначены на другой активный
+// тикет, плановое окно которого пересекается с новым окном тикета
+var ErrAssignmentOverlap = errors.New("ticket assignments overlap assignments to other active tickets")
+
+// checkTicketBookings проверяет активные назначения тикета по его текущему плановому окну
+// (в транзакции tx - уже с новыми значениями) и возвращает ErrAssignmentOverlap, если их водители
+// или машины заняты на других активных тикетах. Берет те же advisory-блокировки, что и CreateIfFree:
+// сначала водителей, затем машины, каждых в порядке идентификаторов.
+func checkTicketBookings(tx *gorm.DB, ticketID uuid.UUID) error {
+	var ticket model.Ticket
+	if err := tx.Select("id", "planned_start_at", "planned_end_at").Where("id = ?", ticketID).First(&ticket).Error; err != nil {
+		return err
+	}
+	var assignments []model.TicketAssignment
+	if err := tx.Where("ticket_id = ? AND is_active = ?", ticketID, true).Find(&assignments).Error; err != nil {
+		return err
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	var driverIDs, vehicleIDs []uuid.UUID
+	for _, assignment := range assignments {
+		if !slices.Contains(driverIDs, assignment.DriverID) {
+			driverIDs = append(driverIDs, assignment.DriverID)
+		}
+		if !slices.Contains(vehicleIDs, assignment.VehicleID) {
+			vehicleIDs = append(vehicleIDs, assignment.VehicleID)
+		}
+	}
+	compare := func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) }
+	slices.SortFunc(driverIDs, compare)
+	slices.SortFunc(vehicleIDs, compare)
+
+	var keys []string
+	for _, id := range driverIDs {
+		keys = append(keys, "driver:"+id.String())
+	}
+	for _, id := range vehicleIDs {
+		keys = append(keys, "vehicle:"+id.String())
+	}
+	for _, key := range keys {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", key).Error; err != nil {
+			return err
+		}
+	}
+
+	bookings, err := listBookings(tx, driverIDs, vehicleIDs, ticket.PlannedStartAt, ticket.PlannedEndAt, ticketID)
+	if err != nil {
+		return err
+	}
+	if len(bookings) > 0 {
+		return ErrAssignmentOverlap
+	}
+	return nil
+}
+
+// CreateIfFree создает назначение, если у его водителя и машины нет активных назначений на другие
+// активные тикеты с окном, пересекающимся с [start, end). Иначе ничего не создает и возвращает
+// пересечения. Проверка и запись выполняются под транзакционными advisory-блокировками водителя
+// и машины, поэтому параллельные запросы не назначат их дважды.
+func (r *AssignmentRepository) CreateIfFree(ctx context.Context, assignment *model.TicketAssignment, start, end time.Time) ([]AssignmentBooking, error) {
+	var conflicts []AssignmentBooking
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Блокировки берутся в одном порядке (водитель, затем машина), чтобы не было взаимоблокировок
+		for _, key := range []string{"driver:" + assignment.DriverID.String(), "vehicle:" + assignment.VehicleID.String()} {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", key).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		conflicts, err = listBookings(tx, []uuid.UUID{assignment.DriverID}, []uuid.UUID{assignment.VehicleID}, start, end, assignment.TicketID)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return nil
+		}
+		return tx.Create(assignment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// ListContractorFleet возвращает водителей и машины, которые когда-либо назначались на тикеты
+// подрядчика (справочники водителей и машин ведутся в других сервисах)
+func (r *AssignmentRepository) ListContractorFleet(ctx context.Context, contractorID uuid.UUID) ([]uuid.UUID, []uuid.UUID, error) {
+	base := func() *gorm.DB {
+		return r.db.WithContext(ctx).
+			Table("ticket_assignments ta").
+			Joins("JOIN tickets t ON t.id = ta.ticket_id").
+			Where("t.contractor_id = ?", contractorID)
+	}
+
+	var driverIDs, vehicleIDs []uuid.UUID
+	if err := base().Distinct("ta.driver_id").Order("ta.driver_id").Pluck("ta.driver_id", &driverIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := base().Distinct("ta.vehicle_id").Order("ta.vehicle_id").Pluck("ta.vehicle_id", &vehicleIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	return driverIDs, vehicleIDs, nil
+}
+
+// ListCandidatesForTrip возвращает назначения водителя или машины, действовавшие на момент at:
+// назначенные не позже at и либо активные, либо снятые после at
+func (r *AssignmentRepository) ListCandidatesForTrip(ctx context.Context, driverID, vehicleID *uuid.UUID, at time.Time) ([]model.TicketAssignment, error) {
+	if driverID == nil && vehicleID == nil {
+		return nil, nil
+	}
+
+	query := r.db.WithContext(ctx).
+		Where("assigned_at <= ?", at).
+		Where("(is_active = ? OR unassigned_at >= ?)", true, at)
+
+	switch {
+	case driverID != nil && vehicleID != nil:
+		query = query.Where("(driver_id = ? OR vehicle_id = ?)", *driverID, *vehicleID)
+	case driverID != nil:
+		query = query.Where("driver_id = ?", *driverID)
+	default:
+		query = query.Where("vehicle_id = ?", *vehicleID)
+	}
+
+	var assignments []model.TicketAssignment
+	err := query.Order("assigned_at DESC").Find(&assignments).Error
+	return assignments, err
+}
+
+// ListCandidatesForReconciliation возвращает назначения водителя или машины для повторного
+// сопоставления рейса с въездом в момент at. В отличие от ListCandidatesForTrip назначение может
+// быть создано после въезда (подрядчик оформил его задним числом), поэтому окно берется из тикета:
+// at должен попадать в плановый или фактический период работ. Снятые до at назначения не учитываются.
+func (r *AssignmentRepository) ListCandidatesForReconciliation(ctx context.Context, driverID, vehicleID *uuid.UUID, at time.Time) ([]model.TicketAssignment, error) {
+	if driverID == nil && vehicleID == nil {
+		return nil, nil
+	}
+
+	query := r.db.WithContext(ctx).
+		Table("ticket_assignments ta").
+		Select("ta.*").
+		Joins("JOIN tickets t ON t.id = ta.ticket_id").
+		Where("(ta.is_active = ? OR ta.unassigned_at >= ?)", true, at).
+		Where("((t.planned_start_at <= ? AND t.planned_end_at >= ?) OR "+
+			"(t.fact_start_at <= ? AND (t.fact_end_at IS NULL OR t.fact_end_at >= ?)))", at, at, at, at)
+
+	switch {
+	case driverID != nil && vehicleID != nil:
+		query = query.Where("(ta.driver_id = ? OR ta.vehicle_id = ?)", *driverID, *vehicleID)
+	case driverID != nil:
+		query = query.Where("ta.driver_id = ?", *driverID)
+	default:
+		query = query.Where("ta.vehicle_id = ?", *vehicleID)
+	}
+
+	var assignments []model.TicketAssignment
+	err := query.Order("ta.assigned_at DESC").Find(&assignments).Error
+	return assignments, err
+}
+
 // GetVehiclePlateNumber получает номер машины по vehicle_id из таблицы vehicles
 // Таблица vehicles может быть в общей БД или в другом сервисе
 func (r *AssignmentRepository) GetVehiclePlateNumber(ctx context.Context, vehicleID uuid.UUID) (string, error) {
@@ -149,3 +687,34 @@ func (r *AssignmentRepository) GetVehiclePlateNumber(ctx context.Context, vehicl
 
 	return result.PlateNumber, nil
 }
+
+// FindVehicleByPlate находит машину по номеру в таблице vehicles среди переданных написаний номера
+// (вариантов с учетом ошибок распознавания). Точное совпадение с canonical имеет приоритет.
+// Возвращает nil, если машина не найдена.
+func (r *AssignmentRepository) FindVehicleByPlate(ctx context.Context, canonical string, variants []string) (*uuid.UUID, string, error) {
+	var result struct {
+		ID          uuid.UUID `gorm:"column:id"`
+		PlateNumber string    `gorm:"column:plate_number"`
+	}
+
+	if len(variants) == 0 {
+		variants = []string{canonical}
+	}
+
+	const normalizedPlate = "UPPER(REPLACE(REPLACE(plate_number, ' ', ''), '-', ''))"
+	err := r.db.WithContext(ctx).
+		Table("vehicles").
+		Select("id, plate_number").
+		Where(normalizedPlate+" IN ?", variants).
+		Order(gorm.Expr("CASE WHEN "+normalizedPlate+" = ? THEN 0 ELSE 1 END", canonical)).
+		First(&result).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to find vehicle by plate: %w", err)
+	}
+
+	return &result.ID, result.PlateNumber, nil
+}
@@ -18,7 +18,12 @@ func NewCleaningAreaAccessRepository(db *gorm.DB) *CleaningAreaAccessRepository
 // Grant grants access to a cleaning area for a contractor
 // If access already exists, it updates the source and reactivates it (sets revoked_at to NULL)
 func (r *CleaningAreaAccessRepository) Grant(ctx context.Context, areaID, contractorID uuid.UUID, source string) error {
-	return r.db.WithContext(ctx).Exec(`
+	return grantCleaningAreaAccess(r.db.WithContext(ctx), areaID, contractorID, source)
+}
+
+// grantCleaningAreaAccess выполняет Grant в переданном соединении или транзакции
+func grantCleaningAreaAccess(db *gorm.DB, areaID, contractorID uuid.UUID, source string) error {
+	return db.Exec(`
 		INSERT INTO cleaning_area_access (cleaning_area_id, contractor_id, source, revoked_at)
 		VALUES (?, ?, ?, NULL)
 		ON CONFLICT (cleaning_area_id, contractor_id)
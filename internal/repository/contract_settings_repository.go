@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+type ContractSettingsRepository struct {
+	db *gorm.DB
+}
+
+func NewContractSettingsRepository(db *gorm.DB) *ContractSettingsRepository {
+	return &ContractSettingsRepository{db: db}
+}
+
+// GetByContractID возвращает настройки договора или nil, если они не заданы
+func (r *ContractSettingsRepository) GetByContractID(ctx context.Context, contractID uuid.UUID) (*model.ContractSettings, error) {
+	var settings model.ContractSettings
+	err := r.db.WithContext(ctx).Where("contract_id = ?", contractID).First(&settings).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &settings, nil
+}
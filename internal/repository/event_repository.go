@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+type EventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+func (r *EventRepository) CreateLprEvent(ctx context.Context, event *model.LprEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *EventRepository) CreateVolumeEvent(ctx context.Context, event *model.VolumeEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// FindLprEventByExternalID находит LPR событие по идентификатору из вышестоящего сервиса
+func (r *EventRepository) FindLprEventByExternalID(ctx context.Context, externalID string) (*model.LprEvent, error) {
+	var event model.LprEvent
+	err := r.db.WithContext(ctx).Where("external_id = ?", externalID).First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// FindVolumeEventByExternalID находит volume событие по идентификатору из вышестоящего сервиса
+func (r *EventRepository) FindVolumeEventByExternalID(ctx context.Context, externalID string) (*model.VolumeEvent, error) {
+	var event model.VolumeEvent
+	err := r.db.WithContext(ctx).Where("external_id = ?", externalID).First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// FindNearestFreeVolumeEvent находит ближайшее по времени volume событие на той же камере или полигоне,
+// которое еще не привязано ни к одному рейсу
+func (r *EventRepository) FindNearestFreeVolumeEvent(ctx context.Context, cameraID uuid.UUID, polygonID *uuid.UUID, direction string, at time.Time, window time.Duration) (*model.VolumeEvent, error) {
+	var event model.VolumeEvent
+	query := r.db.WithContext(ctx).
+		Where("direction = ?", direction).
+		Where("detected_at BETWEEN ? AND ?", at.Add(-window), at.Add(window)).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM trips tr
+			WHERE tr.entry_volume_event_id = volume_events.id OR tr.exit_volume_event_id = volume_events.id
+		)`)
+
+	if polygonID != nil {
+		query = query.Where("(camera_id = ? OR polygon_id = ?)", cameraID, *polygonID)
+	} else {
+		query = query.Where("camera_id = ?", cameraID)
+	}
+
+	err := query.
+		Order(gorm.Expr("ABS(EXTRACT(EPOCH FROM (detected_at - ?)))", at)).
+		First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
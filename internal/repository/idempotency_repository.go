@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"ticket-service/internal/model"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve резервирует ключ за текущим запросом.
+// Возвращает true, если ключ зарезервирован этим вызовом, иначе - ранее сохраненную запись.
+// Записи старше ttl считаются просроченными и перезаписываются.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, record *model.IdempotencyKey, ttl time.Duration) (bool, *model.IdempotencyKey, error) {
+	db := r.db.WithContext(ctx)
+
+	if err := db.
+		Where("scope = ? AND key = ? AND created_at < ?", record.Scope, record.Key, time.Now().Add(-ttl)).
+		Delete(&model.IdempotencyKey{}).Error; err != nil {
+		return false, nil, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil, nil
+	}
+
+	var existing model.IdempotencyKey
+	if err := db.Where("scope = ? AND key = ?", record.Scope, record.Key).First(&existing).Error; err != nil {
+		return false, nil, err
+	}
+	return false, &existing, nil
+}
+
+// Complete сохраняет ответ, который будет возвращаться на повторные запросы
+func (r *IdempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&model.IdempotencyKey{}).
+		Where("scope = ? AND key = ?", scope, key).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"response_body": body,
+			"completed_at":  now,
+		}).Error
+}
+
+// Release удаляет резерв ключа, чтобы запрос можно было повторить (например, после 5xx)
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	return r.db.WithContext(ctx).
+		Where("scope = ? AND key = ?", scope, key).
+		Delete(&model.IdempotencyKey{}).Error
+}
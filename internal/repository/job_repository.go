@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"ticket-service/internal/model"
+)
+
+type JobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// Enqueue ставит задачу в очередь. Если задача с тем же dedup_key еще не выполнена,
+// новая не создается и возвращается false.
+func (r *JobRepository) Enqueue(ctx context.Context, job *model.Job) (bool, error) {
+	return enqueueJob(r.db.WithContext(ctx), job)
+}
+
+// enqueueJob ставит задачу в очередь в рамках переданной транзакции (см. Enqueue)
+func enqueueJob(tx *gorm.DB, job *model.Job) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Claim забирает ближайшую готовую к выполнению задачу одного из типов и переводит ее в RUNNING.
+// Параллельные воркеры не блокируют друг друга (FOR UPDATE SKIP LOCKED). Возвращает nil, если задач нет.
+func (r *JobRepository) Claim(ctx context.Context, types []string, now time.Time) (*model.Job, error) {
+	var claimed *model.Job
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var job model.Job
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ? AND type IN ?", model.JobStatusPending, now, types).
+			Order("run_at ASC").
+			First(&job).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		job.Status = model.JobStatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		if err := tx.Model(&model.Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		claimed = &job
+		return nil
+	})
+	return claimed, err
+}
+
+// Complete отмечает задачу выполненной
+func (r *JobRepository) Complete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&model.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     model.JobStatusDone,
+			"locked_at":  nil,
+			"last_error": nil,
+		}).Error
+}
+
+// Retry возвращает задачу в очередь с повтором не раньше runAt
+func (r *JobRepository) Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	return r.db.WithContext(ctx).Model(&model.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     model.JobStatusPending,
+			"run_at":     runAt,
+			"locked_at":  nil,
+			"last_error": lastError,
+		}).Error
+}
+
+// MarkDead переводит задачу в DEAD, после чего она больше не выполняется
+func (r *JobRepository) MarkDead(ctx context.Context, id uuid.UUID, lastError string) error {
+	return r.db.WithContext(ctx).Model(&model.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     model.JobStatusDead,
+			"locked_at":  nil,
+			"last_error": lastError,
+		}).Error
+}
+
+// RequeueStale возвращает в очередь задачи, зависшие в RUNNING дольше lockedBefore
+// (воркер упал или был остановлен во время выполнения)
+func (r *JobRepository) RequeueStale(ctx context.Context, lockedBefore time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&model.Job{}).
+		Where("status = ? AND locked_at < ?", model.JobStatusRunning, lockedBefore).
+		Updates(map[string]interface{}{
+			"status":    model.JobStatusPending,
+			"locked_at": nil,
+			"run_at":    time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
@@ -28,3 +28,13 @@ func (r *PolygonAccessRepository) Grant(ctx context.Context, polygonID, contract
 			updated_at = NOW()
 	`, polygonID, contractorID, source).Error
 }
+
+// HasAccess проверяет, есть ли у подрядчика действующий (не отозванный) доступ к полигону
+func (r *PolygonAccessRepository) HasAccess(ctx context.Context, polygonID, contractorID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("polygon_access").
+		Where("polygon_id = ? AND contractor_id = ? AND revoked_at IS NULL", polygonID, contractorID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+type ReconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+func (r *ReconciliationRepository) Create(ctx context.Context, entry *model.TripReconciliationLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// HasAction проверяет, записывалось ли уже указанное действие по рейсу
+func (r *ReconciliationRepository) HasAction(ctx context.Context, tripID uuid.UUID, action string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.TripReconciliationLog{}).
+		Where("trip_id = ? AND action = ?", tripID, action).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+type ScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+func (r *ScheduleRepository) Create(ctx context.Context, schedule *model.TicketSchedule) error {
+	return r.db.WithContext(ctx).Create(schedule).Error
+}
+
+func (r *ScheduleRepository) GetByID(ctx context.Context, id string) (*model.TicketSchedule, error) {
+	var schedule model.TicketSchedule
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListByOrg возвращает расписания организации, status == nil - все
+func (r *ScheduleRepository) ListByOrg(ctx context.Context, orgID uuid.UUID, status *model.TicketScheduleStatus) ([]model.TicketSchedule, error) {
+	query := r.db.WithContext(ctx).Where("created_by_org_id = ?", orgID)
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var schedules []model.TicketSchedule
+	err := query.Order("created_at DESC").Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *ScheduleRepository) ListActive(ctx context.Context) ([]model.TicketSchedule, error) {
+	var schedules []model.TicketSchedule
+	err := r.db.WithContext(ctx).
+		Where("status = ?", model.TicketScheduleStatusActive).
+		Order("id ASC").
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// SaveAndReset сохраняет расписание и удаляет будущие тикеты серии, по которым еще не начаты работы
+// (PLANNED, начало позже now, нет назначений). materialized_until переносится на now, чтобы планировщик
+// создал их заново по новым правилам, начиная с now; более ранние окна не пересоздаются.
+// Возвращает количество удаленных тикетов.
+func (r *ScheduleRepository) SaveAndReset(ctx context.Context, schedule *model.TicketSchedule, now time.Time) (int64, error) {
+	var removed int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		schedule.MaterializedUntil = &now
+		if err := tx.Save(schedule).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(`
+			DELETE FROM tickets t
+			WHERE t.schedule_id = ?
+				AND t.status = ?
+				AND t.planned_start_at > ?
+				AND NOT EXISTS (SELECT 1 FROM ticket_assignments a WHERE a.ticket_id = t.id)
+		`, schedule.ID, model.TicketStatusPlanned, now)
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		return nil
+	})
+	return removed, err
+}
+
+// SetMaterializedUntil сдвигает отметку, до которой тикеты серии созданы (только вперед)
+func (r *ScheduleRepository) SetMaterializedUntil(ctx context.Context, id uuid.UUID, until time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.TicketSchedule{}).
+		Where("id = ? AND status = ? AND (materialized_until IS NULL OR materialized_until < ?)",
+			id, model.TicketScheduleStatusActive, until).
+		Update("materialized_until", until).Error
+}
+
+// ListExceptions возвращает удаленные вручную даты серии в диапазоне [from, to] (включительно)
+func (r *ScheduleRepository) ListExceptions(ctx context.Context, scheduleID uuid.UUID, from, to time.Time) ([]model.ScheduleException, error) {
+	var exceptions []model.ScheduleException
+	err := r.db.WithContext(ctx).
+		Where("schedule_id = ? AND date BETWEEN ? AND ?", scheduleID, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("date ASC").
+		Find(&exceptions).Error
+	return exceptions, err
+}
+
+type HolidayRepository struct {
+	db *gorm.DB
+}
+
+func NewHolidayRepository(db *gorm.DB) *HolidayRepository {
+	return &HolidayRepository{db: db}
+}
+
+// Upsert добавляет нерабочий день или обновляет его название
+func (r *HolidayRepository) Upsert(ctx context.Context, holiday *model.Holiday) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO holidays (org_id, date, name)
+		VALUES (?, ?, ?)
+		ON CONFLICT (org_id, date)
+		DO UPDATE SET name = EXCLUDED.name
+	`, holiday.OrgID, holiday.Date.Format("2006-01-02"), holiday.Name).Error
+}
+
+func (r *HolidayRepository) Delete(ctx context.Context, orgID uuid.UUID, date time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("org_id = ? AND date = ?", orgID, date.Format("2006-01-02")).
+		Delete(&model.Holiday{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// List возвращает нерабочие дни организации в диапазоне дат [from, to] (включительно)
+func (r *HolidayRepository) List(ctx context.Context, orgID uuid.UUID, from, to time.Time) ([]model.Holiday, error) {
+	var holidays []model.Holiday
+	err := r.db.WithContext(ctx).
+		Where("org_id = ? AND date BETWEEN ? AND ?", orgID, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("date ASC").
+		Find(&holidays).Error
+	return holidays, err
+}
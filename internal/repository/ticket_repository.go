@@ -3,9 +3,12 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"ticket-service/internal/model"
 )
@@ -14,6 +17,20 @@ type TicketRepository struct {
 	db *gorm.DB
 }
 
+// ErrTicketOverlap - запись нарушила ex_tickets_area_overlap: активный тикет пересекается по времени
+// с другим активным тикетом того же участка
+var ErrTicketOverlap = errors.New("ticket overlaps active tickets on the same cleaning area")
+
+// ticketOverlapError переводит нарушение ограничения ex_tickets_area_overlap в ErrTicketOverlap
+// (gorm не транслирует ошибки exclusion-ограничений)
+func ticketOverlapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == "ex_tickets_area_overlap" {
+		return ErrTicketOverlap
+	}
+	return err
+}
+
 func NewTicketRepository(db *gorm.DB) *TicketRepository {
 	return &TicketRepository{db: db}
 }
@@ -38,6 +55,235 @@ func (r *TicketRepository) Update(ctx context.Context, ticket *model.Ticket) err
 	return r.db.WithContext(ctx).Save(ticket).Error
 }
 
+// CreateWithHistory создает тикет и первую запись истории статусов
+func (r *TicketRepository) CreateWithHistory(ctx context.Context, ticket *model.Ticket, entry *model.TicketStatusHistory) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ticket).Error; err != nil {
+			return err
+		}
+		entry.TicketID = ticket.ID
+		return tx.Create(entry).Error
+	})
+	return ticketOverlapError(err)
+}
+
+// CreateBatch создает тикеты с записями истории статусов и выдает подрядчикам доступ к участкам
+// (cleaning_area_access, source TICKET) одной транзакцией: при любой ошибке не создается ничего.
+// entries[i] относится к tickets[i].
+func (r *TicketRepository) CreateBatch(ctx context.Context, tickets []*model.Ticket, entries []*model.TicketStatusHistory) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		type areaContractor struct{ areaID, contractorID uuid.UUID }
+		granted := make(map[areaContractor]bool)
+
+		for i, ticket := range tickets {
+			if err := tx.Create(ticket).Error; err != nil {
+				return err
+			}
+			entries[i].TicketID = ticket.ID
+			if err := tx.Create(entries[i]).Error; err != nil {
+				return err
+			}
+
+			key := areaContractor{ticket.CleaningAreaID, ticket.ContractorID}
+			if granted[key] {
+				continue
+			}
+			if err := grantCleaningAreaAccess(tx, ticket.CleaningAreaID, ticket.ContractorID, "TICKET"); err != nil {
+				return err
+			}
+			granted[key] = true
+		}
+		return nil
+	})
+	return ticketOverlapError(err)
+}
+
+// CreateScheduled создает тикет серии расписания с первой записью истории статусов.
+// Если тикет на эту дату серии уже есть (uq_tickets_schedule_date) или он пересекся бы с активным
+// тикетом участка (ex_tickets_area_overlap), ничего не делает и возвращает false.
+func (r *TicketRepository) CreateScheduled(ctx context.Context, ticket *model.Ticket, entry *model.TicketStatusHistory) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(ticket)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		created = true
+		entry.TicketID = ticket.ID
+		return tx.Create(entry).Error
+	})
+	return created, err
+}
+
+// TicketStatusChange - смена статуса тикета, сохраняемая одной транзакцией
+type TicketStatusChange struct {
+	// Ticket - тикет с новым статусом, фактическими датами и planned_end_at
+	Ticket *model.Ticket
+	// From - статус, в котором тикет должен находиться в БД
+	From  model.TicketStatus
+	Entry *model.TicketStatusHistory
+	// Changes - записи истории изменений полей, измененных вместе со статусом (planned_end_at)
+	Changes []model.TicketChangeHistory
+	// RenewCompletedAssignments - снять завершенные активные назначения и создать вместо них
+	// новые (NOT_STARTED) с теми же водителем и машиной, чтобы водители могли начать новые рейсы
+	RenewCompletedAssignments bool
+	// CheckAssignmentBookings - проверить, что водители и машины активных назначений тикета
+	// не заняты на других тикетах в его окне (ErrAssignmentOverlap)
+	CheckAssignmentBookings bool
+}
+
+// ChangeStatus сохраняет новый статус тикета вместе с записью истории.
+// Обновление выполняется, только если в БД тикет все еще в статусе From; иначе возвращает false
+// (статус успел измениться параллельным запросом).
+func (r *TicketRepository) ChangeStatus(ctx context.Context, change TicketStatusChange) (bool, error) {
+	ticket := change.Ticket
+	changed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Ticket{}).
+			Where("id = ? AND status = ?", ticket.ID, change.From).
+			Updates(map[string]interface{}{
+				"status":         ticket.Status,
+				"fact_start_at":  ticket.FactStartAt,
+				"fact_end_at":    ticket.FactEndAt,
+				"planned_end_at": ticket.PlannedEndAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		changed = true
+
+		if change.RenewCompletedAssignments {
+			if err := renewCompletedAssignments(tx, ticket.ID, time.Now()); err != nil {
+				return err
+			}
+		}
+		if change.CheckAssignmentBookings {
+			if err := checkTicketBookings(tx, ticket.ID); err != nil {
+				return err
+			}
+		}
+		if len(change.Changes) > 0 {
+			if err := tx.Create(&change.Changes).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(change.Entry).Error
+	})
+	return changed, ticketOverlapError(err)
+}
+
+func renewCompletedAssignments(tx *gorm.DB, ticketID uuid.UUID, now time.Time) error {
+	var completed []model.TicketAssignment
+	if err := tx.
+		Where("ticket_id = ? AND is_active = ? AND driver_mark_status IN ?", ticketID, true,
+			[]model.DriverMarkStatus{model.DriverMarkStatusShiftEnded, model.DriverMarkStatusCompleted}).
+		Find(&completed).Error; err != nil {
+		return err
+	}
+
+	for _, assignment := range completed {
+		// Старое назначение остается в истории вместе со своими рейсами
+		if err := tx.Model(&model.TicketAssignment{}).
+			Where("id = ?", assignment.ID).
+			Updates(map[string]interface{}{
+				"is_active":     false,
+				"unassigned_at": now,
+			}).Error; err != nil {
+			return err
+		}
+
+		renewed := &model.TicketAssignment{
+			TicketID:             ticketID,
+			DriverID:             assignment.DriverID,
+			VehicleID:            assignment.VehicleID,
+			DriverMarkStatus:     model.DriverMarkStatusNotStarted,
+			AssignedAt:           now,
+			IsActive:             true,
+			PreviousAssignmentID: &assignment.ID,
+		}
+		if err := tx.Create(renewed).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListStatusHistory возвращает историю статусов тикета в хронологическом порядке
+func (r *TicketRepository) ListStatusHistory(ctx context.Context, ticketID uuid.UUID) ([]model.TicketStatusHistory, error) {
+	var history []model.TicketStatusHistory
+	err := r.db.WithContext(ctx).
+		Where("ticket_id = ?", ticketID).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error
+	return history, err
+}
+
+// UpdateFields сохраняет измененные поля тикета вместе с записями истории изменений.
+// Обновление выполняется, только если тикет все еще в статусе status; иначе возвращает false.
+// checkBookings - проверить назначения тикета по новому окну (ErrAssignmentOverlap).
+func (r *TicketRepository) UpdateFields(ctx context.Context, ticketID uuid.UUID, status model.TicketStatus, updates map[string]interface{}, changes []model.TicketChangeHistory, checkBookings bool) (bool, error) {
+	changed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Ticket{}).
+			Where("id = ? AND status = ?", ticketID, status).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		changed = true
+		if checkBookings {
+			if err := checkTicketBookings(tx, ticketID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&changes).Error
+	})
+	return changed, ticketOverlapError(err)
+}
+
+// FindOverlapping возвращает активные тикеты участка (PLANNED, IN_PROGRESS, без флага allow_overlap),
+// плановое окно которых пересекается с [start, end). excludeID - тикет, который не нужно учитывать
+// (сам редактируемый тикет); uuid.Nil - не исключать ничего.
+func (r *TicketRepository) FindOverlapping(ctx context.Context, cleaningAreaID uuid.UUID, start, end time.Time, excludeID uuid.UUID) ([]model.Ticket, error) {
+	var tickets []model.Ticket
+	err := r.db.WithContext(ctx).
+		Where("cleaning_area_id = ?", cleaningAreaID).
+		Where("status IN ?", []model.TicketStatus{model.TicketStatusPlanned, model.TicketStatusInProgress}).
+		Where("NOT allow_overlap").
+		Where("planned_start_at < ? AND planned_end_at > ?", end, start).
+		Where("id <> ?", excludeID).
+		Order("planned_start_at ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// ListChangeHistory возвращает историю изменений полей тикета в хронологическом порядке
+func (r *TicketRepository) ListChangeHistory(ctx context.Context, ticketID uuid.UUID) ([]model.TicketChangeHistory, error) {
+	var history []model.TicketChangeHistory
+	err := r.db.WithContext(ctx).
+		Where("ticket_id = ?", ticketID).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error
+	return history, err
+}
+
+func (r *TicketRepository) CountActiveAssignmentsByTicketID(ctx context.Context, ticketID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.TicketAssignment{}).
+		Where("ticket_id = ? AND is_active = ?", ticketID, true).
+		Count(&count).Error
+	return count, err
+}
+
 func (r *TicketRepository) CountTripsByTicketID(ctx context.Context, ticketID uuid.UUID) (int64, error) {
 	var count int64
 	err := r.db.WithContext(ctx).Model(&model.Trip{}).
@@ -56,8 +302,8 @@ func (r *TicketRepository) CountIncompleteTripsByTicketID(ctx context.Context, t
 func (r *TicketRepository) CountIncompleteAssignmentsByTicketID(ctx context.Context, ticketID uuid.UUID) (int64, error) {
 	var count int64
 	err := r.db.WithContext(ctx).Model(&model.TicketAssignment{}).
-		Where("ticket_id = ? AND is_active = ? AND driver_mark_status != ?",
-			ticketID, true, model.DriverMarkStatusCompleted).
+		Where("ticket_id = ? AND is_active = ? AND driver_mark_status NOT IN ?",
+			ticketID, true, []model.DriverMarkStatus{model.DriverMarkStatusShiftEnded, model.DriverMarkStatusCompleted}).
 		Count(&count).Error
 	return count, err
 }
@@ -67,6 +313,7 @@ type TicketListFilter struct {
 	ContractorID     *string
 	CleaningAreaID   *string
 	ContractID       *string
+	ScheduleID       *string
 	CreatedByOrgID   *string
 	DriverID         *string
 	PlannedStartFrom *string
@@ -95,6 +342,9 @@ func (r *TicketRepository) List(ctx context.Context, filter TicketListFilter) ([
 	if filter.ContractID != nil {
 		query = query.Where("contract_id = ?", *filter.ContractID)
 	}
+	if filter.ScheduleID != nil {
+		query = query.Where("schedule_id = ?", *filter.ScheduleID)
+	}
 	if filter.CreatedByOrgID != nil {
 		query = query.Where("created_by_org_id = ?", *filter.CreatedByOrgID)
 	}
@@ -139,6 +389,8 @@ type TicketMetrics struct {
 	TotalTrips    int64   `json:"total_trips"`
 	TotalVolumeM3 float64 `json:"total_volume_m3"`
 	HasViolations bool    `json:"has_violations"`
+	// OpenViolations - количество не снятых нарушений по рейсам тикета
+	OpenViolations int64 `json:"open_violations"`
 }
 
 // GetTicketMetrics рассчитывает метрики тикета
@@ -151,10 +403,10 @@ func (r *TicketRepository) GetTicketMetrics(ctx context.Context, ticketID uuid.U
 		return nil, err
 	}
 
-	// Общий объём вывезен (сумма detected_volume_entry)
+	// Общий объём вывезен (сумма принятого объема рейсов)
 	var totalVolume *float64
 	if err := r.db.WithContext(ctx).Model(&model.Trip{}).
-		Select("COALESCE(SUM(detected_volume_entry), 0)").
+		Select("COALESCE(SUM(accepted_volume_m3), 0)").
 		Where("ticket_id = ?", ticketID).
 		Scan(&totalVolume).Error; err != nil {
 		return nil, err
@@ -163,14 +415,14 @@ func (r *TicketRepository) GetTicketMetrics(ctx context.Context, ticketID uuid.U
 		metrics.TotalVolumeM3 = *totalVolume
 	}
 
-	// Наличие нарушений (есть ли рейсы со статусом != 'OK')
-	var violationsCount int64
-	if err := r.db.WithContext(ctx).Model(&model.Trip{}).
-		Where("ticket_id = ? AND status != ?", ticketID, model.TripStatusOK).
-		Count(&violationsCount).Error; err != nil {
+	// Наличие нарушений (не снятые нарушения по рейсам тикета)
+	if err := r.db.WithContext(ctx).Model(&model.TripViolation{}).
+		Joins("JOIN trips tr ON tr.id = trip_violations.trip_id").
+		Where("tr.ticket_id = ? AND trip_violations.resolved_at IS NULL", ticketID).
+		Count(&metrics.OpenViolations).Error; err != nil {
 		return nil, err
 	}
-	metrics.HasViolations = violationsCount > 0
+	metrics.HasViolations = metrics.OpenViolations > 0
 
 	return &metrics, nil
 }
@@ -205,18 +457,30 @@ func (r *TicketRepository) GetAppealsByTicketID(ctx context.Context, ticketID uu
 	return appeals, err
 }
 
-// Delete deletes a ticket by ID
+// Delete deletes a ticket by ID.
+// Дата серии удаленного тикета расписания записывается в ticket_schedule_exceptions,
+// чтобы планировщик не создал тикет на эту дату снова.
 func (r *TicketRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).
-		Table("tickets").
-		Where("id = ?", id).
-		Delete(nil)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO ticket_schedule_exceptions (schedule_id, date)
+			SELECT schedule_id, schedule_date FROM tickets
+			WHERE id = ? AND schedule_id IS NOT NULL AND schedule_date IS NOT NULL
+			ON CONFLICT DO NOTHING
+		`, id).Error; err != nil {
+			return err
+		}
 
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
-	}
-	return nil
+		result := tx.Table("tickets").
+			Where("id = ?", id).
+			Delete(nil)
+
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
 }
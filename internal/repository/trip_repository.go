@@ -23,6 +23,17 @@ func (r *TripRepository) Create(ctx context.Context, trip *model.Trip) error {
 	return r.db.WithContext(ctx).Create(trip).Error
 }
 
+// CreateWithReconciliationLog создает рейс и запись trip_reconciliation_log о нем одной транзакцией
+func (r *TripRepository) CreateWithReconciliationLog(ctx context.Context, trip *model.Trip, entry *model.TripReconciliationLog) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(trip).Error; err != nil {
+			return err
+		}
+		entry.TripID = trip.ID
+		return tx.Create(entry).Error
+	})
+}
+
 func (r *TripRepository) GetByID(ctx context.Context, id string) (*model.Trip, error) {
 	var trip model.Trip
 	err := r.db.WithContext(ctx).Where("id = ?", id).First(&trip).Error
@@ -39,6 +50,39 @@ func (r *TripRepository) Update(ctx context.Context, trip *model.Trip) error {
 	return r.db.WithContext(ctx).Save(trip).Error
 }
 
+// CreateWithJob создает рейс и ставит задачу по нему в очередь одной транзакцией
+func (r *TripRepository) CreateWithJob(ctx context.Context, trip *model.Trip, job *model.Job) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(trip).Error; err != nil {
+			return err
+		}
+		_, err := enqueueJob(tx, job)
+		return err
+	})
+}
+
+// UpdateWithJob сохраняет рейс и ставит задачу по нему в очередь одной транзакцией.
+// Если такая задача еще не выполнена (тот же dedup_key), новая не создается.
+func (r *TripRepository) UpdateWithJob(ctx context.Context, trip *model.Trip, job *model.Job) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(trip).Error; err != nil {
+			return err
+		}
+		_, err := enqueueJob(tx, job)
+		return err
+	})
+}
+
+// SetVolumeCalculationStatus меняет только состояние расчета объема рейса, не затрагивая остальные поля
+func (r *TripRepository) SetVolumeCalculationStatus(ctx context.Context, id uuid.UUID, status model.VolumeCalculationStatus, calcErr *string) error {
+	return r.db.WithContext(ctx).Model(&model.Trip{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"volume_calculation_status": status,
+			"volume_calculation_error":  calcErr,
+		}).Error
+}
+
 func (r *TripRepository) ListByTicketID(ctx context.Context, ticketID uuid.UUID) ([]model.Trip, error) {
 	var trips []model.Trip
 	err := r.db.WithContext(ctx).
@@ -89,6 +133,212 @@ func (r *TripRepository) FindByAssignmentID(ctx context.Context, assignmentID uu
 	return &trip, nil
 }
 
+// FindByExternalID находит рейс по идентификатору из вышестоящего сервиса
+func (r *TripRepository) FindByExternalID(ctx context.Context, externalID string) (*model.Trip, error) {
+	var trip model.Trip
+	err := r.db.WithContext(ctx).Where("external_id = ?", externalID).First(&trip).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// FindByLprEventID находит рейс, который был открыт или закрыт указанным LPR событием
+func (r *TripRepository) FindByLprEventID(ctx context.Context, eventID uuid.UUID) (*model.Trip, error) {
+	var trip model.Trip
+	err := r.db.WithContext(ctx).
+		Where("entry_lpr_event_id = ? OR exit_lpr_event_id = ?", eventID, eventID).
+		First(&trip).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// FindByVolumeEventID находит рейс, к которому привязано указанное volume событие
+func (r *TripRepository) FindByVolumeEventID(ctx context.Context, eventID uuid.UUID) (*model.Trip, error) {
+	var trip model.Trip
+	err := r.db.WithContext(ctx).
+		Where("entry_volume_event_id = ? OR exit_volume_event_id = ?", eventID, eventID).
+		First(&trip).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// FindOpenByPlate находит последний рейс без выезда для номера на том же полигоне (или камере, если полигон не указан)
+func (r *TripRepository) FindOpenByPlate(ctx context.Context, plate string, cameraID uuid.UUID, polygonID *uuid.UUID, from, to time.Time) (*model.Trip, error) {
+	var trip model.Trip
+	query := r.db.WithContext(ctx).
+		Where("detected_plate_number = ?", plate).
+		Where("exit_lpr_event_id IS NULL").
+		Where("entry_at BETWEEN ? AND ?", from, to)
+
+	if polygonID != nil {
+		query = query.Where("polygon_id = ?", *polygonID)
+	} else {
+		query = query.Where("camera_id = ?", cameraID)
+	}
+
+	err := query.Order("entry_at DESC").First(&trip).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// FindAwaitingVolume находит ближайший по времени рейс, которому еще не сопоставлено volume событие
+// указанного направления (въезд сравнивается с entry_at, выезд - с exit_at)
+func (r *TripRepository) FindAwaitingVolume(ctx context.Context, direction string, cameraID uuid.UUID, polygonID *uuid.UUID, at time.Time, window time.Duration) (*model.Trip, error) {
+	timeColumn := "entry_at"
+	volumeColumn := "entry_volume_event_id"
+	if direction == model.EventDirectionExit {
+		timeColumn = "exit_at"
+		volumeColumn = "exit_volume_event_id"
+	}
+
+	var trip model.Trip
+	query := r.db.WithContext(ctx).
+		Where(volumeColumn+" IS NULL").
+		Where(timeColumn+" BETWEEN ? AND ?", at.Add(-window), at.Add(window))
+
+	if polygonID != nil {
+		query = query.Where("(camera_id = ? OR polygon_id = ?)", cameraID, *polygonID)
+	} else {
+		query = query.Where("camera_id = ?", cameraID)
+	}
+
+	err := query.
+		Order(gorm.Expr("ABS(EXTRACT(EPOCH FROM ("+timeColumn+" - ?)))", at)).
+		First(&trip).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// ListUnassigned возвращает рейсы без тикета, у которых известны водитель или машина,
+// постранично по (entry_at, id) начиная после указанной позиции
+func (r *TripRepository) ListUnassigned(ctx context.Context, afterEntryAt time.Time, afterID uuid.UUID, limit int) ([]model.Trip, error) {
+	var trips []model.Trip
+	err := r.db.WithContext(ctx).
+		Where("ticket_id IS NULL").
+		Where("(entry_at, id) > (?, ?)", afterEntryAt, afterID).
+		Where("(driver_id IS NOT NULL OR vehicle_id IS NOT NULL)").
+		Order("entry_at ASC, id ASC").
+		Limit(limit).
+		Find(&trips).Error
+	return trips, err
+}
+
+// VolumeRecalculationFilter - выборка автоматически созданных рейсов для пересчета объема
+type VolumeRecalculationFilter struct {
+	AssignmentID   *uuid.UUID
+	TicketID       *uuid.UUID
+	EntryFrom      *time.Time
+	EntryTo        *time.Time
+	CreatedByOrgID *uuid.UUID
+	// AfterEntryAt/AfterID - продолжить выборку после этого рейса (по entry_at, id), Limit - размер страницы
+	AfterEntryAt *time.Time
+	AfterID      uuid.UUID
+	Limit        int
+}
+
+// ListForVolumeRecalculation возвращает автоматически созданные рейсы назначений, объем которых
+// рассчитывается по событиям ANPR
+func (r *TripRepository) ListForVolumeRecalculation(ctx context.Context, filter VolumeRecalculationFilter) ([]model.Trip, error) {
+	query := r.db.WithContext(ctx).Table("trips tr").
+		Select("tr.*").
+		Where("tr.auto_created = ? AND tr.ticket_assignment_id IS NOT NULL", true)
+
+	if filter.AssignmentID != nil {
+		query = query.Where("tr.ticket_assignment_id = ?", *filter.AssignmentID)
+	}
+	if filter.TicketID != nil {
+		query = query.Where("tr.ticket_id = ?", *filter.TicketID)
+	}
+	if filter.EntryFrom != nil {
+		query = query.Where("tr.entry_at >= ?", *filter.EntryFrom)
+	}
+	if filter.EntryTo != nil {
+		query = query.Where("tr.entry_at < ?", *filter.EntryTo)
+	}
+	if filter.CreatedByOrgID != nil {
+		query = query.Joins("JOIN tickets t ON t.id = tr.ticket_id").
+			Where("t.created_by_org_id = ?", *filter.CreatedByOrgID)
+	}
+
+	if filter.AfterEntryAt != nil {
+		query = query.Where("(tr.entry_at, tr.id) > (?, ?)", *filter.AfterEntryAt, filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var trips []model.Trip
+	err := query.Order("tr.entry_at ASC, tr.id ASC").Find(&trips).Error
+	return trips, err
+}
+
+// MarkVolumePendingWithJobs переводит рейсы в ожидание расчета объема и ставит задачи расчета
+// в очередь одной транзакцией. Возвращает количество новых задач: задачи с уже ожидающим
+// dedup_key не создаются повторно.
+func (r *TripRepository) MarkVolumePendingWithJobs(ctx context.Context, ids []uuid.UUID, jobs []*model.Job) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	queued := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, job := range jobs {
+			added, err := enqueueJob(tx, job)
+			if err != nil {
+				return err
+			}
+			if added {
+				queued++
+			}
+		}
+		return tx.Model(&model.Trip{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"volume_calculation_status": model.VolumeCalculationPending,
+				"volume_calculation_error":  nil,
+			}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return queued, nil
+}
+
+// SumVolumeByContract возвращает суммарный принятый объем рейсов по всем тикетам договора, кроме указанного рейса
+func (r *TripRepository) SumVolumeByContract(ctx context.Context, contractID uuid.UUID, excludeTripID uuid.UUID) (float64, error) {
+	var total float64
+	err := r.db.WithContext(ctx).Table("trips tr").
+		Select("COALESCE(SUM(tr.accepted_volume_m3), 0)").
+		Joins("JOIN tickets t ON t.id = tr.ticket_id").
+		Where("t.contract_id = ?", contractID).
+		Where("tr.id <> ?", excludeTripID).
+		Scan(&total).Error
+	return total, err
+}
+
 // ReceptionJournalEntry представляет запись в журнале приёма снега
 type ReceptionJournalEntry struct {
 	TripID              uuid.UUID  `json:"trip_id"`
@@ -103,7 +353,12 @@ type ReceptionJournalEntry struct {
 	DetectedVolumeEntry *float64   `json:"detected_volume_entry"`
 	DetectedVolumeExit  *float64   `json:"detected_volume_exit"`
 	NetVolumeM3         float64    `json:"net_volume_m3"`
+	AcceptedVolumeM3    *float64   `json:"accepted_volume_m3"`
+	VolumeStrategy      *string    `json:"volume_strategy"`
 	Status              string     `json:"status"`
+	ViolationReason     *string    `json:"violation_reason"`
+
+	Violations []model.TripViolation `gorm:"-" json:"violations"`
 }
 
 // ReceptionJournalFilter фильтры для журнала приёма
@@ -131,7 +386,10 @@ func (r *TripRepository) ListReceptionJournal(ctx context.Context, filter Recept
 			tr.detected_volume_entry,
 			tr.detected_volume_exit,
 			COALESCE(tr.detected_volume_entry, 0) - COALESCE(tr.detected_volume_exit, 0) AS net_volume_m3,
-			tr.status::text AS status
+			tr.accepted_volume_m3,
+			tr.volume_strategy,
+			tr.status::text AS status,
+			tr.violation_reason
 		`).
 		Joins("LEFT JOIN polygons p ON p.id = tr.polygon_id").
 		Joins("LEFT JOIN tickets t ON t.id = tr.ticket_id").
@@ -154,8 +412,13 @@ func (r *TripRepository) ListReceptionJournal(ctx context.Context, filter Recept
 		query = query.Where("t.contractor_id = ?", *filter.ContractorID)
 	}
 
+	// Фильтр по статусу смотрит на не снятые нарушения рейса, а не только на основной статус
 	if filter.Status != nil {
-		query = query.Where("tr.status = ?", *filter.Status)
+		if *filter.Status == model.TripStatusOK {
+			query = query.Where("NOT EXISTS (SELECT 1 FROM trip_violations v WHERE v.trip_id = tr.id AND v.resolved_at IS NULL)")
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM trip_violations v WHERE v.trip_id = tr.id AND v.type = ? AND v.resolved_at IS NULL)", *filter.Status)
+		}
 	}
 
 	query = query.Order("tr.entry_at DESC")
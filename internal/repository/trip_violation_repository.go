@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+type TripViolationRepository struct {
+	db *gorm.DB
+}
+
+func NewTripViolationRepository(db *gorm.DB) *TripViolationRepository {
+	return &TripViolationRepository{db: db}
+}
+
+func (r *TripViolationRepository) GetByID(ctx context.Context, id string) (*model.TripViolation, error) {
+	var violation model.TripViolation
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&violation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &violation, nil
+}
+
+func (r *TripViolationRepository) ListByTripID(ctx context.Context, tripID uuid.UUID) ([]model.TripViolation, error) {
+	var violations []model.TripViolation
+	err := r.db.WithContext(ctx).
+		Where("trip_id = ?", tripID).
+		Order("created_at ASC").
+		Find(&violations).Error
+	return violations, err
+}
+
+// ListByTripIDs возвращает нарушения нескольких рейсов, сгруппированные по trip_id
+func (r *TripViolationRepository) ListByTripIDs(ctx context.Context, tripIDs []uuid.UUID) (map[uuid.UUID][]model.TripViolation, error) {
+	result := make(map[uuid.UUID][]model.TripViolation)
+	if len(tripIDs) == 0 {
+		return result, nil
+	}
+
+	var violations []model.TripViolation
+	err := r.db.WithContext(ctx).
+		Where("trip_id IN ?", tripIDs).
+		Order("created_at ASC").
+		Find(&violations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range violations {
+		result[v.TripID] = append(result[v.TripID], v)
+	}
+	return result, nil
+}
+
+// HasUnresolved проверяет, есть ли у рейса не снятые нарушения
+func (r *TripViolationRepository) HasUnresolved(ctx context.Context, tripID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.TripViolation{}).
+		Where("trip_id = ? AND resolved_at IS NULL", tripID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Sync приводит нарушения рейса к переданному списку: новые типы добавляются, существующие обновляются,
+// не снятые нарушения, которых больше нет в списке, удаляются. Снятые по обжалованию нарушения сохраняются.
+func (r *TripViolationRepository) Sync(ctx context.Context, tripID uuid.UUID, violations []model.TripViolation) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []model.TripViolation
+		if err := tx.Where("trip_id = ?", tripID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		byType := make(map[model.TripStatus]*model.TripViolation, len(existing))
+		for i := range existing {
+			byType[existing[i].Type] = &existing[i]
+		}
+
+		seen := make(map[model.TripStatus]bool, len(violations))
+		for _, v := range violations {
+			seen[v.Type] = true
+
+			if current, ok := byType[v.Type]; ok {
+				if current.Severity == v.Severity && current.Reason == v.Reason && current.DetectedBy == v.DetectedBy {
+					continue
+				}
+				if err := tx.Model(current).Updates(map[string]interface{}{
+					"severity":    v.Severity,
+					"reason":      v.Reason,
+					"detected_by": v.DetectedBy,
+				}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			v.TripID = tripID
+			if err := tx.Create(&v).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, current := range existing {
+			if seen[current.Type] || current.IsResolved() {
+				continue
+			}
+			if err := tx.Delete(&model.TripViolation{}, "id = ?", current.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ResolveByAppeal снимает нарушения рейса по одобренному обжалованию: одно нарушение,
+// если violationID указан, иначе все не снятые нарушения рейса
+func (r *TripViolationRepository) ResolveByAppeal(ctx context.Context, tripID uuid.UUID, violationID *uuid.UUID, appealID uuid.UUID) error {
+	query := r.db.WithContext(ctx).Model(&model.TripViolation{}).
+		Where("trip_id = ? AND resolved_at IS NULL", tripID)
+	if violationID != nil {
+		query = query.Where("id = ?", *violationID)
+	}
+	return query.Updates(map[string]interface{}{
+		"resolved_by_appeal_id": appealID,
+		"resolved_at":           time.Now(),
+	}).Error
+}
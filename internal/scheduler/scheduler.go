@@ -0,0 +1,55 @@
+// Package scheduler - фоновое создание тикетов по расписаниям (ticket_schedules).
+// Тикеты серий создаются заранее, на SCHEDULER_HORIZON вперед, чтобы подрядчик успел
+// назначить водителей. Проходы идемпотентны, поэтому планировщик можно безопасно
+// перезапускать и запускать в нескольких экземплярах сервиса.
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"ticket-service/internal/config"
+	"ticket-service/internal/service"
+)
+
+type Scheduler struct {
+	scheduleService *service.ScheduleService
+	cfg             config.SchedulerConfig
+	log             zerolog.Logger
+}
+
+func New(scheduleService *service.ScheduleService, cfg config.SchedulerConfig, log zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		scheduleService: scheduleService,
+		cfg:             cfg,
+		log:             log,
+	}
+}
+
+// Run выполняет проходы с периодом Interval до отмены контекста
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := s.scheduleService.MaterializeSchedules(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			s.log.Error().Err(err).Msg("ticket schedule materialization failed")
+		}
+		if stats.Created > 0 || stats.Failed > 0 {
+			s.log.Info().
+				Int("schedules", stats.Schedules).
+				Int("created", stats.Created).
+				Int("failed", stats.Failed).
+				Msg("ticket schedule materialization finished")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"ticket-service/internal/plate"
+)
+
+// MaxANPRWebhookBatchSize ограничивает количество событий в одной доставке webhook
+const MaxANPRWebhookBatchSize = 500
+
+// IngestANPREventInput - событие, присланное ANPR сервисом (формат совпадает с client.ANPREvent)
+type IngestANPREventInput struct {
+	// ID - идентификатор события в ANPR, используется как external_id для дедупликации
+	ID           string
+	PlateNumber  string
+	EventTime    string
+	Direction    string
+	SnowVolumeM3 *float64
+	CameraID     string
+	PolygonID    *string
+}
+
+// ANPREventResult - результат обработки одного события webhook
+type ANPREventResult struct {
+	ID     string             `json:"id"`
+	Status string             `json:"status"`
+	Error  string             `json:"error,omitempty"`
+	Lpr    *LprEventResult    `json:"lpr,omitempty"`
+	Volume *VolumeEventResult `json:"volume,omitempty"`
+}
+
+// IngestANPREvents сохраняет события, присланные ANPR, в lpr_events/volume_events и сразу
+// обновляет рейсы, как при приеме событий от камер. Объем (snow_volume_m3) сохраняется первым,
+// чтобы въезд LPR сразу нашел его при открытии рейса. Повторная доставка события
+// с тем же ID не создает дубликатов.
+// Возвращает результат по каждому событию; ошибки отдельных событий не прерывают обработку.
+func (s *EventService) IngestANPREvents(ctx context.Context, inputs []IngestANPREventInput) ([]ANPREventResult, error) {
+	if len(inputs) == 0 || len(inputs) > MaxANPRWebhookBatchSize {
+		return nil, ErrInvalidInput
+	}
+
+	results := make([]ANPREventResult, 0, len(inputs))
+	for _, input := range inputs {
+		item := ANPREventResult{ID: input.ID}
+
+		err := s.ingestANPREvent(ctx, input, &item)
+		switch {
+		case err == nil:
+			item.Status = TripIngestStatusCreated
+		case errors.Is(err, ErrConflict):
+			item.Status = TripIngestStatusConflict
+			item.Error = err.Error()
+		case errors.Is(err, ErrNotFound):
+			item.Status = TripIngestStatusNotFound
+			item.Error = err.Error()
+		case errors.Is(err, ErrInvalidInput):
+			item.Status = TripIngestStatusInvalidInput
+			item.Error = err.Error()
+		default:
+			s.log.Error().
+				Err(err).
+				Str("anpr_event_id", input.ID).
+				Msg("failed to ingest ANPR webhook event")
+			item.Status = TripIngestStatusError
+			item.Error = "internal error"
+		}
+
+		results = append(results, item)
+	}
+
+	return results, nil
+}
+
+func (s *EventService) ingestANPREvent(ctx context.Context, input IngestANPREventInput, item *ANPREventResult) error {
+	if input.ID == "" {
+		return ErrInvalidInput
+	}
+	// Номер проверяется до сохранения объема, чтобы не оставить volume событие без LPR
+	if canonical, _ := plate.Canonical(input.PlateNumber); canonical == "" {
+		return ErrInvalidInput
+	}
+	externalID := input.ID
+
+	if input.SnowVolumeM3 != nil {
+		volume, err := s.IngestVolumeEvent(ctx, IngestVolumeEventInput{
+			ExternalID:     &externalID,
+			CameraID:       input.CameraID,
+			PolygonID:      input.PolygonID,
+			DetectedVolume: *input.SnowVolumeM3,
+			DetectedAt:     input.EventTime,
+			Direction:      input.Direction,
+		})
+		if err != nil {
+			return err
+		}
+		item.Volume = volume
+	}
+
+	lpr, err := s.IngestLprEvent(ctx, IngestLprEventInput{
+		ExternalID:  &externalID,
+		CameraID:    input.CameraID,
+		PolygonID:   input.PolygonID,
+		PlateNumber: input.PlateNumber,
+		DetectedAt:  input.EventTime,
+		Direction:   input.Direction,
+	})
+	if err != nil {
+		return err
+	}
+	item.Lpr = lpr
+	return nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -16,6 +17,8 @@ type AppealService struct {
 	tripRepo       *repository.TripRepository
 	ticketRepo     *repository.TicketRepository
 	assignmentRepo *repository.AssignmentRepository
+	violationRepo  *repository.TripViolationRepository
+	tripService    *TripService
 }
 
 func NewAppealService(
@@ -23,17 +26,23 @@ func NewAppealService(
 	tripRepo *repository.TripRepository,
 	ticketRepo *repository.TicketRepository,
 	assignmentRepo *repository.AssignmentRepository,
+	violationRepo *repository.TripViolationRepository,
+	tripService *TripService,
 ) *AppealService {
 	return &AppealService{
 		appealRepo:     appealRepo,
 		tripRepo:       tripRepo,
 		ticketRepo:     ticketRepo,
 		assignmentRepo: assignmentRepo,
+		violationRepo:  violationRepo,
+		tripService:    tripService,
 	}
 }
 
 type CreateAppealInput struct {
-	TripID           string
+	TripID string
+	// TripViolationID - конкретное обжалуемое нарушение; пусто - обжалуется рейс целиком
+	TripViolationID  string
 	AppealReasonType string
 	Comment          string
 }
@@ -62,9 +71,50 @@ func (s *AppealService) Create(ctx context.Context, principal model.Principal, i
 		return nil, ErrPermissionDenied
 	}
 
-	// Можно обжаловать только рейсы с нарушениями
-	if trip.Status == model.TripStatusOK {
-		return nil, ErrConflict
+	var violation *model.TripViolation
+	if input.TripViolationID != "" {
+		v, err := s.violationRepo.GetByID(ctx, input.TripViolationID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+		if v.TripID != tripID {
+			return nil, ErrInvalidInput
+		}
+		violation = v
+	}
+
+	var violationID *uuid.UUID
+	if violation != nil {
+		violationID = &violation.ID
+	}
+
+	// Повторная подача по тому же рейсу/нарушению возвращает уже открытое обжалование
+	existing, err := s.appealRepo.FindOpenByTripAndUser(ctx, tripID, principal.UserID, violationID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	// Можно обжаловать только не снятые нарушения
+	reason := string(trip.Status)
+	if violation != nil {
+		if violation.IsResolved() {
+			return nil, ErrConflict
+		}
+		reason = string(violation.Type)
+	} else {
+		hasOpen, err := s.violationRepo.HasUnresolved(ctx, tripID)
+		if err != nil {
+			return nil, err
+		}
+		if !hasOpen && trip.Status == model.TripStatusOK {
+			return nil, ErrConflict
+		}
 	}
 
 	var ticketID *uuid.UUID
@@ -75,9 +125,10 @@ func (s *AppealService) Create(ctx context.Context, principal model.Principal, i
 	appeal := &model.Appeal{
 		TripID:           &tripID,
 		TicketID:         ticketID,
+		TripViolationID:  violationID,
 		CreatedByUserID:  principal.UserID,
 		Status:           model.AppealStatusSubmitted,
-		Reason:           string(trip.Status), // Нарушение из статуса рейса
+		Reason:           reason, // Обжалуемое нарушение
 		AppealReasonType: &input.AppealReasonType,
 		Comment:          input.Comment,
 	}
@@ -226,12 +277,37 @@ func (s *AppealService) UpdateStatus(ctx context.Context, principal model.Princi
 		}
 	}
 
+	switch status {
+	case model.AppealStatusSubmitted, model.AppealStatusUnderReview, model.AppealStatusNeedInfo:
+	case model.AppealStatusApproved, model.AppealStatusRejected, model.AppealStatusClosed:
+		if appeal.ResolvedAt == nil {
+			now := time.Now()
+			appeal.ResolvedAt = &now
+		}
+	default:
+		return ErrInvalidInput
+	}
+
 	appeal.Status = status
 	if adminResponse != nil {
 		appeal.AdminResponse = adminResponse
 	}
 
-	return s.appealRepo.Update(ctx, appeal)
+	if err := s.appealRepo.Update(ctx, appeal); err != nil {
+		return err
+	}
+
+	// Одобренное обжалование снимает нарушение (или все нарушения рейса) и пересчитывает статус рейса
+	if status == model.AppealStatusApproved && appeal.TripID != nil {
+		if err := s.violationRepo.ResolveByAppeal(ctx, *appeal.TripID, appeal.TripViolationID, appeal.ID); err != nil {
+			return err
+		}
+		if _, err := s.tripService.Reevaluate(ctx, *appeal.TripID); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *AppealService) AddComment(ctx context.Context, principal model.Principal, appealID string, content string) error {
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+// maxAvailabilityRange - максимальное окно запроса занятости водителей и машин
+const maxAvailabilityRange = 31 * 24 * time.Hour
+
+const (
+	AssignmentResourceDriver  = "driver"
+	AssignmentResourceVehicle = "vehicle"
+)
+
+// AssignmentConflict - назначение на другой тикет, из-за которого водитель или машина (Resource)
+// заняты в окне нового назначения
+type AssignmentConflict struct {
+	Resource string `json:"resource"`
+	repository.AssignmentBooking
+}
+
+// AssignmentOverlapError - водитель или машина уже назначены на тикет с пересекающимся окном.
+// Является ErrConflict (errors.Is); Conflicts - пересекающиеся назначения для ответа API.
+type AssignmentOverlapError struct {
+	Conflicts []AssignmentConflict
+}
+
+// newAssignmentOverlapError отбирает из bookings назначения, занимающие водителя или машину
+// одного из assignments
+func newAssignmentOverlapError(bookings []repository.AssignmentBooking, assignments ...model.TicketAssignment) *AssignmentOverlapError {
+	drivers := make(map[uuid.UUID]bool)
+	vehicles := make(map[uuid.UUID]bool)
+	for _, assignment := range assignments {
+		drivers[assignment.DriverID] = true
+		vehicles[assignment.VehicleID] = true
+	}
+
+	var conflicts []AssignmentConflict
+	for _, booking := range bookings {
+		if drivers[booking.DriverID] {
+			conflicts = append(conflicts, AssignmentConflict{Resource: AssignmentResourceDriver, AssignmentBooking: booking})
+		}
+		if vehicles[booking.VehicleID] {
+			conflicts = append(conflicts, AssignmentConflict{Resource: AssignmentResourceVehicle, AssignmentBooking: booking})
+		}
+	}
+	return &AssignmentOverlapError{Conflicts: conflicts}
+}
+
+func (e *AssignmentOverlapError) Error() string {
+	return fmt.Sprintf("conflict: driver or vehicle already assigned to %d overlapping ticket(s)", len(e.Conflicts))
+}
+
+func (e *AssignmentOverlapError) Unwrap() error {
+	return ErrConflict
+}
+
+// AvailabilityInput - окно для проверки занятости: либо TicketID (окно тикета), либо From/To (RFC3339).
+// DriverIDs/VehicleIDs - кого проверять; пусто - все водители и машины, работавшие на тикетах подрядчика.
+type AvailabilityInput struct {
+	TicketID   string
+	From       string
+	To         string
+	DriverIDs  []string
+	VehicleIDs []string
+}
+
+// ResourceAvailability - занятость водителя или машины в окне; Bookings - пересекающиеся назначения
+type ResourceAvailability struct {
+	ID        uuid.UUID                      `json:"id"`
+	Available bool                           `json:"available"`
+	Bookings  []repository.AssignmentBooking `json:"bookings,omitempty"`
+}
+
+type AvailabilityResult struct {
+	From     time.Time              `json:"from"`
+	To       time.Time              `json:"to"`
+	Drivers  []ResourceAvailability `json:"drivers"`
+	Vehicles []ResourceAvailability `json:"vehicles"`
+}
+
+// Availability показывает подрядчику, какие водители и машины свободны в окне, то есть не назначены
+// на другие активные тикеты с пересекающимся плановым окном (по тем же правилам, что и Create)
+func (s *AssignmentService) Availability(ctx context.Context, principal model.Principal, input AvailabilityInput) (*AvailabilityResult, error) {
+	if !principal.IsContractor() {
+		return nil, ErrPermissionDenied
+	}
+
+	var from, to time.Time
+	excludeTicketID := uuid.Nil
+	if strings.TrimSpace(input.TicketID) != "" {
+		if _, err := uuid.Parse(strings.TrimSpace(input.TicketID)); err != nil {
+			return nil, ErrInvalidInput
+		}
+		ticket, err := s.ticketRepo.GetByID(ctx, strings.TrimSpace(input.TicketID))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+		if ticket.ContractorID != principal.OrgID {
+			return nil, ErrPermissionDenied
+		}
+		// Назначения на сам тикет не делают водителя занятым для него
+		from, to, excludeTicketID = ticket.PlannedStartAt, ticket.PlannedEndAt, ticket.ID
+	} else {
+		var err error
+		if from, err = time.Parse(time.RFC3339, strings.TrimSpace(input.From)); err != nil {
+			return nil, fmt.Errorf("%w: from must be RFC3339", ErrInvalidInput)
+		}
+		if to, err = time.Parse(time.RFC3339, strings.TrimSpace(input.To)); err != nil {
+			return nil, fmt.Errorf("%w: to must be RFC3339", ErrInvalidInput)
+		}
+		if !to.After(from) || to.Sub(from) > maxAvailabilityRange {
+			return nil, fmt.Errorf("%w: to must be after from, window up to 31 days", ErrInvalidInput)
+		}
+	}
+
+	driverIDs, err := parseUUIDList(input.DriverIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: driver_ids must be UUIDs", ErrInvalidInput)
+	}
+	vehicleIDs, err := parseUUIDList(input.VehicleIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: vehicle_ids must be UUIDs", ErrInvalidInput)
+	}
+	if len(driverIDs) == 0 && len(vehicleIDs) == 0 {
+		if driverIDs, vehicleIDs, err = s.assignmentRepo.ListContractorFleet(ctx, principal.OrgID); err != nil {
+			return nil, err
+		}
+	}
+
+	bookings, err := s.assignmentRepo.ListBookings(ctx, driverIDs, vehicleIDs, from, to, excludeTicketID)
+	if err != nil {
+		return nil, err
+	}
+
+	byDriver := make(map[uuid.UUID][]repository.AssignmentBooking)
+	byVehicle := make(map[uuid.UUID][]repository.AssignmentBooking)
+	for _, booking := range bookings {
+		byDriver[booking.DriverID] = append(byDriver[booking.DriverID], booking)
+		byVehicle[booking.VehicleID] = append(byVehicle[booking.VehicleID], booking)
+	}
+
+	resources := func(ids []uuid.UUID, booked map[uuid.UUID][]repository.AssignmentBooking) []ResourceAvailability {
+		result := make([]ResourceAvailability, 0, len(ids))
+		for _, id := range ids {
+			result = append(result, ResourceAvailability{ID: id, Available: len(booked[id]) == 0, Bookings: booked[id]})
+		}
+		return result
+	}
+
+	return &AvailabilityResult{
+		From:     from,
+		To:       to,
+		Drivers:  resources(driverIDs, byDriver),
+		Vehicles: resources(vehicleIDs, byVehicle),
+	}, nil
+}
+
+// parseUUIDList разбирает список идентификаторов, пропуская пустые значения и повторы
+func parseUUIDList(values []string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+// Веса признаков при сопоставлении рейса с назначением
+const (
+	matchScoreVehicle          = 4
+	matchScoreDriver           = 2
+	matchScoreActive           = 1
+	matchScoreAssignmentWindow = 8
+	matchScoreFactWindow       = 3
+	matchScorePlannedWindow    = 2
+)
+
+// AssignmentMatchCandidate - назначение, подходящее под рейс, с оценкой совпадения
+type AssignmentMatchCandidate struct {
+	AssignmentID uuid.UUID `json:"assignment_id"`
+	TicketID     uuid.UUID `json:"ticket_id"`
+	DriverID     uuid.UUID `json:"driver_id"`
+	VehicleID    uuid.UUID `json:"vehicle_id"`
+	Score        int       `json:"score"`
+	Reasons      []string  `json:"reasons"`
+}
+
+// AssignmentMatch - результат сопоставления рейса с назначением
+type AssignmentMatch struct {
+	Assignment *model.TicketAssignment
+	Ambiguous  bool
+	Candidates []AssignmentMatchCandidate
+}
+
+// matchAssignment подбирает назначение для рейса по времени въезда, водителю и машине.
+// Кандидат должен попадать во временное окно рейса назначения или окно тикета.
+// Если тикет указан явно, рассматриваются только его назначения.
+// Если несколько назначений набирают одинаковый максимальный балл, рейс не привязывается,
+// а кандидаты возвращаются для ручного разбора.
+func (s *TripService) matchAssignment(ctx context.Context, driverID, vehicleID, ticketID *uuid.UUID, entryAt time.Time) (*AssignmentMatch, error) {
+	assignments, err := s.assignmentRepo.ListCandidatesForTrip(ctx, driverID, vehicleID, entryAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assignment candidates: %w", err)
+	}
+	return s.pickAssignment(ctx, assignments, driverID, vehicleID, ticketID, entryAt)
+}
+
+// matchAssignmentForReconciliation подбирает назначение для ранее сохраненного рейса без назначения.
+// Назначение могло появиться уже после въезда, поэтому кандидаты отбираются по окну тикета,
+// а не по времени создания назначения.
+func (s *TripService) matchAssignmentForReconciliation(ctx context.Context, driverID, vehicleID *uuid.UUID, entryAt time.Time) (*AssignmentMatch, error) {
+	assignments, err := s.assignmentRepo.ListCandidatesForReconciliation(ctx, driverID, vehicleID, entryAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assignment candidates: %w", err)
+	}
+	return s.pickAssignment(ctx, assignments, driverID, vehicleID, nil, entryAt)
+}
+
+// pickAssignment оценивает кандидатов и выбирает назначение с наибольшим баллом
+func (s *TripService) pickAssignment(ctx context.Context, assignments []model.TicketAssignment, driverID, vehicleID, ticketID *uuid.UUID, entryAt time.Time) (*AssignmentMatch, error) {
+	tickets := make(map[uuid.UUID]*model.Ticket)
+	candidates := make([]AssignmentMatchCandidate, 0, len(assignments))
+	byID := make(map[uuid.UUID]*model.TicketAssignment, len(assignments))
+
+	for i := range assignments {
+		a := &assignments[i]
+		if ticketID != nil && a.TicketID != *ticketID {
+			continue
+		}
+
+		ticket, ok := tickets[a.TicketID]
+		if !ok {
+			t, err := s.ticketRepo.GetByID(ctx, a.TicketID.String())
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			ticket = t
+			tickets[a.TicketID] = t
+		}
+		if ticket == nil || ticket.Status == model.TicketStatusCancelled {
+			continue
+		}
+
+		candidate, ok := scoreAssignment(a, ticket, driverID, vehicleID, entryAt)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate)
+		byID[a.ID] = a
+	}
+
+	match := &AssignmentMatch{Candidates: candidates}
+	if len(candidates) == 0 {
+		return match, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	if len(candidates) > 1 && candidates[0].Score == candidates[1].Score {
+		match.Ambiguous = true
+		return match, nil
+	}
+
+	match.Assignment = byID[candidates[0].AssignmentID]
+	return match, nil
+}
+
+// scoreAssignment оценивает, насколько назначение подходит под рейс.
+// Возвращает false, если время въезда не попадает ни в одно окно назначения или тикета.
+func scoreAssignment(a *model.TicketAssignment, ticket *model.Ticket, driverID, vehicleID *uuid.UUID, entryAt time.Time) (AssignmentMatchCandidate, bool) {
+	candidate := AssignmentMatchCandidate{
+		AssignmentID: a.ID,
+		TicketID:     a.TicketID,
+		DriverID:     a.DriverID,
+		VehicleID:    a.VehicleID,
+	}
+	add := func(score int, reason string) {
+		candidate.Score += score
+		candidate.Reasons = append(candidate.Reasons, reason)
+	}
+
+	if vehicleID != nil && a.VehicleID == *vehicleID {
+		add(matchScoreVehicle, "vehicle")
+	}
+	if driverID != nil && a.DriverID == *driverID {
+		add(matchScoreDriver, "driver")
+	}
+	if a.IsActive {
+		add(matchScoreActive, "active")
+	}
+
+	inWindow := false
+
+	// Окно рейса, отмеченное водителем
+	if a.TripStartedAt != nil && !entryAt.Before(*a.TripStartedAt) &&
+		(a.TripFinishedAt == nil || !entryAt.After(*a.TripFinishedAt)) {
+		add(matchScoreAssignmentWindow, "assignment_window")
+		inWindow = true
+	}
+
+	// Фактический период работ по тикету
+	if ticket.FactStartAt != nil && !entryAt.Before(*ticket.FactStartAt) &&
+		(ticket.FactEndAt == nil || !entryAt.After(*ticket.FactEndAt)) {
+		add(matchScoreFactWindow, "ticket_fact_window")
+		inWindow = true
+	}
+
+	// Плановый период тикета
+	if !entryAt.Before(ticket.PlannedStartAt) && !entryAt.After(ticket.PlannedEndAt) {
+		add(matchScorePlannedWindow, "ticket_planned_window")
+		inWindow = true
+	}
+
+	return candidate, inWindow
+}
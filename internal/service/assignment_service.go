@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -76,6 +78,15 @@ func (s *AssignmentService) Create(ctx context.Context, principal model.Principa
 		return nil, ErrConflict
 	}
 
+	// Повторный запрос на то же назначение возвращает уже существующее
+	existing, err := s.assignmentRepo.FindActiveDuplicate(ctx, ticketID, driverID, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
 	assignment := &model.TicketAssignment{
 		TicketID:         ticketID,
 		DriverID:         driverID,
@@ -84,9 +95,14 @@ func (s *AssignmentService) Create(ctx context.Context, principal model.Principa
 		IsActive:         true,
 	}
 
-	if err := s.assignmentRepo.Create(ctx, assignment); err != nil {
+	// Водитель и машина не могут работать на двух тикетах с пересекающимися окнами одновременно
+	conflicts, err := s.assignmentRepo.CreateIfFree(ctx, assignment, ticket.PlannedStartAt, ticket.PlannedEndAt)
+	if err != nil {
 		return nil, err
 	}
+	if len(conflicts) > 0 {
+		return nil, newAssignmentOverlapError(conflicts, *assignment)
+	}
 
 	return assignment, nil
 }
@@ -121,105 +137,237 @@ func (s *AssignmentService) Delete(ctx context.Context, principal model.Principa
 	return s.assignmentRepo.Delete(ctx, id)
 }
 
-func (s *AssignmentService) UpdateDriverMarkStatus(ctx context.Context, principal model.Principal, id string, status model.DriverMarkStatus) error {
-	// Только водитель может обновлять свой статус
-	if !principal.IsDriver() || principal.DriverID == nil {
-		return ErrPermissionDenied
+// ReassignInput - новые водитель и/или машина; пустое поле - остается прежним
+type ReassignInput struct {
+	DriverID  string
+	VehicleID string
+	Comment   string
+}
+
+// Reassign передает работу по назначению другому водителю и/или машине (например, при поломке):
+// текущее назначение снимается (unassigned_at), а вместо него создается новое, связанное с ним через
+// previous_assignment_id. Рейсы, выполненные до переназначения, остаются за прежним назначением;
+// открытый рейс закрывается и по нему создается trip на прежних водителя и машину.
+func (s *AssignmentService) Reassign(ctx context.Context, principal model.Principal, id string, input ReassignInput) (*model.TicketAssignment, error) {
+	if !principal.IsContractor() {
+		return nil, ErrPermissionDenied
 	}
 
 	assignment, err := s.assignmentRepo.GetByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrNotFound
+			return nil, ErrNotFound
 		}
-		return err
+		return nil, err
 	}
 
-	// Проверяем, что это назначение принадлежит водителю
-	if assignment.DriverID != *principal.DriverID {
-		return ErrPermissionDenied
+	successor := &model.TicketAssignment{DriverID: assignment.DriverID, VehicleID: assignment.VehicleID}
+	if value := strings.TrimSpace(input.DriverID); value != "" {
+		if successor.DriverID, err = uuid.Parse(value); err != nil {
+			return nil, fmt.Errorf("%w: driver_id must be UUID", ErrInvalidInput)
+		}
+	}
+	if value := strings.TrimSpace(input.VehicleID); value != "" {
+		if successor.VehicleID, err = uuid.Parse(value); err != nil {
+			return nil, fmt.Errorf("%w: vehicle_id must be UUID", ErrInvalidInput)
+		}
+	}
+	if successor.DriverID == assignment.DriverID && successor.VehicleID == assignment.VehicleID {
+		return nil, fmt.Errorf("%w: driver_id or vehicle_id must differ from the current assignment", ErrInvalidInput)
 	}
 
-	// Проверяем статус тикета
 	ticket, err := s.ticketRepo.GetByID(ctx, assignment.TicketID.String())
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if ticket.Status == model.TicketStatusCancelled || ticket.Status == model.TicketStatusClosed || ticket.Status == model.TicketStatusCompleted {
-		return ErrConflict
+	if ticket.ContractorID != principal.OrgID {
+		return nil, ErrPermissionDenied
+	}
+	if !isTicketMutableForAssignments(ticket.Status) || !assignment.IsActive || assignment.DriverMarkStatus.IsFinished() {
+		return nil, ErrConflict
 	}
 
-	// Обновляем статус и временные метки
-	now := time.Now()
-	if status == model.DriverMarkStatusInWork {
-		// Проверяем, что рейс еще не начат
-		if assignment.TripStartedAt != nil {
-			return ErrConflict // рейс уже начат
-		}
-		// При начале работы фиксируем время начала рейса и обновляем статус
-		if err := s.assignmentRepo.UpdateTripStartedAt(ctx, id, now, status); err != nil {
+	event := newDriverStatusEvent(principal, assignment, model.DriverStatusEventReassigned)
+	if comment := strings.TrimSpace(input.Comment); comment != "" {
+		event.Comment = &comment
+	}
+
+	round, conflicts, reassigned, err := s.assignmentRepo.Reassign(ctx, assignment.ID, successor, ticket.PlannedStartAt, ticket.PlannedEndAt, event)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, newAssignmentOverlapError(conflicts, *successor)
+	}
+	if !reassigned {
+		return nil, ErrConflict // назначение успели снять или смена завершена
+	}
+	// assignment - снимок до переназначения: trip закрытого рейса создается на прежних водителя и машину
+	if round != nil {
+		s.completeRound(ctx, assignment, round)
+	}
+
+	result, err := s.attachRounds(ctx, []model.TicketAssignment{*successor})
+	if err != nil {
+		return nil, err
+	}
+	return &result[0], nil
+}
+
+// UpdateDriverMarkStatus обрабатывает отметки водителя. Каждая отметка IN_WORK открывает новый рейс
+// назначения, COMPLETED закрывает текущий рейс и создает по нему trip. Назначение остается в IN_WORK
+// (или PAUSED), пока водитель не завершит смену (EndShift). Отметки пишутся в driver_status_events.
+func (s *AssignmentService) UpdateDriverMarkStatus(ctx context.Context, principal model.Principal, id string, status model.DriverMarkStatus) error {
+	assignment, ticket, err := s.driverAssignment(ctx, principal, id)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case model.DriverMarkStatusInWork:
+		event := newDriverStatusEvent(principal, assignment, model.DriverStatusEventRoundStarted)
+		round, err := s.assignmentRepo.StartRound(ctx, assignment.ID, event)
+		if err != nil {
 			return err
 		}
-		assignment.TripStartedAt = &now
-	} else if status == model.DriverMarkStatusCompleted {
-		// Проверяем, что рейс был начат
-		if assignment.TripStartedAt == nil {
-			return ErrConflict // нельзя завершить рейс, который не был начат
-		}
-		// Проверяем, что рейс еще не завершен
-		if assignment.TripFinishedAt != nil {
-			return ErrConflict // рейс уже завершен
+		if round == nil {
+			return ErrConflict // предыдущий рейс не завершен, водитель на паузе или смена уже закончена
 		}
-		// При завершении фиксируем время окончания рейса и обновляем статус
-		if err := s.assignmentRepo.UpdateTripFinishedAt(ctx, id, now, status); err != nil {
+		// Первый факт работы переводит тикет в IN_PROGRESS
+		if err := s.ticketService.OnDriverStarted(ctx, ticket, principal); err != nil {
 			return err
 		}
-		assignment.TripFinishedAt = &now
-	} else {
-		// Для других статусов просто обновляем статус
-		if err := s.assignmentRepo.UpdateDriverMarkStatus(ctx, id, status); err != nil {
+	case model.DriverMarkStatusCompleted:
+		event := newDriverStatusEvent(principal, assignment, model.DriverStatusEventRoundFinished)
+		round, err := s.assignmentRepo.FinishRound(ctx, assignment.ID, event)
+		if err != nil {
 			return err
 		}
+		if round == nil {
+			return ErrConflict // нельзя завершить рейс, который не был начат
+		}
+		s.completeRound(ctx, assignment, round)
+	default:
+		// Для других статусов просто обновляем статус
+		return s.assignmentRepo.UpdateDriverMarkStatus(ctx, id, status)
 	}
 
-	// Если водитель отметил "В работе", проверяем, нужно ли перевести тикет в IN_PROGRESS
-	if status == model.DriverMarkStatusInWork {
-		if ticket.Status == model.TicketStatusPlanned && ticket.FactStartAt == nil {
-			now := time.Now()
-			ticket.Status = model.TicketStatusInProgress
-			ticket.FactStartAt = &now
-			if err := s.ticketRepo.Update(ctx, ticket); err != nil {
-				return err
-			}
-		}
+	return nil
+}
+
+// EndShift завершает смену водителя по назначению (в том числе с паузы): закрывает текущий рейс,
+// если он открыт, и переводит назначение в SHIFT_ENDED. После этого тикет может автоматически
+// перейти в COMPLETED.
+func (s *AssignmentService) EndShift(ctx context.Context, principal model.Principal, id string) error {
+	assignment, _, err := s.driverAssignment(ctx, principal, id)
+	if err != nil {
+		return err
 	}
 
-	if status == model.DriverMarkStatusCompleted {
-		// Рассчитываем объем и создаем/обновляем trip
-		// Если расчет объема или создание trip не удались, рейс все равно считается завершенным
-		// (assignment уже обновлен с trip_finished_at и COMPLETED статусом)
-		if s.tripService != nil {
-			assignmentUUID, err := uuid.Parse(id)
-			if err == nil {
-				_, err := s.tripService.CompleteTripAndCalculateVolume(ctx, assignmentUUID)
-				if err != nil {
-					// Логируем ошибку, но НЕ прерываем выполнение
-					// Рейс должен быть завершен даже если расчет объема или создание trip не удались
-					// Согласно ТЗ, лучше завершить рейс с объемом 0, чем вернуть ошибку пользователю
-					// Ошибка уже залогирована в CompleteTripAndCalculateVolume
-				}
-			}
-		}
+	event := newDriverStatusEvent(principal, assignment, model.DriverStatusEventShiftEnded)
+	round, ended, err := s.assignmentRepo.EndShift(ctx, assignment.ID, event)
+	if err != nil {
+		return err
+	}
+	if !ended {
+		return ErrConflict // смена не начата или уже завершена
+	}
+	if round != nil {
+		s.completeRound(ctx, assignment, round)
+	}
 
-		if err := s.ticketService.TryAutoComplete(ctx, assignment.TicketID); err != nil {
-			return err
+	return s.ticketService.TryAutoComplete(ctx, assignment.TicketID)
+}
+
+// driverAssignment загружает назначение водителя principal вместе с тикетом и проверяет,
+// что по тикету еще можно отмечать рейсы
+func (s *AssignmentService) driverAssignment(ctx context.Context, principal model.Principal, id string) (*model.TicketAssignment, *model.Ticket, error) {
+	// Только водитель может обновлять свой статус
+	if !principal.IsDriver() || principal.DriverID == nil {
+		return nil, nil, ErrPermissionDenied
+	}
+
+	assignment, err := s.assignmentRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrNotFound
 		}
+		return nil, nil, err
 	}
 
-	return nil
+	// Проверяем, что это назначение принадлежит водителю
+	if assignment.DriverID != *principal.DriverID {
+		return nil, nil, ErrPermissionDenied
+	}
+	// Снятое назначение (снято подрядчиком или заменено при возврате тикета на доработку)
+	if !assignment.IsActive {
+		return nil, nil, ErrConflict
+	}
+
+	// Проверяем статус тикета
+	ticket, err := s.ticketRepo.GetByID(ctx, assignment.TicketID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	if ticket.Status == model.TicketStatusCancelled || ticket.Status == model.TicketStatusClosed || ticket.Status == model.TicketStatusCompleted {
+		return nil, nil, ErrConflict
+	}
+
+	return assignment, ticket, nil
+}
+
+// completeRound создает trip по завершенному рейсу и ставит расчет объема в очередь.
+// Если это не удалось, рейс все равно считается завершенным (finished_at уже сохранен).
+func (s *AssignmentService) completeRound(ctx context.Context, assignment *model.TicketAssignment, round *model.AssignmentRound) {
+	if s.tripService == nil {
+		return
+	}
+	if _, err := s.tripService.CompleteRound(ctx, assignment, round); err != nil {
+		s.tripService.log.Error().
+			Err(err).
+			Str("assignment_id", assignment.ID.String()).
+			Int("round", round.Number).
+			Msg("failed to complete trip")
+	}
 }
 
 func (s *AssignmentService) ListByTicketID(ctx context.Context, principal model.Principal, ticketID string) ([]model.TicketAssignment, error) {
+	ticket, err := s.ticketForAssignments(ctx, principal, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.assignmentRepo.ListByTicketID(ctx, ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.attachRounds(ctx, visibleAssignments(principal, assignments))
+}
+
+// History возвращает все назначения тикета, включая снятые (is_active=false, unassigned_at),
+// в порядке назначения. Цепочку переназначений можно восстановить по previous_assignment_id.
+func (s *AssignmentService) History(ctx context.Context, principal model.Principal, ticketID string) ([]model.TicketAssignment, error) {
+	ticket, err := s.ticketForAssignments(ctx, principal, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.assignmentRepo.ListHistoryByTicketID(ctx, ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.attachRounds(ctx, visibleAssignments(principal, assignments))
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = []model.TicketAssignment{}
+	}
+	return result, nil
+}
+
+// ticketForAssignments загружает тикет и проверяет, что principal может видеть его назначения
+func (s *AssignmentService) ticketForAssignments(ctx context.Context, principal model.Principal, ticketID string) (*model.Ticket, error) {
 	ticket, err := s.ticketRepo.GetByID(ctx, ticketID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -250,24 +398,61 @@ func (s *AssignmentService) ListByTicketID(ctx context.Context, principal model.
 		if !has {
 			return nil, ErrPermissionDenied
 		}
-		// Водитель видит только свои назначения
-		assignments, err := s.assignmentRepo.ListByTicketID(ctx, ticket.ID)
-		if err != nil {
-			return nil, err
-		}
-		// Фильтруем по driver_id
-		var result []model.TicketAssignment
-		for _, a := range assignments {
-			if a.DriverID == *principal.DriverID {
-				result = append(result, a)
-			}
-		}
-		return result, nil
 	} else {
 		return nil, ErrPermissionDenied
 	}
+	return ticket, nil
+}
 
-	return s.assignmentRepo.ListByTicketID(ctx, ticket.ID)
+// visibleAssignments оставляет водителю только его назначения
+func visibleAssignments(principal model.Principal, assignments []model.TicketAssignment) []model.TicketAssignment {
+	if !principal.IsDriver() || principal.DriverID == nil {
+		return assignments
+	}
+	var result []model.TicketAssignment
+	for _, a := range assignments {
+		if a.DriverID == *principal.DriverID {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// attachRounds заполняет рейсы назначений и рабочее время смены (без пауз) по журналу отметок
+func (s *AssignmentService) attachRounds(ctx context.Context, assignments []model.TicketAssignment) ([]model.TicketAssignment, error) {
+	ids := make([]uuid.UUID, 0, len(assignments))
+	for _, a := range assignments {
+		ids = append(ids, a.ID)
+	}
+	rounds, err := s.assignmentRepo.ListRounds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byAssignment := make(map[uuid.UUID][]model.AssignmentRound)
+	for _, round := range rounds {
+		byAssignment[round.AssignmentID] = append(byAssignment[round.AssignmentID], round)
+	}
+
+	events, err := s.assignmentRepo.ListStatusEvents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	eventsByAssignment := make(map[uuid.UUID][]model.DriverStatusEvent)
+	for _, event := range events {
+		eventsByAssignment[event.AssignmentID] = append(eventsByAssignment[event.AssignmentID], event)
+	}
+
+	now := time.Now()
+	for i := range assignments {
+		assignments[i].Rounds = byAssignment[assignments[i].ID]
+		// Для назначений, отмеченных до появления журнала, рабочее время неизвестно
+		if assignmentEvents := eventsByAssignment[assignments[i].ID]; len(assignmentEvents) > 0 {
+			seconds := int64(workingDuration(assignmentEvents, now) / time.Second)
+			assignments[i].WorkingSeconds = &seconds
+		}
+	}
+	return assignments, nil
 }
 
 func isTicketMutableForAssignments(status model.TicketStatus) bool {
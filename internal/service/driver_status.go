@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+// PauseInput - причина паузы (REFUELLING, BREAKDOWN, BREAK, OTHER) и необязательный комментарий
+type PauseInput struct {
+	Reason  string
+	Comment string
+}
+
+// ChangeVehicleInput - новая машина назначения и необязательный комментарий (например, описание поломки)
+type ChangeVehicleInput struct {
+	VehicleID string
+	Comment   string
+}
+
+// newDriverStatusEvent готовит запись журнала driver_status_events от имени principal.
+// Статусы до и после заполняет репозиторий в транзакции смены статуса.
+func newDriverStatusEvent(principal model.Principal, assignment *model.TicketAssignment, eventType model.DriverStatusEventType) *model.DriverStatusEvent {
+	return &model.DriverStatusEvent{
+		DriverID:    assignment.DriverID,
+		Event:       eventType,
+		ActorUserID: principal.UserID,
+		ActorRole:   principal.Role,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// Pause ставит смену водителя на паузу (заправка, поломка, перерыв). Текущий рейс не закрывается,
+// но время паузы не считается рабочим, а события ANPR за паузу не входят в объем рейса.
+func (s *AssignmentService) Pause(ctx context.Context, principal model.Principal, id string, input PauseInput) error {
+	reason := model.PauseReason(strings.ToUpper(strings.TrimSpace(input.Reason)))
+	if !reason.IsValid() {
+		return fmt.Errorf("%w: reason must be one of REFUELLING, BREAKDOWN, BREAK, OTHER", ErrInvalidInput)
+	}
+
+	assignment, _, err := s.driverAssignment(ctx, principal, id)
+	if err != nil {
+		return err
+	}
+
+	event := newDriverStatusEvent(principal, assignment, model.DriverStatusEventPaused)
+	event.PauseReason = &reason
+	if comment := strings.TrimSpace(input.Comment); comment != "" {
+		event.Comment = &comment
+	}
+
+	paused, err := s.assignmentRepo.Pause(ctx, assignment.ID, event)
+	if err != nil {
+		return err
+	}
+	if !paused {
+		return ErrConflict // смена не идет или уже на паузе
+	}
+	return nil
+}
+
+// Resume возвращает смену водителя с паузы в IN_WORK
+func (s *AssignmentService) Resume(ctx context.Context, principal model.Principal, id string) error {
+	assignment, _, err := s.driverAssignment(ctx, principal, id)
+	if err != nil {
+		return err
+	}
+
+	resumed, err := s.assignmentRepo.Resume(ctx, assignment.ID, newDriverStatusEvent(principal, assignment, model.DriverStatusEventResumed))
+	if err != nil {
+		return err
+	}
+	if !resumed {
+		return ErrConflict // назначение не на паузе
+	}
+	return nil
+}
+
+// ChangeVehicle заменяет машину назначения (например, при поломке). Открытый рейс закрывается и
+// засчитывается прежней машине, уже созданные рейсы не меняются; следующий рейс водитель начинает
+// на новой машине. Новая машина не должна быть занята на пересекающихся тикетах.
+func (s *AssignmentService) ChangeVehicle(ctx context.Context, principal model.Principal, id string, input ChangeVehicleInput) (*model.TicketAssignment, error) {
+	if !principal.IsContractor() {
+		return nil, ErrPermissionDenied
+	}
+
+	vehicleID, err := uuid.Parse(strings.TrimSpace(input.VehicleID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: vehicle_id must be UUID", ErrInvalidInput)
+	}
+
+	assignment, err := s.assignmentRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	ticket, err := s.ticketRepo.GetByID(ctx, assignment.TicketID.String())
+	if err != nil {
+		return nil, err
+	}
+	if ticket.ContractorID != principal.OrgID {
+		return nil, ErrPermissionDenied
+	}
+	if !isTicketMutableForAssignments(ticket.Status) || !assignment.IsActive || assignment.DriverMarkStatus.IsFinished() {
+		return nil, ErrConflict
+	}
+	if assignment.VehicleID == vehicleID {
+		return nil, fmt.Errorf("%w: vehicle is already assigned", ErrInvalidInput)
+	}
+
+	event := newDriverStatusEvent(principal, assignment, model.DriverStatusEventVehicleChanged)
+	if comment := strings.TrimSpace(input.Comment); comment != "" {
+		event.Comment = &comment
+	}
+
+	round, conflicts, err := s.assignmentRepo.ChangeVehicle(ctx, assignment.ID, vehicleID, ticket.PlannedStartAt, ticket.PlannedEndAt, event)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		overlapErr := &AssignmentOverlapError{}
+		for _, booking := range conflicts {
+			overlapErr.Conflicts = append(overlapErr.Conflicts, AssignmentConflict{Resource: AssignmentResourceVehicle, AssignmentBooking: booking})
+		}
+		return nil, overlapErr
+	}
+	// assignment - снимок до замены: trip закрытого рейса создается на прежнюю машину
+	if round != nil {
+		s.completeRound(ctx, assignment, round)
+	}
+
+	updated, err := s.assignmentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.attachRounds(ctx, []model.TicketAssignment{*updated})
+	if err != nil {
+		return nil, err
+	}
+	return &result[0], nil
+}
+
+// StatusEvents возвращает журнал отметок назначения: подрядчику тикета и водителю назначения
+func (s *AssignmentService) StatusEvents(ctx context.Context, principal model.Principal, id string) ([]model.DriverStatusEvent, error) {
+	assignment, err := s.assignmentRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	switch {
+	case principal.IsContractor():
+		ticket, err := s.ticketRepo.GetByID(ctx, assignment.TicketID.String())
+		if err != nil {
+			return nil, err
+		}
+		if ticket.ContractorID != principal.OrgID {
+			return nil, ErrPermissionDenied
+		}
+	case principal.IsDriver():
+		if principal.DriverID == nil || assignment.DriverID != *principal.DriverID {
+			return nil, ErrPermissionDenied
+		}
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	events, err := s.assignmentRepo.ListStatusEvents(ctx, []uuid.UUID{assignment.ID})
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []model.DriverStatusEvent{}
+	}
+	return events, nil
+}
+
+// statusInterval - период [Start, End), в течение которого назначение было в статусе Status
+type statusInterval struct {
+	Status model.DriverMarkStatus
+	Start  time.Time
+	End    time.Time
+}
+
+// statusIntervals восстанавливает по журналу (в хронологическом порядке) периоды статусов назначения.
+// Последний период длится до until.
+func statusIntervals(events []model.DriverStatusEvent, until time.Time) []statusInterval {
+	var intervals []statusInterval
+	for i, event := range events {
+		end := until
+		if i+1 < len(events) {
+			end = events[i+1].CreatedAt
+		}
+		if end.After(event.CreatedAt) {
+			intervals = append(intervals, statusInterval{Status: event.ToStatus, Start: event.CreatedAt, End: end})
+		}
+	}
+	return intervals
+}
+
+// workingDuration - время смены в IN_WORK без пауз
+func workingDuration(events []model.DriverStatusEvent, until time.Time) time.Duration {
+	var total time.Duration
+	for _, interval := range statusIntervals(events, until) {
+		if interval.Status == model.DriverMarkStatusInWork {
+			total += interval.End.Sub(interval.Start)
+		}
+	}
+	return total
+}
+
+// pausedIntervals - периоды пауз смены
+func pausedIntervals(events []model.DriverStatusEvent, until time.Time) []statusInterval {
+	var paused []statusInterval
+	for _, interval := range statusIntervals(events, until) {
+		if interval.Status == model.DriverMarkStatusPaused {
+			paused = append(paused, interval)
+		}
+	}
+	return paused
+}
+
+// duringPause проверяет, что момент at попадает в одну из пауз
+func duringPause(paused []statusInterval, at time.Time) bool {
+	for _, interval := range paused {
+		if !at.Before(interval.Start) && at.Before(interval.End) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/config"
+	"ticket-service/internal/model"
+	"ticket-service/internal/plate"
+	"ticket-service/internal/repository"
+)
+
+// EventService принимает сырые события LPR и volume камер и собирает из них рейсы:
+// въезд LPR открывает рейс, выезд LPR закрывает последний открытый рейс с тем же номером,
+// volume события привязываются к ближайшему по времени въезду/выезду на той же камере/полигоне.
+type EventService struct {
+	eventRepo      *repository.EventRepository
+	tripRepo       *repository.TripRepository
+	assignmentRepo *repository.AssignmentRepository
+	tripService    *TripService
+	ticketService  *TicketService
+	cfg            config.PairingConfig
+	log            zerolog.Logger
+}
+
+func NewEventService(
+	eventRepo *repository.EventRepository,
+	tripRepo *repository.TripRepository,
+	assignmentRepo *repository.AssignmentRepository,
+	tripService *TripService,
+	ticketService *TicketService,
+	cfg config.PairingConfig,
+	log zerolog.Logger,
+) *EventService {
+	return &EventService{
+		eventRepo:      eventRepo,
+		tripRepo:       tripRepo,
+		assignmentRepo: assignmentRepo,
+		tripService:    tripService,
+		ticketService:  ticketService,
+		cfg:            cfg,
+		log:            log,
+	}
+}
+
+type IngestLprEventInput struct {
+	// ExternalID - идентификатор события в сервисе камер, повторная доставка с тем же ID не создает дубликатов
+	ExternalID  *string
+	CameraID    string
+	PolygonID   *string
+	PlateNumber string
+	DetectedAt  string
+	Direction   string
+	Confidence  *float64
+	PhotoURL    *string
+}
+
+type IngestVolumeEventInput struct {
+	ExternalID     *string
+	CameraID       string
+	PolygonID      *string
+	DetectedVolume float64
+	DetectedAt     string
+	Direction      string
+	PhotoURL       *string
+}
+
+// LprEventResult содержит сохраненное событие и рейс, который был открыт или закрыт этим событием
+type LprEventResult struct {
+	Event *model.LprEvent `json:"event"`
+	Trip  *model.Trip     `json:"trip"`
+}
+
+// VolumeEventResult содержит сохраненное событие и рейс, к которому был привязан объем
+type VolumeEventResult struct {
+	Event *model.VolumeEvent `json:"event"`
+	Trip  *model.Trip        `json:"trip"`
+}
+
+func (s *EventService) IngestLprEvent(ctx context.Context, input IngestLprEventInput) (*LprEventResult, error) {
+	if input.ExternalID != nil && *input.ExternalID != "" {
+		existing, err := s.eventRepo.FindLprEventByExternalID(ctx, *input.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return s.replayLprEvent(ctx, existing)
+		}
+	} else {
+		input.ExternalID = nil
+	}
+
+	cameraID, err := uuid.Parse(input.CameraID)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	polygonID, err := parseOptionalUUID(input.PolygonID)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	detectedAt, err := time.Parse(time.RFC3339, input.DetectedAt)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	direction, ok := normalizeDirection(input.Direction)
+	if !ok {
+		return nil, ErrInvalidInput
+	}
+
+	// Номер хранится в каноническом виде, чтобы въезд и выезд сопоставлялись несмотря на ошибки OCR
+	plateNumber, _ := plate.Canonical(input.PlateNumber)
+	if plateNumber == "" {
+		return nil, ErrInvalidInput
+	}
+
+	event := &model.LprEvent{
+		ExternalID:  input.ExternalID,
+		CameraID:    cameraID,
+		PolygonID:   polygonID,
+		PlateNumber: plateNumber,
+		DetectedAt:  detectedAt,
+		Direction:   &direction,
+		Confidence:  input.Confidence,
+		PhotoURL:    input.PhotoURL,
+	}
+
+	if err := s.eventRepo.CreateLprEvent(ctx, event); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) && input.ExternalID != nil {
+			existing, findErr := s.eventRepo.FindLprEventByExternalID(ctx, *input.ExternalID)
+			if findErr != nil {
+				return nil, findErr
+			}
+			if existing != nil {
+				return s.replayLprEvent(ctx, existing)
+			}
+		}
+		return nil, err
+	}
+
+	var trip *model.Trip
+	if direction == model.EventDirectionEntry {
+		trip, err = s.openTrip(ctx, event)
+	} else {
+		trip, err = s.closeTrip(ctx, event)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &LprEventResult{Event: event, Trip: trip}, nil
+}
+
+func (s *EventService) IngestVolumeEvent(ctx context.Context, input IngestVolumeEventInput) (*VolumeEventResult, error) {
+	if input.ExternalID != nil && *input.ExternalID != "" {
+		existing, err := s.eventRepo.FindVolumeEventByExternalID(ctx, *input.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return s.replayVolumeEvent(ctx, existing)
+		}
+	} else {
+		input.ExternalID = nil
+	}
+
+	cameraID, err := uuid.Parse(input.CameraID)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	polygonID, err := parseOptionalUUID(input.PolygonID)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	detectedAt, err := time.Parse(time.RFC3339, input.DetectedAt)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	direction, ok := normalizeDirection(input.Direction)
+	if !ok {
+		return nil, ErrInvalidInput
+	}
+
+	if input.DetectedVolume < 0 {
+		return nil, ErrInvalidInput
+	}
+
+	event := &model.VolumeEvent{
+		ExternalID:     input.ExternalID,
+		CameraID:       cameraID,
+		PolygonID:      polygonID,
+		DetectedVolume: input.DetectedVolume,
+		DetectedAt:     detectedAt,
+		Direction:      &direction,
+		PhotoURL:       input.PhotoURL,
+	}
+
+	if err := s.eventRepo.CreateVolumeEvent(ctx, event); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) && input.ExternalID != nil {
+			existing, findErr := s.eventRepo.FindVolumeEventByExternalID(ctx, *input.ExternalID)
+			if findErr != nil {
+				return nil, findErr
+			}
+			if existing != nil {
+				return s.replayVolumeEvent(ctx, existing)
+			}
+		}
+		return nil, err
+	}
+
+	// Volume событие могло прийти позже LPR - ищем рейс, который его ждет
+	trip, err := s.tripRepo.FindAwaitingVolume(ctx, direction, cameraID, polygonID, detectedAt, s.cfg.VolumeMatchWindow)
+	if err != nil {
+		return nil, err
+	}
+	if trip == nil {
+		return &VolumeEventResult{Event: event}, nil
+	}
+
+	volume := event.DetectedVolume
+	if direction == model.EventDirectionEntry {
+		trip.EntryVolumeEventID = &event.ID
+		trip.DetectedVolumeEntry = &volume
+	} else {
+		trip.ExitVolumeEventID = &event.ID
+		trip.DetectedVolumeExit = &volume
+	}
+
+	if err := s.tripService.Update(ctx, trip); err != nil {
+		return nil, err
+	}
+
+	s.log.Info().
+		Str("trip_id", trip.ID.String()).
+		Str("volume_event_id", event.ID.String()).
+		Str("direction", direction).
+		Float64("detected_volume", volume).
+		Msg("paired volume event with trip")
+
+	if direction == model.EventDirectionExit {
+		s.tryAutoComplete(ctx, trip)
+	}
+
+	return &VolumeEventResult{Event: event, Trip: trip}, nil
+}
+
+// replayLprEvent возвращает результат первичной обработки уже сохраненного LPR события
+func (s *EventService) replayLprEvent(ctx context.Context, event *model.LprEvent) (*LprEventResult, error) {
+	trip, err := s.tripRepo.FindByLprEventID(ctx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &LprEventResult{Event: event, Trip: trip}, nil
+}
+
+// replayVolumeEvent возвращает результат первичной обработки уже сохраненного volume события
+func (s *EventService) replayVolumeEvent(ctx context.Context, event *model.VolumeEvent) (*VolumeEventResult, error) {
+	trip, err := s.tripRepo.FindByVolumeEventID(ctx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &VolumeEventResult{Event: event, Trip: trip}, nil
+}
+
+// openTrip создает рейс по LPR событию въезда и привязывает к нему ближайшее volume событие въезда
+func (s *EventService) openTrip(ctx context.Context, event *model.LprEvent) (*model.Trip, error) {
+	volumeEvent, err := s.eventRepo.FindNearestFreeVolumeEvent(ctx, event.CameraID, event.PolygonID, model.EventDirectionEntry, event.DetectedAt, s.cfg.VolumeMatchWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	vehicleID, vehiclePlate, err := s.assignmentRepo.FindVehicleByPlate(ctx, event.PlateNumber, plate.Variants(event.PlateNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	cameraID := event.CameraID.String()
+	entryLprEventID := event.ID.String()
+	input := CreateTripInput{
+		CameraID:            &cameraID,
+		PolygonID:           uuidPtrString(event.PolygonID),
+		VehicleID:           uuidPtrString(vehicleID),
+		VehiclePlateNumber:  vehiclePlate,
+		DetectedPlateNumber: event.PlateNumber,
+		EntryLprEventID:     &entryLprEventID,
+		EntryAt:             event.DetectedAt.Format(time.RFC3339),
+		Status:              model.TripStatusOK,
+	}
+
+	if volumeEvent != nil {
+		entryVolumeEventID := volumeEvent.ID.String()
+		volume := volumeEvent.DetectedVolume
+		input.EntryVolumeEventID = &entryVolumeEventID
+		input.DetectedVolumeEntry = &volume
+	}
+
+	trip, err := s.tripService.Create(ctx, input)
+	if err != nil {
+		if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+			// Событие сохранено, но рейс создать нельзя (например, тикет уже закрыт)
+			s.log.Warn().
+				Err(err).
+				Str("lpr_event_id", event.ID.String()).
+				Str("plate", event.PlateNumber).
+				Msg("entry event stored without trip")
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	s.log.Info().
+		Str("trip_id", trip.ID.String()).
+		Str("lpr_event_id", event.ID.String()).
+		Str("plate", event.PlateNumber).
+		Bool("volume_paired", volumeEvent != nil).
+		Msg("opened trip from entry event")
+
+	return trip, nil
+}
+
+// closeTrip закрывает последний открытый рейс с тем же номером по LPR событию выезда
+func (s *EventService) closeTrip(ctx context.Context, event *model.LprEvent) (*model.Trip, error) {
+	trip, err := s.tripRepo.FindOpenByPlate(ctx, event.PlateNumber, event.CameraID, event.PolygonID, event.DetectedAt.Add(-s.cfg.MaxTripDuration), event.DetectedAt)
+	if err != nil {
+		return nil, err
+	}
+	if trip == nil {
+		s.log.Warn().
+			Str("lpr_event_id", event.ID.String()).
+			Str("plate", event.PlateNumber).
+			Msg("exit event has no matching open trip")
+		return nil, nil
+	}
+
+	exitAt := event.DetectedAt
+	trip.ExitLprEventID = &event.ID
+	trip.ExitAt = &exitAt
+
+	volumeEvent, err := s.eventRepo.FindNearestFreeVolumeEvent(ctx, event.CameraID, event.PolygonID, model.EventDirectionExit, event.DetectedAt, s.cfg.VolumeMatchWindow)
+	if err != nil {
+		return nil, err
+	}
+	if volumeEvent != nil {
+		volume := volumeEvent.DetectedVolume
+		trip.ExitVolumeEventID = &volumeEvent.ID
+		trip.DetectedVolumeExit = &volume
+	}
+
+	if err := s.tripService.Update(ctx, trip); err != nil {
+		return nil, err
+	}
+
+	s.log.Info().
+		Str("trip_id", trip.ID.String()).
+		Str("lpr_event_id", event.ID.String()).
+		Str("plate", event.PlateNumber).
+		Bool("volume_paired", volumeEvent != nil).
+		Msg("closed trip from exit event")
+
+	s.tryAutoComplete(ctx, trip)
+
+	return trip, nil
+}
+
+// tryAutoComplete пробует перевести тикет в COMPLETED после закрытия рейса (best-effort)
+func (s *EventService) tryAutoComplete(ctx context.Context, trip *model.Trip) {
+	if trip.TicketID == nil || s.ticketService == nil {
+		return
+	}
+	if err := s.ticketService.TryAutoComplete(ctx, *trip.TicketID); err != nil {
+		s.log.Warn().
+			Err(err).
+			Str("ticket_id", trip.TicketID.String()).
+			Str("trip_id", trip.ID.String()).
+			Msg("failed to auto-complete ticket after trip pairing")
+	}
+}
+
+func normalizeDirection(raw string) (string, bool) {
+	direction := strings.ToLower(strings.TrimSpace(raw))
+	switch direction {
+	case model.EventDirectionEntry, model.EventDirectionExit:
+		return direction, true
+	default:
+		return "", false
+	}
+}
+
+func parseOptionalUUID(raw *string) (*uuid.UUID, error) {
+	if raw == nil || *raw == "" {
+		return nil, nil
+	}
+	parsed, err := uuid.Parse(*raw)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func uuidPtrString(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	value := id.String()
+	return &value
+}
@@ -0,0 +1,670 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/config"
+	"ticket-service/internal/model"
+	"ticket-service/internal/recurrence"
+	"ticket-service/internal/repository"
+)
+
+const dateLayout = "2006-01-02"
+
+// ScheduleService управляет расписаниями повторяющихся тикетов и создает тикеты серий заранее
+type ScheduleService struct {
+	scheduleRepo   *repository.ScheduleRepository
+	holidayRepo    *repository.HolidayRepository
+	ticketRepo     *repository.TicketRepository
+	areaAccessRepo *repository.CleaningAreaAccessRepository
+	cfg            config.SchedulerConfig
+	log            zerolog.Logger
+}
+
+func NewScheduleService(
+	scheduleRepo *repository.ScheduleRepository,
+	holidayRepo *repository.HolidayRepository,
+	ticketRepo *repository.TicketRepository,
+	areaAccessRepo *repository.CleaningAreaAccessRepository,
+	cfg config.SchedulerConfig,
+	log zerolog.Logger,
+) *ScheduleService {
+	return &ScheduleService{
+		scheduleRepo:   scheduleRepo,
+		holidayRepo:    holidayRepo,
+		ticketRepo:     ticketRepo,
+		areaAccessRepo: areaAccessRepo,
+		cfg:            cfg,
+		log:            log,
+	}
+}
+
+type CreateScheduleInput struct {
+	CleaningAreaID string
+	ContractorID   string
+	ContractID     string
+	// RRule - правило повторения, например "FREQ=DAILY" или "FREQ=WEEKLY;BYDAY=MO,WE,FR"
+	RRule string
+	// Timezone - IANA часовой пояс окна работ, пустой - SCHEDULER_DEFAULT_TIMEZONE
+	Timezone string
+	// StartTime/EndTime - окно работ "HH:MM"; EndTime не позже StartTime - окончание на следующий день
+	StartTime string
+	EndTime   string
+	// StartsOn/EndsOn - первая и последняя дата серии (YYYY-MM-DD), EndsOn необязательна
+	StartsOn     string
+	EndsOn       string
+	SkipHolidays *bool
+	Description  string
+}
+
+func (s *ScheduleService) Create(ctx context.Context, principal model.Principal, input CreateScheduleInput) (*model.TicketSchedule, error) {
+	// Только KGU ZKH создает расписания, как и тикеты
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+
+	schedule := &model.TicketSchedule{
+		CreatedByOrgID: principal.OrgID,
+		Timezone:       input.Timezone,
+		StartTime:      strings.TrimSpace(input.StartTime),
+		EndTime:        strings.TrimSpace(input.EndTime),
+		SkipHolidays:   true,
+		Description:    input.Description,
+		Status:         model.TicketScheduleStatusActive,
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = s.cfg.DefaultTimezone
+	}
+	if input.SkipHolidays != nil {
+		schedule.SkipHolidays = *input.SkipHolidays
+	}
+
+	var err error
+	if schedule.CleaningAreaID, err = uuid.Parse(input.CleaningAreaID); err != nil {
+		return nil, ErrInvalidInput
+	}
+	if schedule.ContractorID, err = uuid.Parse(input.ContractorID); err != nil {
+		return nil, ErrInvalidInput
+	}
+	if schedule.ContractID, err = uuid.Parse(input.ContractID); err != nil {
+		return nil, ErrInvalidInput
+	}
+	if schedule.StartsOn, err = time.Parse(dateLayout, input.StartsOn); err != nil {
+		return nil, ErrInvalidInput
+	}
+	if input.EndsOn != "" {
+		endsOn, err := time.Parse(dateLayout, input.EndsOn)
+		if err != nil {
+			return nil, ErrInvalidInput
+		}
+		schedule.EndsOn = &endsOn
+	}
+	if schedule.RRule, err = normalizeRRule(input.RRule); err != nil {
+		return nil, err
+	}
+	if err := validateSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.scheduleRepo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	s.grantAreaAccess(ctx, schedule)
+	s.materializeNow(ctx, schedule)
+
+	return schedule, nil
+}
+
+func (s *ScheduleService) Get(ctx context.Context, principal model.Principal, id string) (*model.TicketSchedule, error) {
+	return s.getOwned(ctx, principal, id)
+}
+
+func (s *ScheduleService) List(ctx context.Context, principal model.Principal, status string) ([]model.TicketSchedule, error) {
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+
+	var filter *model.TicketScheduleStatus
+	if status != "" {
+		st := model.TicketScheduleStatus(strings.ToUpper(status))
+		switch st {
+		case model.TicketScheduleStatusActive, model.TicketScheduleStatusPaused, model.TicketScheduleStatusEnded:
+		default:
+			return nil, ErrInvalidInput
+		}
+		filter = &st
+	}
+
+	return s.scheduleRepo.ListByOrg(ctx, principal.OrgID, filter)
+}
+
+// UpdateScheduleInput - частичное изменение расписания: nil - поле не меняется.
+// Пустая строка в EndsOn снимает дату окончания.
+type UpdateScheduleInput struct {
+	CleaningAreaID *string
+	ContractorID   *string
+	ContractID     *string
+	RRule          *string
+	Timezone       *string
+	StartTime      *string
+	EndTime        *string
+	StartsOn       *string
+	EndsOn         *string
+	SkipHolidays   *bool
+	Description    *string
+}
+
+// Update меняет правила серии. Будущие тикеты серии, по которым еще не начаты работы
+// (PLANNED без назначений), пересоздаются по новым правилам; остальные не меняются.
+func (s *ScheduleService) Update(ctx context.Context, principal model.Principal, id string, input UpdateScheduleInput) (*model.TicketSchedule, error) {
+	schedule, err := s.getOwned(ctx, principal, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status == model.TicketScheduleStatusEnded {
+		return nil, ErrConflict
+	}
+
+	before := *schedule
+
+	parseUUID := func(value *string, target *uuid.UUID) error {
+		if value == nil {
+			return nil
+		}
+		parsed, err := uuid.Parse(strings.TrimSpace(*value))
+		if err != nil {
+			return ErrInvalidInput
+		}
+		*target = parsed
+		return nil
+	}
+	if err := parseUUID(input.CleaningAreaID, &schedule.CleaningAreaID); err != nil {
+		return nil, err
+	}
+	if err := parseUUID(input.ContractorID, &schedule.ContractorID); err != nil {
+		return nil, err
+	}
+	if err := parseUUID(input.ContractID, &schedule.ContractID); err != nil {
+		return nil, err
+	}
+	if input.RRule != nil {
+		if schedule.RRule, err = normalizeRRule(*input.RRule); err != nil {
+			return nil, err
+		}
+	}
+	if input.Timezone != nil {
+		schedule.Timezone = strings.TrimSpace(*input.Timezone)
+	}
+	if input.StartTime != nil {
+		schedule.StartTime = strings.TrimSpace(*input.StartTime)
+	}
+	if input.EndTime != nil {
+		schedule.EndTime = strings.TrimSpace(*input.EndTime)
+	}
+	if input.StartsOn != nil {
+		if schedule.StartsOn, err = time.Parse(dateLayout, *input.StartsOn); err != nil {
+			return nil, ErrInvalidInput
+		}
+	}
+	if input.EndsOn != nil {
+		schedule.EndsOn = nil
+		if *input.EndsOn != "" {
+			endsOn, err := time.Parse(dateLayout, *input.EndsOn)
+			if err != nil {
+				return nil, ErrInvalidInput
+			}
+			schedule.EndsOn = &endsOn
+		}
+	}
+	if input.SkipHolidays != nil {
+		schedule.SkipHolidays = *input.SkipHolidays
+	}
+	if input.Description != nil {
+		schedule.Description = *input.Description
+	}
+
+	if err := validateSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.saveAndReset(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	if schedule.ContractorID != before.ContractorID || schedule.CleaningAreaID != before.CleaningAreaID {
+		s.grantAreaAccess(ctx, schedule)
+	}
+	s.materializeNow(ctx, schedule)
+
+	return schedule, nil
+}
+
+// Pause приостанавливает серию: новые тикеты не создаются, будущие тикеты без начатых работ удаляются
+func (s *ScheduleService) Pause(ctx context.Context, principal model.Principal, id string) (*model.TicketSchedule, error) {
+	return s.changeStatus(ctx, principal, id, model.TicketScheduleStatusActive, model.TicketScheduleStatusPaused)
+}
+
+// Resume возобновляет приостановленную серию с текущего момента
+func (s *ScheduleService) Resume(ctx context.Context, principal model.Principal, id string) (*model.TicketSchedule, error) {
+	schedule, err := s.changeStatus(ctx, principal, id, model.TicketScheduleStatusPaused, model.TicketScheduleStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	s.materializeNow(ctx, schedule)
+	return schedule, nil
+}
+
+// End окончательно завершает серию. Будущие тикеты без начатых работ удаляются,
+// уже идущие работы доводятся до конца как обычные тикеты.
+func (s *ScheduleService) End(ctx context.Context, principal model.Principal, id string) (*model.TicketSchedule, error) {
+	schedule, err := s.getOwned(ctx, principal, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status == model.TicketScheduleStatusEnded {
+		return nil, ErrConflict
+	}
+
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if schedule.EndsOn == nil || schedule.EndsOn.After(today) {
+		schedule.EndsOn = &today
+	}
+	schedule.Status = model.TicketScheduleStatusEnded
+
+	if err := s.saveAndReset(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *ScheduleService) changeStatus(ctx context.Context, principal model.Principal, id string, from, to model.TicketScheduleStatus) (*model.TicketSchedule, error) {
+	schedule, err := s.getOwned(ctx, principal, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != from {
+		return nil, ErrConflict
+	}
+
+	schedule.Status = to
+	if err := s.saveAndReset(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *ScheduleService) saveAndReset(ctx context.Context, schedule *model.TicketSchedule) error {
+	removed, err := s.scheduleRepo.SaveAndReset(ctx, schedule, time.Now())
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		s.log.Info().
+			Str("schedule_id", schedule.ID.String()).
+			Str("status", string(schedule.Status)).
+			Int64("removed_tickets", removed).
+			Msg("removed not started tickets of changed schedule")
+	}
+	return nil
+}
+
+func (s *ScheduleService) getOwned(ctx context.Context, principal model.Principal, id string) (*model.TicketSchedule, error) {
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+
+	schedule, err := s.scheduleRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if schedule.CreatedByOrgID != principal.OrgID {
+		return nil, ErrPermissionDenied
+	}
+	return schedule, nil
+}
+
+// grantAreaAccess выдает подрядчику доступ к участку, как при создании тикета (best-effort)
+func (s *ScheduleService) grantAreaAccess(ctx context.Context, schedule *model.TicketSchedule) {
+	if err := s.areaAccessRepo.Grant(ctx, schedule.CleaningAreaID, schedule.ContractorID, "TICKET"); err != nil {
+		s.log.Warn().
+			Err(err).
+			Str("cleaning_area_id", schedule.CleaningAreaID.String()).
+			Str("contractor_id", schedule.ContractorID.String()).
+			Str("schedule_id", schedule.ID.String()).
+			Msg("failed to grant cleaning area access for contractor (schedule saved successfully)")
+	}
+}
+
+// materializeNow сразу создает ближайшие тикеты серии, не дожидаясь планировщика.
+// Ошибка не критична: планировщик повторит на следующем проходе.
+func (s *ScheduleService) materializeNow(ctx context.Context, schedule *model.TicketSchedule) {
+	if schedule.Status != model.TicketScheduleStatusActive {
+		return
+	}
+	if _, err := s.materialize(ctx, schedule, time.Now()); err != nil {
+		s.log.Warn().
+			Err(err).
+			Str("schedule_id", schedule.ID.String()).
+			Msg("failed to materialize schedule, will retry on next scheduler pass")
+	}
+}
+
+// MaterializeStats - итоги прохода планировщика
+type MaterializeStats struct {
+	Schedules int
+	Created   int
+	Failed    int
+}
+
+// MaterializeSchedules создает тикеты всех активных серий на SCHEDULER_HORIZON вперед от now.
+// Повторный вызов не создает дубликатов: дата серии уникальна (uq_tickets_schedule_date),
+// materialized_until отмечает уже обработанный период, а даты удаленных вручную тикетов
+// хранятся в ticket_schedule_exceptions и не пересоздаются, в том числе после изменения серии.
+func (s *ScheduleService) MaterializeSchedules(ctx context.Context, now time.Time) (MaterializeStats, error) {
+	var stats MaterializeStats
+
+	schedules, err := s.scheduleRepo.ListActive(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		stats.Schedules++
+
+		created, err := s.materialize(ctx, schedule, now)
+		stats.Created += created
+		if err != nil {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			stats.Failed++
+			s.log.Warn().Err(err).Str("schedule_id", schedule.ID.String()).Msg("failed to materialize schedule")
+		}
+	}
+
+	return stats, nil
+}
+
+func (s *ScheduleService) materialize(ctx context.Context, schedule *model.TicketSchedule, now time.Time) (int, error) {
+	rule, err := recurrence.Parse(schedule.RRule)
+	if err != nil {
+		return 0, err
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return 0, err
+	}
+	startClock, endClock, err := parseScheduleWindow(schedule.StartTime, schedule.EndTime)
+	if err != nil {
+		return 0, err
+	}
+
+	horizonEnd := now.Add(s.cfg.Horizon)
+
+	// Пропускаемые даты серии: нерабочие дни организации и тикеты, удаленные вручную.
+	// Окно работ короче суток, поэтому достаточно захватить вчерашний день.
+	skip := make(map[string]bool)
+	from, to := now.In(loc).AddDate(0, 0, -1), horizonEnd.In(loc)
+	if schedule.SkipHolidays {
+		list, err := s.holidayRepo.List(ctx, schedule.CreatedByOrgID, from, to)
+		if err != nil {
+			return 0, err
+		}
+		for _, holiday := range list {
+			skip[holiday.Date.Format(dateLayout)] = true
+		}
+	}
+	exceptions, err := s.scheduleRepo.ListExceptions(ctx, schedule.ID, from, to)
+	if err != nil {
+		return 0, err
+	}
+	for _, exception := range exceptions {
+		skip[exception.Date.Format(dateLayout)] = true
+	}
+
+	created := 0
+	for _, window := range scheduleWindows(schedule, rule, loc, startClock, endClock, now, horizonEnd, skip) {
+		ok, err := s.createOccurrence(ctx, schedule, window.Date, window.Start, window.End)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created++
+		}
+	}
+
+	if err := s.scheduleRepo.SetMaterializedUntil(ctx, schedule.ID, horizonEnd); err != nil {
+		return created, err
+	}
+	schedule.MaterializedUntil = &horizonEnd
+
+	if created > 0 {
+		s.log.Info().
+			Str("schedule_id", schedule.ID.String()).
+			Int("created", created).
+			Msg("materialized scheduled tickets")
+	}
+	return created, nil
+}
+
+// scheduleWindow - окно работ одной даты серии
+type scheduleWindow struct {
+	Date  time.Time
+	Start time.Time
+	End   time.Time
+}
+
+// scheduleWindows возвращает окна серии, которые нужно создать: начало позже materialized_until
+// и не позже horizonEnd, окно не закончилось к now, дата серии не входит в skip
+func scheduleWindows(schedule *model.TicketSchedule, rule recurrence.Rule, loc *time.Location, startClock, endClock time.Duration, now, horizonEnd time.Time, skip map[string]bool) []scheduleWindow {
+	startsOn := time.Date(schedule.StartsOn.Year(), schedule.StartsOn.Month(), schedule.StartsOn.Day(), 0, 0, 0, 0, loc)
+
+	var windows []scheduleWindow
+	for date := range rule.Dates(startsOn) {
+		key := date.Format(dateLayout)
+		if schedule.EndsOn != nil && key > schedule.EndsOn.Format(dateLayout) {
+			break
+		}
+
+		start := atClock(date, startClock)
+		end := atClock(date, endClock)
+		if !end.After(start) {
+			end = atClock(date.AddDate(0, 0, 1), endClock)
+		}
+
+		if start.After(horizonEnd) {
+			break
+		}
+		if schedule.MaterializedUntil != nil && !start.After(*schedule.MaterializedUntil) {
+			continue
+		}
+		// Окно, которое уже закончилось, не создаем (например, после долгого простоя сервиса)
+		if !end.After(now) {
+			continue
+		}
+		if skip[key] {
+			continue
+		}
+
+		windows = append(windows, scheduleWindow{Date: date, Start: start, End: end})
+	}
+	return windows
+}
+
+func (s *ScheduleService) createOccurrence(ctx context.Context, schedule *model.TicketSchedule, date, start, end time.Time) (bool, error) {
+	scheduleID := schedule.ID
+	// Дата серии хранится как календарная дата (DATE), без сдвига часового пояса
+	scheduleDate := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	ticket := &model.Ticket{
+		CleaningAreaID: schedule.CleaningAreaID,
+		ContractorID:   schedule.ContractorID,
+		ContractID:     schedule.ContractID,
+		CreatedByOrgID: schedule.CreatedByOrgID,
+		Status:         model.TicketStatusPlanned,
+		PlannedStartAt: start,
+		PlannedEndAt:   end,
+		Description:    schedule.Description,
+		ScheduleID:     &scheduleID,
+		ScheduleDate:   &scheduleDate,
+	}
+
+	entry := newTicketStatusHistory(ticket, nil, model.TicketEventCreate, nil, "created by schedule")
+	created, err := s.ticketRepo.CreateScheduled(ctx, ticket, entry)
+	if err != nil || created {
+		return created, err
+	}
+
+	// Тикет не создан: либо дата серии уже материализована, либо окно пересекается с активным
+	// тикетом участка. Второе требует внимания KGU - дата серии пропускается.
+	overlapping, err := s.ticketRepo.FindOverlapping(ctx, ticket.CleaningAreaID, start, end, uuid.Nil)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range overlapping {
+		if other.ScheduleID != nil && *other.ScheduleID == schedule.ID &&
+			other.ScheduleDate != nil && other.ScheduleDate.Equal(scheduleDate) {
+			// Тикет на эту дату серии уже есть
+			return false, nil
+		}
+	}
+	if len(overlapping) > 0 {
+		s.log.Warn().
+			Str("schedule_id", schedule.ID.String()).
+			Str("schedule_date", scheduleDate.Format(dateLayout)).
+			Str("overlapping_ticket_id", overlapping[0].ID.String()).
+			Msg("scheduled ticket skipped: overlaps active ticket on the same cleaning area")
+	}
+	return false, nil
+}
+
+// atClock - время суток clock в дату date (в ее часовом поясе, с учетом перехода на летнее время)
+func atClock(date time.Time, clock time.Duration) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(),
+		int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, date.Location())
+}
+
+func normalizeRRule(value string) (string, error) {
+	rule, err := recurrence.Parse(value)
+	if err != nil {
+		return "", ErrInvalidInput
+	}
+	return rule.String(), nil
+}
+
+func validateSchedule(schedule *model.TicketSchedule) error {
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil || schedule.Timezone == "" {
+		return ErrInvalidInput
+	}
+	if _, _, err := parseScheduleWindow(schedule.StartTime, schedule.EndTime); err != nil {
+		return ErrInvalidInput
+	}
+	if schedule.EndsOn != nil && schedule.EndsOn.Before(schedule.StartsOn) {
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+// parseScheduleWindow возвращает начало и конец окна как смещение от полуночи
+func parseScheduleWindow(startTime, endTime string) (time.Duration, time.Duration, error) {
+	start, err := time.Parse("15:04", startTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := time.Parse("15:04", endTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	clock := func(t time.Time) time.Duration {
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return clock(start), clock(end), nil
+}
+
+// ListHolidays возвращает нерабочие дни организации в диапазоне дат (YYYY-MM-DD, включительно).
+// По умолчанию - текущий год.
+func (s *ScheduleService) ListHolidays(ctx context.Context, principal model.Principal, from, to string) ([]model.Holiday, error) {
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+
+	now := time.Now()
+	fromDate := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(now.Year(), 12, 31, 0, 0, 0, 0, time.UTC)
+	var err error
+	if from != "" {
+		if fromDate, err = time.Parse(dateLayout, from); err != nil {
+			return nil, ErrInvalidInput
+		}
+	}
+	if to != "" {
+		if toDate, err = time.Parse(dateLayout, to); err != nil {
+			return nil, ErrInvalidInput
+		}
+	}
+	if toDate.Before(fromDate) {
+		return nil, ErrInvalidInput
+	}
+
+	return s.holidayRepo.List(ctx, principal.OrgID, fromDate, toDate)
+}
+
+// AddHoliday добавляет нерабочий день организации. Уже созданные тикеты на эту дату не удаляются.
+func (s *ScheduleService) AddHoliday(ctx context.Context, principal model.Principal, date, name string) (*model.Holiday, error) {
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+
+	parsed, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	holiday := &model.Holiday{
+		OrgID: principal.OrgID,
+		Date:  parsed,
+		Name:  strings.TrimSpace(name),
+	}
+	if err := s.holidayRepo.Upsert(ctx, holiday); err != nil {
+		return nil, err
+	}
+	return holiday, nil
+}
+
+func (s *ScheduleService) DeleteHoliday(ctx context.Context, principal model.Principal, date string) error {
+	if !principal.IsKgu() {
+		return ErrPermissionDenied
+	}
+
+	parsed, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return ErrInvalidInput
+	}
+
+	deleted, err := s.holidayRepo.Delete(ctx, principal.OrgID, parsed)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
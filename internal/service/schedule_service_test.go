@@ -0,0 +1,213 @@
+package service
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/recurrence"
+)
+
+func TestScheduleWindows(t *testing.T) {
+	almaty, err := time.LoadLocation("Asia/Almaty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	at := func(loc *time.Location, month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, loc)
+	}
+
+	daily := model.TicketSchedule{
+		RRule:     "FREQ=DAILY",
+		Timezone:  "Asia/Almaty",
+		StartTime: "08:00",
+		EndTime:   "16:00",
+		StartsOn:  date(2025, 1, 13),
+	}
+	now := at(almaty, 1, 14, 9)
+
+	tests := []struct {
+		name     string
+		schedule func(s *model.TicketSchedule)
+		now      time.Time
+		horizon  time.Duration
+		skip     []string
+		want     [][2]time.Time
+	}{
+		{
+			// 13-го окно уже закончилось, 14-го идет, 17-го начинается до горизонта
+			name:    "finished windows are not created",
+			now:     now,
+			horizon: 72 * time.Hour,
+			want: [][2]time.Time{
+				{at(almaty, 1, 14, 8), at(almaty, 1, 14, 16)},
+				{at(almaty, 1, 15, 8), at(almaty, 1, 15, 16)},
+				{at(almaty, 1, 16, 8), at(almaty, 1, 16, 16)},
+				{at(almaty, 1, 17, 8), at(almaty, 1, 17, 16)},
+			},
+		},
+		{
+			name:    "holidays and deleted dates are skipped",
+			now:     now,
+			horizon: 72 * time.Hour,
+			skip:    []string{"2025-01-15", "2025-01-16"},
+			want: [][2]time.Time{
+				{at(almaty, 1, 14, 8), at(almaty, 1, 14, 16)},
+				{at(almaty, 1, 17, 8), at(almaty, 1, 17, 16)},
+			},
+		},
+		{
+			name: "materialized period is not created again",
+			schedule: func(s *model.TicketSchedule) {
+				until := at(almaty, 1, 15, 8)
+				s.MaterializedUntil = &until
+			},
+			now:     now,
+			horizon: 72 * time.Hour,
+			want: [][2]time.Time{
+				{at(almaty, 1, 16, 8), at(almaty, 1, 16, 16)},
+				{at(almaty, 1, 17, 8), at(almaty, 1, 17, 16)},
+			},
+		},
+		{
+			name: "ends on",
+			schedule: func(s *model.TicketSchedule) {
+				endsOn := date(2025, 1, 15)
+				s.EndsOn = &endsOn
+			},
+			now:     now,
+			horizon: 72 * time.Hour,
+			want: [][2]time.Time{
+				{at(almaty, 1, 14, 8), at(almaty, 1, 14, 16)},
+				{at(almaty, 1, 15, 8), at(almaty, 1, 15, 16)},
+			},
+		},
+		{
+			name: "weekly series",
+			schedule: func(s *model.TicketSchedule) {
+				s.RRule = "FREQ=WEEKLY;BYDAY=MO,TH"
+			},
+			now:     now,
+			horizon: 7 * 24 * time.Hour,
+			want: [][2]time.Time{
+				{at(almaty, 1, 16, 8), at(almaty, 1, 16, 16)},
+				{at(almaty, 1, 20, 8), at(almaty, 1, 20, 16)},
+			},
+		},
+		{
+			name: "night window ends next day",
+			schedule: func(s *model.TicketSchedule) {
+				s.StartTime, s.EndTime = "22:00", "06:00"
+			},
+			now:     now,
+			horizon: 72 * time.Hour,
+			want: [][2]time.Time{
+				{at(almaty, 1, 14, 22), at(almaty, 1, 15, 6)},
+				{at(almaty, 1, 15, 22), at(almaty, 1, 16, 6)},
+				{at(almaty, 1, 16, 22), at(almaty, 1, 17, 6)},
+			},
+		},
+		{
+			// Переход на летнее время 9 марта в 02:00: ночное окно по часам то же, но короче на час
+			name: "night window across spring DST change",
+			schedule: func(s *model.TicketSchedule) {
+				s.Timezone = "America/New_York"
+				s.StartTime, s.EndTime = "22:00", "06:00"
+				s.StartsOn = date(2025, 3, 8)
+			},
+			now:     at(newYork, 3, 8, 12),
+			horizon: 48 * time.Hour,
+			want: [][2]time.Time{
+				{at(newYork, 3, 8, 22), at(newYork, 3, 9, 6)},
+				{at(newYork, 3, 9, 22), at(newYork, 3, 10, 6)},
+			},
+		},
+		{
+			// Возврат на зимнее время 2 ноября в 02:00: окно длиннее на час
+			name: "night window across autumn DST change",
+			schedule: func(s *model.TicketSchedule) {
+				s.Timezone = "America/New_York"
+				s.StartTime, s.EndTime = "22:00", "06:00"
+				s.StartsOn = date(2025, 11, 1)
+			},
+			now:     at(newYork, 11, 1, 12),
+			horizon: 24 * time.Hour,
+			want: [][2]time.Time{
+				{at(newYork, 11, 1, 22), at(newYork, 11, 2, 6)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := daily
+			if tt.schedule != nil {
+				tt.schedule(&schedule)
+			}
+			rule, err := recurrence.Parse(schedule.RRule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loc, err := time.LoadLocation(schedule.Timezone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			startClock, endClock, err := parseScheduleWindow(schedule.StartTime, schedule.EndTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			skip := make(map[string]bool)
+			for _, key := range tt.skip {
+				skip[key] = true
+			}
+
+			windows := scheduleWindows(&schedule, rule, loc, startClock, endClock, tt.now, tt.now.Add(tt.horizon), skip)
+			if len(windows) != len(tt.want) {
+				t.Fatalf("got %d windows %v, want %d", len(windows), windows, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !windows[i].Start.Equal(want[0]) || !windows[i].End.Equal(want[1]) {
+					t.Errorf("window %d: got %v - %v, want %v - %v", i, windows[i].Start, windows[i].End, want[0], want[1])
+				}
+			}
+		})
+	}
+}
+
+func TestAtClockDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		date time.Time
+		want time.Duration
+	}{
+		{name: "standard time", date: time.Date(2025, 1, 15, 0, 0, 0, 0, newYork), want: 5 * time.Hour},
+		{name: "daylight saving time", date: time.Date(2025, 7, 15, 0, 0, 0, 0, newYork), want: 4 * time.Hour},
+		{name: "day of spring change", date: time.Date(2025, 3, 9, 0, 0, 0, 0, newYork), want: 4 * time.Hour},
+		{name: "day of autumn change", date: time.Date(2025, 11, 2, 0, 0, 0, 0, newYork), want: 5 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 08:00 по местным часам в любой день, смещение от UTC зависит от даты
+			got := atClock(tt.date, 8*time.Hour)
+			if got.Hour() != 8 || got.Minute() != 0 {
+				t.Errorf("got local time %v, want 08:00", got)
+			}
+			want := time.Date(tt.date.Year(), tt.date.Month(), tt.date.Day(), 8, 0, 0, 0, time.UTC).Add(tt.want)
+			if !got.Equal(want) {
+				t.Errorf("got %v, want %v", got.UTC(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+// MaxTicketImportRows ограничивает количество строк в одном файле импорта
+const MaxTicketImportRows = 1000
+
+const (
+	TicketImportRowValid   = "valid"
+	TicketImportRowCreated = "created"
+	TicketImportRowInvalid = "invalid"
+)
+
+// ImportTicketRowInput - строка файла импорта; Row - номер строки в файле для отчета
+type ImportTicketRowInput struct {
+	Row int
+	CreateTicketInput
+}
+
+type ImportTicketRowResult struct {
+	Row      int        `json:"row"`
+	Status   string     `json:"status"`
+	Errors   []string   `json:"errors,omitempty"`
+	TicketID *uuid.UUID `json:"ticket_id,omitempty"`
+}
+
+type ImportTicketsResult struct {
+	DryRun  bool                    `json:"dry_run"`
+	Total   int                     `json:"total"`
+	Valid   int                     `json:"valid"`
+	Invalid int                     `json:"invalid"`
+	Created int                     `json:"created"`
+	Rows    []ImportTicketRowResult `json:"rows"`
+}
+
+// ImportTickets проверяет каждую строку по тем же правилам, что и Create (включая пересечения
+// с активными тикетами участка и с другими строками файла), и создает все корректные
+// тикеты одной транзакцией вместе с доступом подрядчиков к участкам. Строки с ошибками попадают
+// в отчет и не создаются. dryRun - только проверка, без записи.
+func (s *TicketService) ImportTickets(ctx context.Context, principal model.Principal, rows []ImportTicketRowInput, dryRun bool) (*ImportTicketsResult, error) {
+	// Только KGU ZKH может создавать тикеты
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: file has no data rows", ErrInvalidInput)
+	}
+	if len(rows) > MaxTicketImportRows {
+		return nil, fmt.Errorf("%w: too many rows (max %d)", ErrInvalidInput, MaxTicketImportRows)
+	}
+
+	result := &ImportTicketsResult{
+		DryRun: dryRun,
+		Total:  len(rows),
+		Rows:   make([]ImportTicketRowResult, 0, len(rows)),
+	}
+
+	type ticketKey struct {
+		areaID, contractorID uuid.UUID
+		start, end           int64
+	}
+	seen := make(map[ticketKey]int)
+
+	// Корректные строки файла без allow_overlap по участкам - для проверки пересечений внутри файла
+	type plannedRow struct {
+		row    int
+		ticket *model.Ticket
+	}
+	planned := make(map[uuid.UUID][]plannedRow)
+
+	var tickets []*model.Ticket
+	var entries []*model.TicketStatusHistory
+	var ticketRows []int
+
+	for _, row := range rows {
+		item := ImportTicketRowResult{Row: row.Row, Status: TicketImportRowValid}
+
+		ticket, problems := newTicketFromInput(principal, row.CreateTicketInput)
+		if ticket != nil {
+			// Одна и та же строка дважды в файле - скорее всего ошибка копирования
+			key := ticketKey{ticket.CleaningAreaID, ticket.ContractorID, ticket.PlannedStartAt.Unix(), ticket.PlannedEndAt.Unix()}
+			if first, ok := seen[key]; ok {
+				problems = append(problems, fmt.Sprintf("duplicate of row %d", first))
+			} else {
+				seen[key] = row.Row
+			}
+		}
+		if ticket != nil && len(problems) == 0 && !ticket.AllowOverlap {
+			for _, other := range planned[ticket.CleaningAreaID] {
+				if ticket.PlannedStartAt.Before(other.ticket.PlannedEndAt) && other.ticket.PlannedStartAt.Before(ticket.PlannedEndAt) {
+					problems = append(problems, fmt.Sprintf("overlaps row %d on the same cleaning area", other.row))
+				}
+			}
+			overlapping, err := s.ticketRepo.FindOverlapping(ctx, ticket.CleaningAreaID, ticket.PlannedStartAt, ticket.PlannedEndAt, uuid.Nil)
+			if err != nil {
+				return nil, err
+			}
+			for _, other := range overlapping {
+				problems = append(problems, fmt.Sprintf("overlaps ticket %s on the same cleaning area", other.ID))
+			}
+			if len(problems) == 0 {
+				planned[ticket.CleaningAreaID] = append(planned[ticket.CleaningAreaID], plannedRow{row.Row, ticket})
+			}
+		}
+
+		if len(problems) > 0 {
+			item.Status = TicketImportRowInvalid
+			item.Errors = problems
+			result.Invalid++
+		} else {
+			result.Valid++
+			tickets = append(tickets, ticket)
+			entries = append(entries, newTicketStatusHistory(ticket, nil, model.TicketEventCreate, &principal, "import"))
+			ticketRows = append(ticketRows, len(result.Rows))
+		}
+		result.Rows = append(result.Rows, item)
+	}
+
+	if dryRun || len(tickets) == 0 {
+		return result, nil
+	}
+
+	if err := s.ticketRepo.CreateBatch(ctx, tickets, entries); err != nil {
+		if errors.Is(err, repository.ErrTicketOverlap) {
+			// Пересекающийся тикет создан параллельно после проверки - повторный dry-run покажет строки
+			return nil, fmt.Errorf("%w: tickets were created concurrently on the same cleaning areas, check the file again", ErrConflict)
+		}
+		return nil, err
+	}
+
+	for i, ticket := range tickets {
+		id := ticket.ID
+		item := &result.Rows[ticketRows[i]]
+		item.Status = TicketImportRowCreated
+		item.TicketID = &id
+	}
+	result.Created = len(tickets)
+
+	s.log.Info().
+		Str("org_id", principal.OrgID.String()).
+		Int("created", result.Created).
+		Int("invalid", result.Invalid).
+		Msg("tickets imported")
+
+	return result, nil
+}
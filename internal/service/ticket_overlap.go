@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+// TicketOverlap - активный тикет того же участка, плановое окно которого пересекается с проверяемым
+type TicketOverlap struct {
+	TicketID       uuid.UUID          `json:"ticket_id"`
+	ContractorID   uuid.UUID          `json:"contractor_id"`
+	Status         model.TicketStatus `json:"status"`
+	PlannedStartAt time.Time          `json:"planned_start_at"`
+	PlannedEndAt   time.Time          `json:"planned_end_at"`
+}
+
+// TicketOverlapError - тикет пересекается по времени с активными тикетами того же участка.
+// Является ErrConflict (errors.Is); Overlaps - список пересекающихся тикетов для ответа API.
+type TicketOverlapError struct {
+	Overlaps []TicketOverlap
+}
+
+func newTicketOverlapError(tickets []model.Ticket) *TicketOverlapError {
+	overlaps := make([]TicketOverlap, 0, len(tickets))
+	for _, t := range tickets {
+		overlaps = append(overlaps, TicketOverlap{
+			TicketID:       t.ID,
+			ContractorID:   t.ContractorID,
+			Status:         t.Status,
+			PlannedStartAt: t.PlannedStartAt,
+			PlannedEndAt:   t.PlannedEndAt,
+		})
+	}
+	return &TicketOverlapError{Overlaps: overlaps}
+}
+
+func (e *TicketOverlapError) Error() string {
+	return fmt.Sprintf("conflict: ticket overlaps %d active ticket(s) on the same cleaning area", len(e.Overlaps))
+}
+
+func (e *TicketOverlapError) Unwrap() error {
+	return ErrConflict
+}
+
+// checkTicketOverlap возвращает TicketOverlapError, если плановое окно тикета пересекается
+// с активными тикетами того же участка. Тикеты с allow_overlap не проверяются.
+func (s *TicketService) checkTicketOverlap(ctx context.Context, ticket *model.Ticket) error {
+	if ticket.AllowOverlap {
+		return nil
+	}
+	tickets, err := s.ticketRepo.FindOverlapping(ctx, ticket.CleaningAreaID, ticket.PlannedStartAt, ticket.PlannedEndAt, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if len(tickets) == 0 {
+		return nil
+	}
+	return newTicketOverlapError(tickets)
+}
+
+// checkAssignmentBookings возвращает AssignmentOverlapError, если водители или машины активных
+// назначений тикета назначены на другие активные тикеты, окно которых пересекается с окном тикета
+func (s *TicketService) checkAssignmentBookings(ctx context.Context, ticket *model.Ticket) error {
+	assignments, err := s.assignmentRepo.ListByTicketID(ctx, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	driverIDs := make([]uuid.UUID, 0, len(assignments))
+	vehicleIDs := make([]uuid.UUID, 0, len(assignments))
+	for _, assignment := range assignments {
+		driverIDs = append(driverIDs, assignment.DriverID)
+		vehicleIDs = append(vehicleIDs, assignment.VehicleID)
+	}
+	bookings, err := s.assignmentRepo.ListBookings(ctx, driverIDs, vehicleIDs, ticket.PlannedStartAt, ticket.PlannedEndAt, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if len(bookings) == 0 {
+		return nil
+	}
+	return newAssignmentOverlapError(bookings, assignments...)
+}
+
+// overlapConflict превращает repository.ErrTicketOverlap и repository.ErrAssignmentOverlap
+// (пересекающийся тикет или назначение созданы параллельным запросом уже после предварительной
+// проверки) в TicketOverlapError или AssignmentOverlapError со списком пересечений
+func (s *TicketService) overlapConflict(ctx context.Context, ticket *model.Ticket, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrTicketOverlap):
+		if checkErr := s.checkTicketOverlap(ctx, ticket); checkErr != nil {
+			return checkErr
+		}
+	case errors.Is(err, repository.ErrAssignmentOverlap):
+		if checkErr := s.checkAssignmentBookings(ctx, ticket); checkErr != nil {
+			return checkErr
+		}
+	default:
+		return err
+	}
+	return ErrConflict
+}
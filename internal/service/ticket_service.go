@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,6 +27,7 @@ type TicketService struct {
 	assignmentRepo *repository.AssignmentRepository
 	appealRepo     *repository.AppealRepository
 	areaAccessRepo *repository.CleaningAreaAccessRepository
+	violationRepo  *repository.TripViolationRepository
 	log            zerolog.Logger
 }
 
@@ -35,6 +37,7 @@ func NewTicketService(
 	assignmentRepo *repository.AssignmentRepository,
 	appealRepo *repository.AppealRepository,
 	areaAccessRepo *repository.CleaningAreaAccessRepository,
+	violationRepo *repository.TripViolationRepository,
 	log zerolog.Logger,
 ) *TicketService {
 	return &TicketService{
@@ -43,6 +46,7 @@ func NewTicketService(
 		assignmentRepo: assignmentRepo,
 		appealRepo:     appealRepo,
 		areaAccessRepo: areaAccessRepo,
+		violationRepo:  violationRepo,
 		log:            log,
 	}
 }
@@ -53,51 +57,20 @@ func (s *TicketService) Create(ctx context.Context, principal model.Principal, i
 		return nil, ErrPermissionDenied
 	}
 
-	cleaningAreaID, err := uuid.Parse(input.CleaningAreaID)
-	if err != nil {
-		return nil, ErrInvalidInput
-	}
-
-	contractorID, err := uuid.Parse(input.ContractorID)
-	if err != nil {
-		return nil, ErrInvalidInput
-	}
-
-	if input.ContractID == "" {
-		return nil, ErrInvalidInput
-	}
-	contractID, err := uuid.Parse(input.ContractID)
-	if err != nil {
-		return nil, ErrInvalidInput
-	}
-
-	plannedStartAt, err := time.Parse(time.RFC3339, input.PlannedStartAt)
-	if err != nil {
-		return nil, ErrInvalidInput
-	}
-
-	plannedEndAt, err := time.Parse(time.RFC3339, input.PlannedEndAt)
-	if err != nil {
-		return nil, ErrInvalidInput
-	}
-
-	if plannedEndAt.Before(plannedStartAt) || plannedEndAt.Equal(plannedStartAt) {
+	ticket, problems := newTicketFromInput(principal, input)
+	if len(problems) > 0 {
 		return nil, ErrInvalidInput
 	}
+	cleaningAreaID, contractorID := ticket.CleaningAreaID, ticket.ContractorID
 
-	ticket := &model.Ticket{
-		CleaningAreaID: cleaningAreaID,
-		ContractorID:   contractorID,
-		ContractID:     contractID,
-		CreatedByOrgID: principal.OrgID,
-		Status:         model.TicketStatusPlanned,
-		PlannedStartAt: plannedStartAt,
-		PlannedEndAt:   plannedEndAt,
-		Description:    input.Description,
+	// Пересечение с другим активным тикетом участка допускается только явно (allow_overlap)
+	if err := s.checkTicketOverlap(ctx, ticket); err != nil {
+		return nil, err
 	}
 
-	if err := s.ticketRepo.Create(ctx, ticket); err != nil {
-		return nil, err
+	entry := newTicketStatusHistory(ticket, nil, model.TicketEventCreate, &principal, "")
+	if err := s.ticketRepo.CreateWithHistory(ctx, ticket, entry); err != nil {
+		return nil, s.overlapConflict(ctx, ticket, err)
 	}
 
 	// Automatically grant access to cleaning area for contractor
@@ -127,6 +100,59 @@ type CreateTicketInput struct {
 	PlannedStartAt string
 	PlannedEndAt   string
 	Description    string
+	// AllowOverlap - намеренно допустить пересечение с активными тикетами того же участка
+	AllowOverlap bool
+}
+
+// newTicketFromInput проверяет поля нового тикета и возвращает описание каждой ошибки
+// (используется и для одиночного создания, и для построчного отчета импорта)
+func newTicketFromInput(principal model.Principal, input CreateTicketInput) (*model.Ticket, []string) {
+	var problems []string
+
+	cleaningAreaID, err := uuid.Parse(strings.TrimSpace(input.CleaningAreaID))
+	if err != nil {
+		problems = append(problems, "cleaning_area_id: must be a UUID")
+	}
+
+	contractorID, err := uuid.Parse(strings.TrimSpace(input.ContractorID))
+	if err != nil {
+		problems = append(problems, "contractor_id: must be a UUID")
+	}
+
+	contractID, err := uuid.Parse(strings.TrimSpace(input.ContractID))
+	if err != nil {
+		problems = append(problems, "contract_id: must be a UUID")
+	}
+
+	plannedStartAt, startErr := time.Parse(time.RFC3339, strings.TrimSpace(input.PlannedStartAt))
+	if startErr != nil {
+		problems = append(problems, "planned_start_at: must be RFC3339, e.g. 2025-01-01T22:00:00+05:00")
+	}
+
+	plannedEndAt, endErr := time.Parse(time.RFC3339, strings.TrimSpace(input.PlannedEndAt))
+	if endErr != nil {
+		problems = append(problems, "planned_end_at: must be RFC3339, e.g. 2025-01-02T06:00:00+05:00")
+	}
+
+	if startErr == nil && endErr == nil && !plannedEndAt.After(plannedStartAt) {
+		problems = append(problems, "planned_end_at: must be after planned_start_at")
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return &model.Ticket{
+		CleaningAreaID: cleaningAreaID,
+		ContractorID:   contractorID,
+		ContractID:     contractID,
+		CreatedByOrgID: principal.OrgID,
+		Status:         model.TicketStatusPlanned,
+		PlannedStartAt: plannedStartAt,
+		PlannedEndAt:   plannedEndAt,
+		Description:    input.Description,
+		AllowOverlap:   input.AllowOverlap,
+	}, nil
 }
 
 func (s *TicketService) Get(ctx context.Context, principal model.Principal, id string) (*model.Ticket, error) {
@@ -171,7 +197,7 @@ func (s *TicketService) List(ctx context.Context, principal model.Principal, fil
 	return s.ticketRepo.List(ctx, filter)
 }
 
-func (s *TicketService) Cancel(ctx context.Context, principal model.Principal, id string) error {
+func (s *TicketService) Cancel(ctx context.Context, principal model.Principal, id string, reason string) error {
 	// Только KGU ZKH может отменять тикеты
 	if !principal.IsKgu() {
 		return ErrPermissionDenied
@@ -190,25 +216,10 @@ func (s *TicketService) Cancel(ctx context.Context, principal model.Principal, i
 	}
 
 	// Можно отменить только если нет фактов (нет рейсов и fact_start_at пустой)
-	if ticket.FactStartAt != nil {
-		return ErrConflict
-	}
-
-	// Проверяем, есть ли рейсы
-	tripCount, err := s.ticketRepo.CountTripsByTicketID(ctx, ticket.ID)
-	if err != nil {
-		return err
-	}
-
-	if tripCount > 0 {
-		return ErrConflict
-	}
-
-	ticket.Status = model.TicketStatusCancelled
-	return s.ticketRepo.Update(ctx, ticket)
+	return s.transition(ctx, ticket, model.TicketEventCancel, &principal, reason)
 }
 
-func (s *TicketService) Close(ctx context.Context, principal model.Principal, id string) error {
+func (s *TicketService) Close(ctx context.Context, principal model.Principal, id string, reason string) error {
 	// KGU ZKH может закрывать тикеты после проверки
 	if !principal.IsKgu() {
 		return ErrPermissionDenied
@@ -227,58 +238,91 @@ func (s *TicketService) Close(ctx context.Context, principal model.Principal, id
 	}
 
 	// Можно закрыть только если тикет в статусе COMPLETED
-	if ticket.Status != model.TicketStatusCompleted {
-		return ErrConflict
-	}
+	return s.transition(ctx, ticket, model.TicketEventClose, &principal, reason)
+}
 
-	ticket.Status = model.TicketStatusClosed
-	return s.ticketRepo.Update(ctx, ticket)
+type ReopenTicketInput struct {
+	Reason string
+	// PlannedEndAt - новый плановый срок окончания (RFC3339), пустой - срок не меняется
+	PlannedEndAt string
 }
 
-func (s *TicketService) Complete(ctx context.Context, principal model.Principal, id string) error {
-	// Подрядчик может завершить тикет
-	if !principal.IsContractor() {
-		return ErrPermissionDenied
+// Reopen возвращает завершенный тикет на доработку (COMPLETED → IN_PROGRESS).
+// Завершенные назначения заменяются новыми, чтобы водители могли выполнять новые рейсы;
+// прежние назначения и их рейсы остаются в истории. Если водители или машины назначений уже заняты
+// на других тикетах в окне тикета, возвращает AssignmentOverlapError.
+func (s *TicketService) Reopen(ctx context.Context, principal model.Principal, id string, input ReopenTicketInput) (*model.Ticket, error) {
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+
+	// Причина возврата обязательна
+	if strings.TrimSpace(input.Reason) == "" {
+		return nil, ErrInvalidInput
 	}
 
 	ticket, err := s.ticketRepo.GetByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrNotFound
+			return nil, ErrNotFound
 		}
-		return err
+		return nil, err
 	}
 
-	if ticket.ContractorID != principal.OrgID {
-		return ErrPermissionDenied
+	if ticket.CreatedByOrgID != principal.OrgID {
+		return nil, ErrPermissionDenied
 	}
 
-	// Проверяем, что все рейсы закрыты (есть exit события и кузов пустой)
-	incompleteTrips, err := s.ticketRepo.CountIncompleteTripsByTicketID(ctx, ticket.ID)
-	if err != nil {
-		return err
+	before := *ticket
+	if input.PlannedEndAt != "" {
+		plannedEndAt, err := time.Parse(time.RFC3339, input.PlannedEndAt)
+		if err != nil {
+			return nil, ErrInvalidInput
+		}
+		if !plannedEndAt.After(ticket.PlannedStartAt) || !plannedEndAt.After(time.Now()) {
+			return nil, ErrInvalidInput
+		}
+		ticket.PlannedEndAt = plannedEndAt
+	}
+	// Новый срок записывается в ticket_change_history вместе со сменой статуса
+	var changes []model.TicketChangeHistory
+	for _, change := range diffTicketFields(&before, ticket) {
+		changes = append(changes, newTicketChangeHistory(&before, change, principal))
 	}
 
-	if incompleteTrips > 0 {
-		return ErrConflict
+	// Назначения возобновляются: за время после завершения их водителей и машины могли назначить
+	// на другие тикеты
+	if err := s.checkAssignmentBookings(ctx, ticket); err != nil {
+		return nil, err
+	}
+
+	if err := s.transition(ctx, ticket, model.TicketEventReopen, &principal, input.Reason, changes...); err != nil {
+		// За время после завершения на участок могли запланировать другой тикет
+		return nil, s.overlapConflict(ctx, ticket, err)
 	}
+	return ticket, nil
+}
 
-	// Проверяем, что все водители отметили "Завершено"
-	incompleteAssignments, err := s.ticketRepo.CountIncompleteAssignmentsByTicketID(ctx, ticket.ID)
+func (s *TicketService) Complete(ctx context.Context, principal model.Principal, id string, reason string) error {
+	// Подрядчик может завершить тикет
+	if !principal.IsContractor() {
+		return ErrPermissionDenied
+	}
+
+	ticket, err := s.ticketRepo.GetByID(ctx, id)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
 		return err
 	}
 
-	if incompleteAssignments > 0 {
-		return ErrConflict
+	if ticket.ContractorID != principal.OrgID {
+		return ErrPermissionDenied
 	}
 
-	now := time.Now()
-	ticket.Status = model.TicketStatusCompleted
-	if ticket.FactEndAt == nil {
-		ticket.FactEndAt = &now
-	}
-	return s.ticketRepo.Update(ctx, ticket)
+	// Все рейсы должны быть закрыты, а все водители - отметить "Завершено"
+	return s.transition(ctx, ticket, model.TicketEventComplete, &principal, reason)
 }
 
 // TicketDetails содержит полную информацию о тикете
@@ -347,6 +391,18 @@ func (s *TicketService) GetDetails(ctx context.Context, principal model.Principa
 		trips = filteredTrips
 	}
 
+	tripIDs := make([]uuid.UUID, 0, len(trips))
+	for _, t := range trips {
+		tripIDs = append(tripIDs, t.ID)
+	}
+	violationsByTrip, err := s.violationRepo.ListByTripIDs(ctx, tripIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range trips {
+		trips[i].Violations = violationsByTrip[trips[i].ID]
+	}
+
 	// Получаем обжалования
 	appeals, err := s.ticketRepo.GetAppealsByTicketID(ctx, ticket.ID)
 	if err != nil {
@@ -389,16 +445,21 @@ func (s *TicketService) OnTripCreated(ctx context.Context, ticketID uuid.UUID) e
 		}
 
 		if firstTrip != nil {
-			now := time.Now()
-			ticket.Status = model.TicketStatusInProgress
-			ticket.FactStartAt = &now
-			return s.ticketRepo.Update(ctx, ticket)
+			return s.tryTransition(ctx, ticket, model.TicketEventStart, nil)
 		}
 	}
 
 	return nil
 }
 
+// OnDriverStarted вызывается, когда водитель отметил «В работе»: тикет PLANNED переходит в IN_PROGRESS
+func (s *TicketService) OnDriverStarted(ctx context.Context, ticket *model.Ticket, driver model.Principal) error {
+	if ticket.Status != model.TicketStatusPlanned || ticket.FactStartAt != nil {
+		return nil
+	}
+	return s.tryTransition(ctx, ticket, model.TicketEventStart, &driver)
+}
+
 // TryAutoComplete переводит тикет в COMPLETED, если выполнены все условия
 func (s *TicketService) TryAutoComplete(ctx context.Context, ticketID uuid.UUID) error {
 	ticket, err := s.ticketRepo.GetByID(ctx, ticketID.String())
@@ -410,29 +471,7 @@ func (s *TicketService) TryAutoComplete(ctx context.Context, ticketID uuid.UUID)
 		return nil
 	}
 
-	incompleteTrips, err := s.ticketRepo.CountIncompleteTripsByTicketID(ctx, ticket.ID)
-	if err != nil {
-		return err
-	}
-	if incompleteTrips > 0 {
-		return nil
-	}
-
-	incompleteAssignments, err := s.ticketRepo.CountIncompleteAssignmentsByTicketID(ctx, ticket.ID)
-	if err != nil {
-		return err
-	}
-	if incompleteAssignments > 0 {
-		return nil
-	}
-
-	now := time.Now()
-	ticket.Status = model.TicketStatusCompleted
-	if ticket.FactEndAt == nil {
-		ticket.FactEndAt = &now
-	}
-
-	return s.ticketRepo.Update(ctx, ticket)
+	return s.tryTransition(ctx, ticket, model.TicketEventAutoComplete, nil)
 }
 
 func (s *TicketService) canAccessTicket(ctx context.Context, principal model.Principal, ticket *model.Ticket) (bool, error) {
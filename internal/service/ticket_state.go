@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+// ticketTransition - допустимый переход статуса тикета по событию
+type ticketTransition struct {
+	from []model.TicketStatus
+	to   model.TicketStatus
+	// guard проверяет условия перехода; ErrConflict - переход сейчас невозможен
+	guard func(ctx context.Context, s *TicketService, ticket *model.Ticket) error
+	// apply - побочные эффекты перехода, сохраняемые вместе со статусом
+	apply func(ticket *model.Ticket, now time.Time)
+	// renewAssignments - заменить завершенные назначения новыми, чтобы водители снова могли выполнять рейсы
+	renewAssignments bool
+	// checkBookings - тикет снова становится активным: его водители и машины не должны быть заняты
+	// на других тикетах в окне тикета
+	checkBookings bool
+}
+
+// ticketTransitions - единственное место, где описано, как меняется статус тикета:
+//
+//	PLANNED     --START-->                  IN_PROGRESS  (первый рейс или «В работе» водителя)
+//	PLANNED     --CANCEL-->                 CANCELLED    (KGU, пока нет рейсов)
+//	IN_PROGRESS --COMPLETE/AUTO_COMPLETE--> COMPLETED    (все рейсы и назначения завершены)
+//	COMPLETED   --CLOSE-->                  CLOSED       (KGU принял работы)
+//	COMPLETED   --REOPEN-->                 IN_PROGRESS  (KGU вернул на доработку)
+var ticketTransitions = map[model.TicketEvent]ticketTransition{
+	model.TicketEventStart: {
+		from:  []model.TicketStatus{model.TicketStatusPlanned},
+		to:    model.TicketStatusInProgress,
+		apply: setTicketFactStart,
+	},
+	model.TicketEventCancel: {
+		from:  []model.TicketStatus{model.TicketStatusPlanned},
+		to:    model.TicketStatusCancelled,
+		guard: guardTicketNotStarted,
+	},
+	model.TicketEventComplete: {
+		from:  []model.TicketStatus{model.TicketStatusInProgress},
+		to:    model.TicketStatusCompleted,
+		guard: guardTicketWorkFinished,
+		apply: setTicketFactEnd,
+	},
+	model.TicketEventAutoComplete: {
+		from:  []model.TicketStatus{model.TicketStatusInProgress},
+		to:    model.TicketStatusCompleted,
+		guard: guardTicketWorkFinished,
+		apply: setTicketFactEnd,
+	},
+	model.TicketEventClose: {
+		from: []model.TicketStatus{model.TicketStatusCompleted},
+		to:   model.TicketStatusClosed,
+	},
+	model.TicketEventReopen: {
+		from:             []model.TicketStatus{model.TicketStatusCompleted},
+		to:               model.TicketStatusInProgress,
+		apply:            clearTicketFactEnd,
+		renewAssignments: true,
+		checkBookings:    true,
+	},
+}
+
+// transition переводит тикет по событию event: проверяет, что переход допустим из текущего статуса,
+// выполняет guard и побочные эффекты, сохраняет тикет и запись истории.
+// actor - пользователь, выполнивший переход (nil для автоматических переходов).
+// changes - записи истории полей, измененных вместе с переходом; сохраняются в той же транзакции.
+// Возвращает ErrConflict, если переход сейчас невозможен.
+func (s *TicketService) transition(ctx context.Context, ticket *model.Ticket, event model.TicketEvent, actor *model.Principal, reason string, changes ...model.TicketChangeHistory) error {
+	t, ok := ticketTransitions[event]
+	if !ok {
+		return fmt.Errorf("unknown ticket event %q", event)
+	}
+	if !slices.Contains(t.from, ticket.Status) {
+		return ErrConflict
+	}
+	if t.guard != nil {
+		if err := t.guard(ctx, s, ticket); err != nil {
+			return err
+		}
+	}
+
+	from := ticket.Status
+	updated := *ticket
+	updated.Status = t.to
+	if t.apply != nil {
+		t.apply(&updated, time.Now())
+	}
+
+	entry := newTicketStatusHistory(&updated, &from, event, actor, reason)
+	changed, err := s.ticketRepo.ChangeStatus(ctx, repository.TicketStatusChange{
+		Ticket:                    &updated,
+		From:                      from,
+		Entry:                     entry,
+		Changes:                   changes,
+		RenewCompletedAssignments: t.renewAssignments,
+		CheckAssignmentBookings:   t.checkBookings,
+	})
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return ErrConflict
+	}
+	*ticket = updated
+
+	s.log.Info().
+		Str("ticket_id", ticket.ID.String()).
+		Str("event", string(event)).
+		Str("from", string(from)).
+		Str("to", string(ticket.Status)).
+		Msg("ticket status changed")
+
+	return nil
+}
+
+// tryTransition выполняет автоматический переход, если он сейчас возможен
+func (s *TicketService) tryTransition(ctx context.Context, ticket *model.Ticket, event model.TicketEvent, actor *model.Principal) error {
+	if err := s.transition(ctx, ticket, event, actor, ""); err != nil && !errors.Is(err, ErrConflict) {
+		return err
+	}
+	return nil
+}
+
+func newTicketStatusHistory(ticket *model.Ticket, from *model.TicketStatus, event model.TicketEvent, actor *model.Principal, reason string) *model.TicketStatusHistory {
+	entry := &model.TicketStatusHistory{
+		TicketID:   ticket.ID,
+		FromStatus: from,
+		ToStatus:   ticket.Status,
+		Event:      event,
+	}
+	if actor != nil {
+		userID, orgID, role := actor.UserID, actor.OrgID, actor.Role
+		entry.ActorUserID = &userID
+		entry.ActorOrgID = &orgID
+		entry.ActorRole = &role
+	}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		entry.Reason = &reason
+	}
+	return entry
+}
+
+// guardTicketNotStarted - отменить можно только тикет без фактов работ (нет рейсов и fact_start_at)
+func guardTicketNotStarted(ctx context.Context, s *TicketService, ticket *model.Ticket) error {
+	if ticket.FactStartAt != nil {
+		return ErrConflict
+	}
+	tripCount, err := s.ticketRepo.CountTripsByTicketID(ctx, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if tripCount > 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// guardTicketWorkFinished - все рейсы закрыты (есть выезд) и все водители отметили «Завершено»
+func guardTicketWorkFinished(ctx context.Context, s *TicketService, ticket *model.Ticket) error {
+	incompleteTrips, err := s.ticketRepo.CountIncompleteTripsByTicketID(ctx, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if incompleteTrips > 0 {
+		return ErrConflict
+	}
+
+	incompleteAssignments, err := s.ticketRepo.CountIncompleteAssignmentsByTicketID(ctx, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if incompleteAssignments > 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func setTicketFactStart(ticket *model.Ticket, now time.Time) {
+	if ticket.FactStartAt == nil {
+		ticket.FactStartAt = &now
+	}
+}
+
+func setTicketFactEnd(ticket *model.Ticket, now time.Time) {
+	if ticket.FactEndAt == nil {
+		ticket.FactEndAt = &now
+	}
+}
+
+func clearTicketFactEnd(ticket *model.Ticket, _ time.Time) {
+	ticket.FactEndAt = nil
+}
+
+// GetStatusHistory возвращает историю статусов тикета всем, кто может видеть тикет
+func (s *TicketService) GetStatusHistory(ctx context.Context, principal model.Principal, id string) ([]model.TicketStatusHistory, error) {
+	ticket, err := s.ticketRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	ok, err := s.canAccessTicket(ctx, principal, ticket)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPermissionDenied
+	}
+
+	return s.ticketRepo.ListStatusHistory(ctx, ticket.ID)
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+)
+
+// UpdateTicketInput - частичное изменение тикета: nil - поле не меняется
+type UpdateTicketInput struct {
+	CleaningAreaID *string
+	ContractorID   *string
+	ContractID     *string
+	PlannedStartAt *string
+	PlannedEndAt   *string
+	Description    *string
+	AllowOverlap   *bool
+}
+
+const (
+	ticketFieldCleaningArea = "cleaning_area_id"
+	ticketFieldContractor   = "contractor_id"
+	ticketFieldContract     = "contract_id"
+	ticketFieldPlannedStart = "planned_start_at"
+	ticketFieldPlannedEnd   = "planned_end_at"
+	ticketFieldDescription  = "description"
+	ticketFieldAllowOverlap = "allow_overlap"
+)
+
+// ticketEditableFields - какие поля тикета можно менять в каждом статусе.
+// В IN_PROGRESS срок окончания можно только продлить. CLOSED и CANCELLED не редактируются.
+var ticketEditableFields = map[model.TicketStatus][]string{
+	model.TicketStatusPlanned: {
+		ticketFieldCleaningArea,
+		ticketFieldContractor,
+		ticketFieldContract,
+		ticketFieldPlannedStart,
+		ticketFieldPlannedEnd,
+		ticketFieldDescription,
+		ticketFieldAllowOverlap,
+	},
+	model.TicketStatusInProgress: {ticketFieldPlannedEnd, ticketFieldDescription, ticketFieldAllowOverlap},
+	model.TicketStatusCompleted:  {ticketFieldDescription},
+}
+
+// Update изменяет поля тикета по правилам ticketEditableFields и записывает каждое изменение
+// в ticket_change_history. Поле, которое нельзя менять в текущем статусе, - ErrConflict.
+// Новое плановое окно или участок проверяются на пересечение с активными тикетами участка
+// (TicketOverlapError), если у тикета не выставлен allow_overlap. Новое плановое окно не должно
+// пересекаться с назначениями водителей и машин тикета на другие тикеты (AssignmentOverlapError).
+// Смена подрядчика или участка выдает подрядчику доступ к участку.
+func (s *TicketService) Update(ctx context.Context, principal model.Principal, id string, input UpdateTicketInput) (*model.Ticket, error) {
+	// Только KGU ZKH может редактировать тикеты
+	if !principal.IsKgu() {
+		return nil, ErrPermissionDenied
+	}
+
+	ticket, err := s.ticketRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if ticket.CreatedByOrgID != principal.OrgID {
+		return nil, ErrPermissionDenied
+	}
+
+	updated := *ticket
+	provided := false
+
+	parseUUIDField := func(value *string, target *uuid.UUID) error {
+		if value == nil {
+			return nil
+		}
+		provided = true
+		parsed, err := uuid.Parse(strings.TrimSpace(*value))
+		if err != nil {
+			return ErrInvalidInput
+		}
+		*target = parsed
+		return nil
+	}
+	parseTimeField := func(value *string, target *time.Time) error {
+		if value == nil {
+			return nil
+		}
+		provided = true
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(*value))
+		if err != nil {
+			return ErrInvalidInput
+		}
+		*target = parsed
+		return nil
+	}
+
+	if err := parseUUIDField(input.CleaningAreaID, &updated.CleaningAreaID); err != nil {
+		return nil, err
+	}
+	if err := parseUUIDField(input.ContractorID, &updated.ContractorID); err != nil {
+		return nil, err
+	}
+	if err := parseUUIDField(input.ContractID, &updated.ContractID); err != nil {
+		return nil, err
+	}
+	if err := parseTimeField(input.PlannedStartAt, &updated.PlannedStartAt); err != nil {
+		return nil, err
+	}
+	if err := parseTimeField(input.PlannedEndAt, &updated.PlannedEndAt); err != nil {
+		return nil, err
+	}
+	if input.Description != nil {
+		provided = true
+		updated.Description = *input.Description
+	}
+	if input.AllowOverlap != nil {
+		provided = true
+		updated.AllowOverlap = *input.AllowOverlap
+	}
+	if !provided {
+		return nil, ErrInvalidInput
+	}
+
+	changes := diffTicketFields(ticket, &updated)
+	if len(changes) == 0 {
+		return ticket, nil
+	}
+
+	editable := ticketEditableFields[ticket.Status]
+	updates := make(map[string]interface{}, len(changes))
+	history := make([]model.TicketChangeHistory, 0, len(changes))
+	for _, change := range changes {
+		if !slices.Contains(editable, change.field) {
+			return nil, ErrConflict
+		}
+		updates[change.field] = change.value
+		history = append(history, newTicketChangeHistory(ticket, change, principal))
+	}
+
+	if !updated.PlannedEndAt.After(updated.PlannedStartAt) {
+		return nil, ErrInvalidInput
+	}
+	// Во время работ срок можно только продлить
+	if ticket.Status == model.TicketStatusInProgress && updated.PlannedEndAt.Before(ticket.PlannedEndAt) {
+		return nil, ErrInvalidInput
+	}
+
+	windowChanged := updated.CleaningAreaID != ticket.CleaningAreaID ||
+		!updated.PlannedStartAt.Equal(ticket.PlannedStartAt) ||
+		!updated.PlannedEndAt.Equal(ticket.PlannedEndAt) ||
+		(ticket.AllowOverlap && !updated.AllowOverlap)
+	if windowChanged {
+		if err := s.checkTicketOverlap(ctx, &updated); err != nil {
+			return nil, err
+		}
+	}
+
+	// Водители и машины назначений тикета не должны быть заняты на других тикетах в новом окне
+	plannedWindowChanged := !updated.PlannedStartAt.Equal(ticket.PlannedStartAt) ||
+		!updated.PlannedEndAt.Equal(ticket.PlannedEndAt)
+	if plannedWindowChanged {
+		if err := s.checkAssignmentBookings(ctx, &updated); err != nil {
+			return nil, err
+		}
+	}
+
+	contractorChanged := updated.ContractorID != ticket.ContractorID
+	if contractorChanged {
+		// Назначения ссылаются на водителей прежнего подрядчика
+		activeAssignments, err := s.ticketRepo.CountActiveAssignmentsByTicketID(ctx, ticket.ID)
+		if err != nil {
+			return nil, err
+		}
+		if activeAssignments > 0 {
+			return nil, ErrConflict
+		}
+	}
+
+	ok, err := s.ticketRepo.UpdateFields(ctx, ticket.ID, ticket.Status, updates, history, plannedWindowChanged)
+	if err != nil {
+		return nil, s.overlapConflict(ctx, &updated, err)
+	}
+	if !ok {
+		// Статус тикета изменился параллельно - правила редактирования могли измениться
+		return nil, ErrConflict
+	}
+
+	if contractorChanged || updated.CleaningAreaID != ticket.CleaningAreaID {
+		// Best-effort, как при создании тикета
+		if err := s.areaAccessRepo.Grant(ctx, updated.CleaningAreaID, updated.ContractorID, "TICKET"); err != nil {
+			s.log.Warn().
+				Err(err).
+				Str("cleaning_area_id", updated.CleaningAreaID.String()).
+				Str("contractor_id", updated.ContractorID.String()).
+				Str("ticket_id", ticket.ID.String()).
+				Msg("failed to grant cleaning area access for contractor (ticket updated successfully)")
+		}
+	}
+
+	s.log.Info().
+		Str("ticket_id", ticket.ID.String()).
+		Int("changed_fields", len(changes)).
+		Msg("ticket updated")
+
+	return s.ticketRepo.GetByID(ctx, ticket.ID.String())
+}
+
+// GetChangeHistory возвращает историю изменений полей тикета всем, кто может видеть тикет
+func (s *TicketService) GetChangeHistory(ctx context.Context, principal model.Principal, id string) ([]model.TicketChangeHistory, error) {
+	ticket, err := s.ticketRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	ok, err := s.canAccessTicket(ctx, principal, ticket)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPermissionDenied
+	}
+
+	return s.ticketRepo.ListChangeHistory(ctx, ticket.ID)
+}
+
+type ticketFieldChange struct {
+	field    string
+	value    interface{}
+	oldValue *string
+	newValue *string
+}
+
+func diffTicketFields(before, after *model.Ticket) []ticketFieldChange {
+	var changes []ticketFieldChange
+	addUUID := func(field string, old, new uuid.UUID) {
+		if old != new {
+			changes = append(changes, ticketFieldChange{field, new, stringPtr(old.String()), stringPtr(new.String())})
+		}
+	}
+	addTime := func(field string, old, new time.Time) {
+		if !old.Equal(new) {
+			changes = append(changes, ticketFieldChange{field, new,
+				stringPtr(old.UTC().Format(time.RFC3339)), stringPtr(new.UTC().Format(time.RFC3339))})
+		}
+	}
+
+	addUUID(ticketFieldCleaningArea, before.CleaningAreaID, after.CleaningAreaID)
+	addUUID(ticketFieldContractor, before.ContractorID, after.ContractorID)
+	addUUID(ticketFieldContract, before.ContractID, after.ContractID)
+	addTime(ticketFieldPlannedStart, before.PlannedStartAt, after.PlannedStartAt)
+	addTime(ticketFieldPlannedEnd, before.PlannedEndAt, after.PlannedEndAt)
+	if before.Description != after.Description {
+		changes = append(changes, ticketFieldChange{ticketFieldDescription, after.Description,
+			stringPtr(before.Description), stringPtr(after.Description)})
+	}
+	if before.AllowOverlap != after.AllowOverlap {
+		changes = append(changes, ticketFieldChange{ticketFieldAllowOverlap, after.AllowOverlap,
+			stringPtr(strconv.FormatBool(before.AllowOverlap)), stringPtr(strconv.FormatBool(after.AllowOverlap))})
+	}
+	return changes
+}
+
+// newTicketChangeHistory - запись истории изменения поля тикета в его текущем статусе
+func newTicketChangeHistory(ticket *model.Ticket, change ticketFieldChange, actor model.Principal) model.TicketChangeHistory {
+	return model.TicketChangeHistory{
+		TicketID:     ticket.ID,
+		Field:        change.field,
+		OldValue:     change.oldValue,
+		NewValue:     change.newValue,
+		TicketStatus: ticket.Status,
+		ActorUserID:  actor.UserID,
+		ActorOrgID:   actor.OrgID,
+		ActorRole:    actor.Role,
+	}
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"ticket-service/internal/model"
+)
+
+// ReconcileTrip повторно сопоставляет рейс без назначения с назначениями водителя/машины.
+// При однозначном совпадении рейс привязывается к тикету, статус тикета и доступ к полигону
+// обновляются так же, как при создании рейса. Возвращает запись о выполненном действии
+// или nil, если подходящих назначений нет.
+func (s *TripService) ReconcileTrip(ctx context.Context, trip *model.Trip) (*model.TripReconciliationLog, error) {
+	if trip.TicketID != nil || (trip.DriverID == nil && trip.VehicleID == nil) {
+		return nil, nil
+	}
+
+	match, err := s.matchAssignmentForReconciliation(ctx, trip.DriverID, trip.VehicleID, trip.EntryAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(match.Candidates) == 0 {
+		return nil, nil
+	}
+
+	entry := &model.TripReconciliationLog{
+		TripID:         trip.ID,
+		PreviousStatus: trip.Status,
+		NewStatus:      trip.Status,
+		Details:        reconciliationDetails(match.Candidates),
+	}
+
+	if match.Ambiguous {
+		entry.Action = model.ReconciliationActionAmbiguous
+		return entry, nil
+	}
+
+	assignment := match.Assignment
+	entry.TicketID = &assignment.TicketID
+	entry.TicketAssignmentID = &assignment.ID
+
+	// Рейс не привязываем к тикету, работы по которому уже завершены
+	ticket, err := s.ticketRepo.GetByID(ctx, assignment.TicketID.String())
+	if err != nil {
+		return nil, err
+	}
+	switch ticket.Status {
+	case model.TicketStatusCancelled, model.TicketStatusClosed, model.TicketStatusCompleted:
+		entry.Action = model.ReconciliationActionSkipped
+		return entry, nil
+	}
+
+	ticketID := assignment.TicketID
+	assignmentID := assignment.ID
+	driverID := assignment.DriverID
+	vehicleID := assignment.VehicleID
+	trip.TicketID = &ticketID
+	trip.TicketAssignmentID = &assignmentID
+	trip.DriverID = &driverID
+	trip.VehicleID = &vehicleID
+
+	if err := s.Update(ctx, trip); err != nil {
+		return nil, err
+	}
+
+	if s.ticketService != nil {
+		if err := s.ticketService.OnTripCreated(ctx, ticketID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.grantPolygonAccess(ctx, trip)
+
+	entry.Action = model.ReconciliationActionAttached
+	entry.NewStatus = trip.Status
+	return entry, nil
+}
+
+// reconciliationDetails сохраняет кандидатов сопоставления для разбора в журнале
+func reconciliationDetails(candidates []AssignmentMatchCandidate) *string {
+	payload, err := json.Marshal(map[string]interface{}{
+		"candidates": candidates,
+	})
+	if err != nil {
+		return nil
+	}
+	details := string(payload)
+	return &details
+}
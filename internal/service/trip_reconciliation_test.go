@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"ticket-service/internal/model"
+)
+
+// Рейс въехал раньше, чем подрядчик создал назначение: кандидат отбирается по окну тикета
+func TestReconcileTripAssignedAfterEntry(t *testing.T) {
+	entryAt := time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)
+	assignedAt := entryAt.Add(2 * time.Hour)
+	plannedStart := time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)
+	plannedEnd := time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		statuses   []model.TicketStatus
+		wantAction string
+	}{
+		{
+			// Назначение найдено, но тикет уже завершен: рейс не привязывается
+			name:       "completed ticket",
+			statuses:   []model.TicketStatus{model.TicketStatusCompleted},
+			wantAction: model.ReconciliationActionSkipped,
+		},
+		{
+			name:       "two equal candidates",
+			statuses:   []model.TicketStatus{model.TicketStatusInProgress, model.TicketStatusInProgress},
+			wantAction: model.ReconciliationActionAmbiguous,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newVolumeFixture(t)
+			driverID, vehicleID := uuid.New(), uuid.New()
+			trip := &model.Trip{
+				ID:        uuid.New(),
+				DriverID:  &driverID,
+				VehicleID: &vehicleID,
+				EntryAt:   entryAt,
+				Status:    model.TripStatusNoAssignment,
+			}
+
+			assignments := sqlmock.NewRows([]string{"id", "ticket_id", "driver_id", "vehicle_id", "is_active", "assigned_at"})
+			ticketIDs := make([]uuid.UUID, len(tt.statuses))
+			for i := range tt.statuses {
+				ticketIDs[i] = uuid.New()
+				assignments.AddRow(uuid.New(), ticketIDs[i], driverID, vehicleID, true, assignedAt)
+			}
+			f.mock.ExpectQuery(`SELECT ta\.\* FROM ticket_assignments ta JOIN tickets t ON t\.id = ta\.ticket_id`).
+				WithArgs(true, entryAt, entryAt, entryAt, entryAt, entryAt, driverID, vehicleID).
+				WillReturnRows(assignments)
+			expectTicket := func(i int) {
+				f.mock.ExpectQuery(`SELECT \* FROM "tickets"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status", "planned_start_at", "planned_end_at"}).
+						AddRow(ticketIDs[i], string(tt.statuses[i]), plannedStart, plannedEnd))
+			}
+			for i := range tt.statuses {
+				expectTicket(i)
+			}
+			if tt.wantAction == model.ReconciliationActionSkipped {
+				expectTicket(0)
+			}
+
+			entry, err := f.service.ReconcileTrip(context.Background(), trip)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if entry == nil {
+				t.Fatal("assignment created after entry was not matched")
+			}
+			if entry.Action != tt.wantAction {
+				t.Errorf("got action %s, want %s", entry.Action, tt.wantAction)
+			}
+			if trip.TicketID != nil {
+				t.Errorf("trip attached to ticket %s", *trip.TicketID)
+			}
+			if err := f.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
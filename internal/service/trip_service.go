@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,9 +12,12 @@ import (
 	"gorm.io/gorm"
 
 	"ticket-service/internal/client"
+	"ticket-service/internal/jobs"
 	"ticket-service/internal/model"
+	"ticket-service/internal/plate"
 	"ticket-service/internal/repository"
-	"ticket-service/internal/utils"
+	"ticket-service/internal/violations"
+	"ticket-service/internal/volume"
 )
 
 type TripService struct {
@@ -21,8 +25,14 @@ type TripService struct {
 	ticketRepo        *repository.TicketRepository
 	assignmentRepo    *repository.AssignmentRepository
 	ticketService     *TicketService
-	anprClient        *client.ANPRClient
+	anprSource        client.ANPREventSource
 	polygonAccessRepo *repository.PolygonAccessRepository
+	contractSettings  *repository.ContractSettingsRepository
+	violationRepo     *repository.TripViolationRepository
+	jobQueue          *jobs.Queue
+	violationEngine   *violations.Engine
+	thresholds        violations.Thresholds
+	volumeStrategy    model.VolumeStrategy
 	log               zerolog.Logger
 }
 
@@ -31,8 +41,14 @@ func NewTripService(
 	ticketRepo *repository.TicketRepository,
 	assignmentRepo *repository.AssignmentRepository,
 	ticketService *TicketService,
-	anprClient *client.ANPRClient,
+	anprSource client.ANPREventSource,
 	polygonAccessRepo *repository.PolygonAccessRepository,
+	contractSettings *repository.ContractSettingsRepository,
+	violationRepo *repository.TripViolationRepository,
+	jobQueue *jobs.Queue,
+	violationEngine *violations.Engine,
+	thresholds violations.Thresholds,
+	volumeStrategy model.VolumeStrategy,
 	log zerolog.Logger,
 ) *TripService {
 	return &TripService{
@@ -40,13 +56,21 @@ func NewTripService(
 		ticketRepo:        ticketRepo,
 		assignmentRepo:    assignmentRepo,
 		ticketService:     ticketService,
-		anprClient:        anprClient,
+		anprSource:        anprSource,
 		polygonAccessRepo: polygonAccessRepo,
+		contractSettings:  contractSettings,
+		violationRepo:     violationRepo,
+		jobQueue:          jobQueue,
+		violationEngine:   violationEngine,
+		thresholds:        thresholds,
+		volumeStrategy:    volumeStrategy,
 		log:               log,
 	}
 }
 
 type CreateTripInput struct {
+	// ExternalID - идентификатор рейса/события в вышестоящем сервисе, используется для дедупликации
+	ExternalID          *string
 	TicketID            *string
 	TicketAssignmentID  *string
 	DriverID            *string
@@ -67,11 +91,22 @@ type CreateTripInput struct {
 }
 
 func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model.Trip, error) {
+	trip, _, err := s.create(ctx, input)
+	return trip, err
+}
+
+// create создает рейс и возвращает результат сопоставления с назначением
+// (nil, если назначение было указано явно или рейс уже существовал)
+func (s *TripService) create(ctx context.Context, input CreateTripInput) (*model.Trip, *AssignmentMatch, error) {
+	if input.ExternalID != nil && *input.ExternalID == "" {
+		input.ExternalID = nil
+	}
+
 	var ticketID *uuid.UUID
 	if input.TicketID != nil {
 		parsed, err := uuid.Parse(*input.TicketID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		ticketID = &parsed
 	}
@@ -80,7 +115,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.TicketAssignmentID != nil {
 		parsed, err := uuid.Parse(*input.TicketAssignmentID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		ticketAssignmentID = &parsed
 	}
@@ -89,7 +124,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.DriverID != nil {
 		parsed, err := uuid.Parse(*input.DriverID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		driverID = &parsed
 	}
@@ -98,7 +133,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.VehicleID != nil {
 		parsed, err := uuid.Parse(*input.VehicleID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		vehicleID = &parsed
 	}
@@ -107,7 +142,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.CameraID != nil {
 		parsed, err := uuid.Parse(*input.CameraID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		cameraID = &parsed
 	}
@@ -116,7 +151,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.PolygonID != nil {
 		parsed, err := uuid.Parse(*input.PolygonID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		polygonID = &parsed
 	}
@@ -125,7 +160,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.EntryLprEventID != nil {
 		parsed, err := uuid.Parse(*input.EntryLprEventID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		entryLprEventID = &parsed
 	}
@@ -134,7 +169,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.ExitLprEventID != nil {
 		parsed, err := uuid.Parse(*input.ExitLprEventID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		exitLprEventID = &parsed
 	}
@@ -143,7 +178,7 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.EntryVolumeEventID != nil {
 		parsed, err := uuid.Parse(*input.EntryVolumeEventID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		entryVolumeEventID = &parsed
 	}
@@ -152,21 +187,30 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 	if input.ExitVolumeEventID != nil {
 		parsed, err := uuid.Parse(*input.ExitVolumeEventID)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		exitVolumeEventID = &parsed
 	}
 
 	entryAt, err := time.Parse(time.RFC3339, input.EntryAt)
 	if err != nil {
-		return nil, ErrInvalidInput
+		return nil, nil, ErrInvalidInput
+	}
+
+	// Повторная доставка того же рейса/события возвращает ранее созданный рейс
+	existing, err := s.findExistingTrip(ctx, input.ExternalID, entryLprEventID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		return existing, nil, nil
 	}
 
 	var exitAt *time.Time
 	if input.ExitAt != nil {
 		parsed, err := time.Parse(time.RFC3339, *input.ExitAt)
 		if err != nil {
-			return nil, ErrInvalidInput
+			return nil, nil, ErrInvalidInput
 		}
 		exitAt = &parsed
 	}
@@ -176,30 +220,33 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 		a, err := s.assignmentRepo.GetByID(ctx, ticketAssignmentID.String())
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return nil, ErrNotFound
+				return nil, nil, ErrNotFound
 			}
-			return nil, err
+			return nil, nil, err
 		}
-		if !a.IsActive {
-			return nil, ErrConflict
+		// Снятое назначение принимает только рейсы, въехавшие до снятия (поздняя доставка)
+		if !a.IsActive && (a.UnassignedAt == nil || a.UnassignedAt.Before(entryAt)) {
+			return nil, nil, ErrConflict
 		}
 		assignment = a
 	}
 
-	if assignment == nil && driverID != nil {
-		a, err := s.assignmentRepo.FindActiveByDriver(ctx, *driverID)
+	// Назначение не указано явно - подбираем по времени въезда, водителю и машине
+	var match *AssignmentMatch
+	if assignment == nil && (driverID != nil || vehicleID != nil) {
+		match, err = s.matchAssignment(ctx, driverID, vehicleID, ticketID, entryAt)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		assignment = a
-	}
-
-	if assignment == nil && vehicleID != nil {
-		a, err := s.assignmentRepo.FindActiveByVehicle(ctx, *vehicleID)
-		if err != nil {
-			return nil, err
+		if match.Ambiguous {
+			s.log.Warn().
+				Interface("driver_id", driverID).
+				Interface("vehicle_id", vehicleID).
+				Time("entry_at", entryAt).
+				Int("candidates", len(match.Candidates)).
+				Msg("ambiguous assignment match, trip left without assignment")
 		}
-		assignment = a
+		assignment = match.Assignment
 	}
 
 	if assignment != nil {
@@ -220,23 +267,32 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 		t, err := s.ticketRepo.GetByID(ctx, ticketID.String())
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return nil, ErrNotFound
+				return nil, nil, ErrNotFound
 			}
-			return nil, err
+			return nil, nil, err
 		}
 
 		switch t.Status {
 		case model.TicketStatusCancelled, model.TicketStatusClosed, model.TicketStatusCompleted:
-			return nil, ErrConflict
+			return nil, nil, ErrConflict
 		}
 	}
 
-	tripStatus := input.Status
-	if ticketID == nil {
-		tripStatus = model.TripStatusNoAssignment
+	// Статус от вызывающей стороны сохраняется как внешнее нарушение,
+	// итоговый статус рейса вычисляет движок нарушений
+	var reportedStatus *model.TripStatus
+	if input.Status != "" {
+		if !input.Status.IsValid() {
+			return nil, nil, ErrInvalidInput
+		}
+		if input.Status != model.TripStatusOK {
+			status := input.Status
+			reportedStatus = &status
+		}
 	}
 
 	trip := &model.Trip{
+		ExternalID:          input.ExternalID,
 		TicketID:            ticketID,
 		TicketAssignmentID:  ticketAssignmentID,
 		DriverID:            driverID,
@@ -253,46 +309,399 @@ func (s *TripService) Create(ctx context.Context, input CreateTripInput) (*model
 		DetectedVolumeExit:  input.DetectedVolumeExit,
 		EntryAt:             entryAt,
 		ExitAt:              exitAt,
-		Status:              tripStatus,
+		ReportedStatus:      reportedStatus,
 	}
 
-	if err := s.tripRepo.Create(ctx, trip); err != nil {
-		return nil, err
+	findings, err := s.evaluateViolations(ctx, trip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if match != nil && match.Ambiguous {
+		// Неоднозначное сопоставление сохраняется для ручного разбора вместе с рейсом,
+		// как это делает фоновое сопоставление
+		err = s.tripRepo.CreateWithReconciliationLog(ctx, trip, &model.TripReconciliationLog{
+			Action:         model.ReconciliationActionAmbiguous,
+			PreviousStatus: trip.Status,
+			NewStatus:      trip.Status,
+			Details:        reconciliationDetails(match.Candidates),
+		})
+	} else {
+		err = s.tripRepo.Create(ctx, trip)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// Параллельный запрос успел создать тот же рейс
+			existing, findErr := s.findExistingTrip(ctx, input.ExternalID, entryLprEventID)
+			if findErr != nil {
+				return nil, nil, findErr
+			}
+			if existing != nil {
+				return existing, nil, nil
+			}
+			return nil, nil, ErrConflict
+		}
+		return nil, nil, err
+	}
+
+	if err := s.syncViolations(ctx, trip, findings); err != nil {
+		return nil, nil, err
 	}
 
 	// Автоматический переход статуса тикета при создании первого рейса
 	if ticketID != nil && s.ticketService != nil {
 		if err := s.ticketService.OnTripCreated(ctx, *ticketID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s.grantPolygonAccess(ctx, trip)
+
+	return trip, match, nil
+}
+
+// grantPolygonAccess автоматически выдает подрядчику тикета доступ к полигону рейса.
+// This is best-effort: if it fails, we log but don't fail trip creation
+func (s *TripService) grantPolygonAccess(ctx context.Context, trip *model.Trip) {
+	if trip.PolygonID == nil || trip.TicketID == nil {
+		return
+	}
+
+	// Get ticket to retrieve contractor ID
+	ticket, err := s.ticketRepo.GetByID(ctx, trip.TicketID.String())
+	if err != nil || ticket == nil {
+		return
+	}
+
+	if err := s.polygonAccessRepo.Grant(ctx, *trip.PolygonID, ticket.ContractorID, "TRIP"); err != nil {
+		s.log.Warn().
+			Err(err).
+			Str("polygon_id", trip.PolygonID.String()).
+			Str("contractor_id", ticket.ContractorID.String()).
+			Str("trip_id", trip.ID.String()).
+			Str("ticket_id", trip.TicketID.String()).
+			Msg("failed to grant polygon access for contractor (trip created successfully)")
+		return
+	}
+
+	s.log.Info().
+		Str("polygon_id", trip.PolygonID.String()).
+		Str("contractor_id", ticket.ContractorID.String()).
+		Str("trip_id", trip.ID.String()).
+		Str("ticket_id", trip.TicketID.String()).
+		Msg("automatically granted polygon access for contractor")
+}
+
+// Update пересчитывает нарушения и сохраняет рейс. Все изменения рейса должны проходить через этот метод,
+// чтобы статус и trip_violations оставались согласованными с данными рейса.
+func (s *TripService) Update(ctx context.Context, trip *model.Trip) error {
+	findings, err := s.evaluateViolations(ctx, trip)
+	if err != nil {
+		return err
+	}
+	if err := s.tripRepo.Update(ctx, trip); err != nil {
+		return err
+	}
+	return s.syncViolations(ctx, trip, findings)
+}
+
+// Reevaluate пересчитывает статус рейса, например после снятия нарушения по обжалованию
+func (s *TripService) Reevaluate(ctx context.Context, tripID uuid.UUID) (*model.Trip, error) {
+	trip, err := s.tripRepo.GetByID(ctx, tripID.String())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := s.Update(ctx, trip); err != nil {
+		return nil, err
+	}
+	return trip, nil
+}
+
+// evaluateViolations рассчитывает принятый объем рейса по стратегии договора, прогоняет рейс
+// через движок нарушений и заполняет Status и ViolationReason.
+// Нарушения, снятые по обжалованию, на статус не влияют. Возвращает все найденные нарушения
+// для сохранения в trip_violations.
+func (s *TripService) evaluateViolations(ctx context.Context, trip *model.Trip) ([]violations.Finding, error) {
+	in := &violations.Input{
+		Trip:       trip,
+		Thresholds: s.thresholds,
+	}
+	strategy := s.volumeStrategy
+
+	if trip.TicketID != nil {
+		ticket, err := s.ticketRepo.GetByID(ctx, trip.TicketID.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ticket for violations: %w", err)
+		}
+		in.Ticket = ticket
+
+		thresholds, contractStrategy, err := s.contractPolicy(ctx, ticket.ContractID)
+		if err != nil {
 			return nil, err
 		}
+		in.Thresholds = thresholds
+		strategy = contractStrategy
+
+		if in.Thresholds.ContractVolumeLimitM3 > 0 {
+			contractVolume, err := s.tripRepo.SumVolumeByContract(ctx, ticket.ContractID, trip.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sum contract volume: %w", err)
+			}
+			in.ContractVolumeM3 = contractVolume
+		}
+
+		if in.Thresholds.RequirePolygonAccess && trip.PolygonID != nil {
+			hasAccess, err := s.polygonAccessRepo.HasAccess(ctx, *trip.PolygonID, ticket.ContractorID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check polygon access: %w", err)
+			}
+			in.HasPolygonAccess = hasAccess
+		}
 	}
 
-	// Automatically grant polygon access for contractor if trip has polygon_id and ticket_id
-	// This is best-effort: if it fails, we log but don't fail trip creation
-	if polygonID != nil && ticketID != nil {
-		// Get ticket to retrieve contractor ID
-		ticket, err := s.ticketRepo.GetByID(ctx, ticketID.String())
-		if err == nil && ticket != nil {
-			if err := s.polygonAccessRepo.Grant(ctx, *polygonID, ticket.ContractorID, "TRIP"); err != nil {
-				s.log.Warn().
-					Err(err).
-					Str("polygon_id", polygonID.String()).
-					Str("contractor_id", ticket.ContractorID.String()).
-					Str("trip_id", trip.ID.String()).
-					Str("ticket_id", ticketID.String()).
-					Msg("failed to grant polygon access for contractor (trip created successfully)")
-			} else {
-				s.log.Info().
-					Str("polygon_id", polygonID.String()).
-					Str("contractor_id", ticket.ContractorID.String()).
-					Str("trip_id", trip.ID.String()).
-					Str("ticket_id", ticketID.String()).
-					Msg("automatically granted polygon access for contractor")
+	if trip.TicketAssignmentID != nil {
+		assignment, err := s.assignmentRepo.GetByID(ctx, trip.TicketAssignmentID.String())
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get assignment for violations: %w", err)
+		}
+		in.Assignment = assignment
+	}
+
+	volume.Apply(trip, strategy)
+
+	findings := s.violationEngine.Evaluate(in).Findings
+
+	resolved := make(map[model.TripStatus]bool)
+	if trip.ID != uuid.Nil {
+		existing, err := s.violationRepo.ListByTripID(ctx, trip.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list trip violations: %w", err)
+		}
+		for _, v := range existing {
+			if v.IsResolved() {
+				resolved[v.Type] = true
 			}
 		}
 	}
 
-	return trip, nil
+	active := make([]violations.Finding, 0, len(findings))
+	for _, f := range findings {
+		if !resolved[f.Status] {
+			active = append(active, f)
+		}
+	}
+
+	result := violations.Summarize(active)
+	trip.Status = result.Status
+	trip.ViolationReason = result.Reason
+	return findings, nil
+}
+
+// syncViolations сохраняет найденные нарушения рейса в trip_violations
+func (s *TripService) syncViolations(ctx context.Context, trip *model.Trip, findings []violations.Finding) error {
+	items := make([]model.TripViolation, 0, len(findings))
+	for _, f := range findings {
+		items = append(items, model.TripViolation{
+			TripID:     trip.ID,
+			Type:       f.Status,
+			Severity:   f.Severity,
+			Reason:     f.Reason,
+			DetectedBy: f.Rule,
+		})
+	}
+	if err := s.violationRepo.Sync(ctx, trip.ID, items); err != nil {
+		return fmt.Errorf("failed to sync trip violations: %w", err)
+	}
+
+	list, err := s.violationRepo.ListByTripID(ctx, trip.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list trip violations: %w", err)
+	}
+	trip.Violations = list
+	return nil
+}
+
+// attachViolations подгружает нарушения для списка рейсов
+func (s *TripService) attachViolations(ctx context.Context, trips []model.Trip) error {
+	ids := make([]uuid.UUID, 0, len(trips))
+	for _, t := range trips {
+		ids = append(ids, t.ID)
+	}
+	byTrip, err := s.violationRepo.ListByTripIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range trips {
+		trips[i].Violations = byTrip[trips[i].ID]
+	}
+	return nil
+}
+
+// contractPolicy возвращает пороги нарушений и стратегию учета объема по умолчанию,
+// переопределенные настройками договора
+func (s *TripService) contractPolicy(ctx context.Context, contractID uuid.UUID) (violations.Thresholds, model.VolumeStrategy, error) {
+	thresholds := s.thresholds
+	strategy := s.volumeStrategy
+
+	settings, err := s.contractSettings.GetByContractID(ctx, contractID)
+	if err != nil {
+		return thresholds, strategy, fmt.Errorf("failed to get contract settings: %w", err)
+	}
+	if settings == nil {
+		return thresholds, strategy, nil
+	}
+
+	if settings.MaxTripVolumeM3 != nil {
+		thresholds.MaxTripVolumeM3 = *settings.MaxTripVolumeM3
+	}
+	if settings.MinTripVolumeM3 != nil {
+		thresholds.MinTripVolumeM3 = *settings.MinTripVolumeM3
+	}
+	if settings.MaxExitVolumeM3 != nil {
+		thresholds.MaxExitVolumeM3 = *settings.MaxExitVolumeM3
+	}
+	if settings.VolumeLimitM3 != nil {
+		thresholds.ContractVolumeLimitM3 = *settings.VolumeLimitM3
+	}
+	if settings.RequirePolygonAccess != nil {
+		thresholds.RequirePolygonAccess = *settings.RequirePolygonAccess
+	}
+	if settings.VolumeStrategy != nil && settings.VolumeStrategy.IsValid() {
+		strategy = *settings.VolumeStrategy
+	}
+	return thresholds, strategy, nil
+}
+
+// findExistingTrip ищет ранее созданный рейс по внешнему идентификатору или LPR событию въезда
+func (s *TripService) findExistingTrip(ctx context.Context, externalID *string, entryLprEventID *uuid.UUID) (*model.Trip, error) {
+	if externalID != nil {
+		trip, err := s.tripRepo.FindByExternalID(ctx, *externalID)
+		if err != nil || trip != nil {
+			return trip, err
+		}
+	}
+	if entryLprEventID != nil {
+		trip, err := s.tripRepo.FindByLprEventID(ctx, *entryLprEventID)
+		if err != nil || trip != nil {
+			return trip, err
+		}
+	}
+	return nil, nil
+}
+
+// MaxTripBatchSize ограничивает количество рейсов в одном пакетном запросе
+const MaxTripBatchSize = 500
+
+// Статусы обработки отдельного рейса в пакетной загрузке
+const (
+	TripIngestStatusCreated      = "created"
+	TripIngestStatusConflict     = "conflict"
+	TripIngestStatusNotFound     = "not_found"
+	TripIngestStatusInvalidInput = "invalid_input"
+	TripIngestStatusError        = "error"
+)
+
+// TripIngestResult содержит созданный рейс и тикет/назначение, к которым он был привязан.
+// Если рейс подошел под несколько назначений одинаково, он остается без привязки,
+// а кандидаты возвращаются в MatchCandidates.
+type TripIngestResult struct {
+	Trip            *model.Trip                `json:"trip"`
+	Ticket          *model.Ticket              `json:"ticket"`
+	Assignment      *model.TicketAssignment    `json:"assignment"`
+	AmbiguousMatch  bool                       `json:"ambiguous_match"`
+	MatchCandidates []AssignmentMatchCandidate `json:"match_candidates,omitempty"`
+}
+
+// TripBatchItemResult результат обработки одного рейса из пакета
+type TripBatchItemResult struct {
+	Index           int                        `json:"index"`
+	Status          string                     `json:"status"`
+	Error           string                     `json:"error,omitempty"`
+	Trip            *model.Trip                `json:"trip,omitempty"`
+	Ticket          *model.Ticket              `json:"ticket,omitempty"`
+	Assignment      *model.TicketAssignment    `json:"assignment,omitempty"`
+	AmbiguousMatch  bool                       `json:"ambiguous_match,omitempty"`
+	MatchCandidates []AssignmentMatchCandidate `json:"match_candidates,omitempty"`
+}
+
+// Ingest создает рейс по данным внешнего сервиса (LPR/volume) и возвращает тикет и назначение,
+// к которым рейс был привязан (если привязка удалась)
+func (s *TripService) Ingest(ctx context.Context, input CreateTripInput) (*TripIngestResult, error) {
+	trip, match, err := s.create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TripIngestResult{Trip: trip}
+	if match != nil && match.Ambiguous {
+		result.AmbiguousMatch = true
+		result.MatchCandidates = match.Candidates
+	}
+
+	if trip.TicketID != nil {
+		ticket, err := s.ticketRepo.GetByID(ctx, trip.TicketID.String())
+		if err != nil {
+			return nil, err
+		}
+		result.Ticket = ticket
+	}
+
+	if trip.TicketAssignmentID != nil {
+		assignment, err := s.assignmentRepo.GetByID(ctx, trip.TicketAssignmentID.String())
+		if err != nil {
+			return nil, err
+		}
+		result.Assignment = assignment
+	}
+
+	return result, nil
+}
+
+// IngestBatch создает рейсы по одному, не прерываясь на ошибках отдельных элементов.
+// Ошибки ErrConflict/ErrNotFound/ErrInvalidInput отражаются в статусе элемента.
+func (s *TripService) IngestBatch(ctx context.Context, inputs []CreateTripInput) ([]TripBatchItemResult, error) {
+	if len(inputs) == 0 || len(inputs) > MaxTripBatchSize {
+		return nil, ErrInvalidInput
+	}
+
+	results := make([]TripBatchItemResult, 0, len(inputs))
+	for i, input := range inputs {
+		item := TripBatchItemResult{Index: i}
+
+		res, err := s.Ingest(ctx, input)
+		switch {
+		case err == nil:
+			item.Status = TripIngestStatusCreated
+			item.Trip = res.Trip
+			item.Ticket = res.Ticket
+			item.Assignment = res.Assignment
+			item.AmbiguousMatch = res.AmbiguousMatch
+			item.MatchCandidates = res.MatchCandidates
+		case errors.Is(err, ErrConflict):
+			item.Status = TripIngestStatusConflict
+			item.Error = err.Error()
+		case errors.Is(err, ErrNotFound):
+			item.Status = TripIngestStatusNotFound
+			item.Error = err.Error()
+		case errors.Is(err, ErrInvalidInput):
+			item.Status = TripIngestStatusInvalidInput
+			item.Error = err.Error()
+		default:
+			s.log.Error().
+				Err(err).
+				Int("index", i).
+				Msg("failed to ingest trip from batch")
+			item.Status = TripIngestStatusError
+			item.Error = "internal error"
+		}
+
+		results = append(results, item)
+	}
+
+	return results, nil
 }
 
 func (s *TripService) ListByTicketID(ctx context.Context, principal model.Principal, ticketID string) ([]model.Trip, error) {
@@ -327,12 +736,26 @@ func (s *TripService) ListByTicketID(ctx context.Context, principal model.Princi
 			return nil, ErrPermissionDenied
 		}
 		// Водитель видит только свои рейсы
-		return s.tripRepo.ListByDriverID(ctx, *principal.DriverID, &ticket.ID)
+		trips, err := s.tripRepo.ListByDriverID(ctx, *principal.DriverID, &ticket.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.attachViolations(ctx, trips); err != nil {
+			return nil, err
+		}
+		return trips, nil
 	} else {
 		return nil, ErrPermissionDenied
 	}
 
-	return s.tripRepo.ListByTicketID(ctx, ticket.ID)
+	trips, err := s.tripRepo.ListByTicketID(ctx, ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachViolations(ctx, trips); err != nil {
+		return nil, err
+	}
+	return trips, nil
 }
 
 func (s *TripService) GetByID(ctx context.Context, principal model.Principal, id string) (*model.Trip, error) {
@@ -370,6 +793,12 @@ func (s *TripService) GetByID(ctx context.Context, principal model.Principal, id
 		}
 	}
 
+	tripViolations, err := s.violationRepo.ListByTripID(ctx, trip.ID)
+	if err != nil {
+		return nil, err
+	}
+	trip.Violations = tripViolations
+
 	return trip, nil
 }
 
@@ -408,9 +837,23 @@ func (s *TripService) GetReceptionJournal(ctx context.Context, principal model.P
 		return nil, err
 	}
 
+	tripIDs := make([]uuid.UUID, 0, len(entries))
+	for _, entry := range entries {
+		tripIDs = append(tripIDs, entry.TripID)
+	}
+	violationsByTrip, err := s.violationRepo.ListByTripIDs(ctx, tripIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Violations = violationsByTrip[entries[i].TripID]
+	}
+
 	var totalVolume float64
 	for _, entry := range entries {
-		totalVolume += entry.NetVolumeM3
+		if entry.AcceptedVolumeM3 != nil {
+			totalVolume += *entry.AcceptedVolumeM3
+		}
 	}
 
 	return &ReceptionJournalResult{
@@ -420,59 +863,67 @@ func (s *TripService) GetReceptionJournal(ctx context.Context, principal model.P
 	}, nil
 }
 
-// CalculateVolumeForAssignment вычисляет объем перевезенного снега для назначения
-// Получает события ANPR за период рейса и суммирует объемы всех событий въезда
+// CalculateVolumeForTrip вычисляет объем перевезенного снега за рейс trip машины назначения
+// Получает события ANPR за период рейса (entry_at - exit_at) и суммирует объемы всех событий въезда
 // Принимает уже полученное assignment, чтобы избежать дублирования запросов
-func (s *TripService) CalculateVolumeForAssignment(ctx context.Context, assignment *model.TicketAssignment) (float64, error) {
-	// Проверяем, что есть временные метки
-	if assignment.TripStartedAt == nil {
-		return 0, fmt.Errorf("trip not started (trip_started_at is nil)")
+func (s *TripService) CalculateVolumeForTrip(ctx context.Context, assignment *model.TicketAssignment, trip *model.Trip) (float64, error) {
+	// Проверяем, что рейс завершен
+	if trip.ExitAt == nil {
+		return 0, fmt.Errorf("%w: trip not finished (exit_at is nil)", ErrInvalidInput)
 	}
-	if assignment.TripFinishedAt == nil {
-		return 0, fmt.Errorf("trip not finished (trip_finished_at is nil)")
+	startedAt, finishedAt := trip.EntryAt, *trip.ExitAt
+
+	// Машина рейса: после замены машины назначения прежние рейсы считаются по прежней машине
+	vehicleID := assignment.VehicleID
+	if trip.VehicleID != nil {
+		vehicleID = *trip.VehicleID
 	}
 
 	// Получаем номер машины
-	plateNumber, err := s.assignmentRepo.GetVehiclePlateNumber(ctx, assignment.VehicleID)
+	plateNumber, err := s.assignmentRepo.GetVehiclePlateNumber(ctx, vehicleID)
 	if err != nil {
 		s.log.Warn().
 			Err(err).
 			Str("assignment_id", assignment.ID.String()).
-			Str("vehicle_id", assignment.VehicleID.String()).
+			Str("vehicle_id", vehicleID.String()).
 			Msg("failed to get vehicle plate number")
 		return 0, fmt.Errorf("failed to get vehicle plate number: %w", err)
 	}
 
-	// Нормализуем номер
-	normalizedPlate := utils.NormalizePlate(plateNumber)
+	// Приводим номер к каноническому виду (кириллица/латиница, O/0, B/8, I/1)
+	normalizedPlate, _ := plate.Canonical(plateNumber)
 	if normalizedPlate == "" {
-		return 0, fmt.Errorf("invalid plate number format")
-	}
-
-	// Запрашиваем события ANPR за период рейса (только въезды)
-	direction := "entry"
-	events, err := s.anprClient.GetEventsByPlateAndTime(
-		ctx,
-		normalizedPlate,
-		*assignment.TripStartedAt,
-		*assignment.TripFinishedAt,
-		&direction,
-	)
+		return 0, fmt.Errorf("%w: invalid plate number format", ErrInvalidInput)
+	}
+
+	// События во время пауз смены (заправка, поломка, перерыв) в объем рейса не входят
+	statusEvents, err := s.assignmentRepo.ListStatusEvents(ctx, []uuid.UUID{assignment.ID})
 	if err != nil {
-		s.log.Error().
-			Err(err).
-			Str("assignment_id", assignment.ID.String()).
-			Str("plate", normalizedPlate).
-			Time("start", *assignment.TripStartedAt).
-			Time("end", *assignment.TripFinishedAt).
-			Msg("failed to get ANPR events")
-		return 0, fmt.Errorf("failed to get ANPR events: %w", err)
+		return 0, fmt.Errorf("failed to get driver status events: %w", err)
 	}
+	paused := pausedIntervals(statusEvents, finishedAt)
 
-	// Суммируем объемы всех событий въезда
+	// Суммируем объемы событий въезда за период рейса по мере загрузки страниц ANPR
 	var totalVolume float64
 	eventCount := 0
-	for _, event := range events {
+	totalEvents := 0
+	pausedEvents := 0
+	for event, err := range s.anprEventsForPlate(ctx, normalizedPlate, startedAt, finishedAt, "entry") {
+		if err != nil {
+			s.log.Error().
+				Err(err).
+				Str("assignment_id", assignment.ID.String()).
+				Str("plate", normalizedPlate).
+				Time("start", startedAt).
+				Time("end", finishedAt).
+				Msg("failed to get ANPR events")
+			return 0, fmt.Errorf("failed to get ANPR events: %w", err)
+		}
+		if duringPause(paused, event.EventTime) {
+			pausedEvents++
+			continue
+		}
+		totalEvents++
 		if event.SnowVolumeM3 != nil {
 			totalVolume += *event.SnowVolumeM3
 			eventCount++
@@ -481,125 +932,170 @@ func (s *TripService) CalculateVolumeForAssignment(ctx context.Context, assignme
 
 	s.log.Info().
 		Str("assignment_id", assignment.ID.String()).
+		Str("trip_id", trip.ID.String()).
 		Str("plate", normalizedPlate).
 		Float64("total_volume_m3", totalVolume).
 		Int("events_count", eventCount).
-		Int("total_events", len(events)).
-		Msg("calculated volume for assignment")
+		Int("total_events", totalEvents).
+		Int("paused_events", pausedEvents).
+		Msg("calculated volume for trip")
 
-	if totalVolume == 0 && len(events) > 0 {
+	if totalVolume == 0 && totalEvents > 0 {
 		s.log.Warn().
 			Str("assignment_id", assignment.ID.String()).
 			Str("plate", normalizedPlate).
-			Int("events_count", len(events)).
+			Int("events_count", totalEvents).
 			Msg("trip completed with zero volume (all events have nil or zero volume)")
 	}
 
 	return totalVolume, nil
 }
 
-// CompleteTripAndCalculateVolume завершает рейс, рассчитывает объем и создает/обновляет запись trip
-// Вызывается после того, как trip_finished_at уже установлен в AssignmentService
-func (s *TripService) CompleteTripAndCalculateVolume(ctx context.Context, assignmentID uuid.UUID) (*model.Trip, error) {
-	// Получаем назначение (оно уже обновлено с trip_finished_at)
-	assignment, err := s.assignmentRepo.GetByID(ctx, assignmentID.String())
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotFound
+// maxANPRPlateVariants ограничивает число написаний номера, по которым запрашивается ANPR:
+// каждое написание - отдельная серия запросов к сервису
+const maxANPRPlateVariants = 4
+
+// anprEventsForPlate перебирает события ANPR по написаниям номера, которые камера могла
+// распознать с ошибкой, и оставляет только события, номер которых совпадает с искомым.
+// Запрашиваются канонический номер и первые замены из plate.Variants, не больше maxANPRPlateVariants.
+// Для дедупликации в памяти хранятся только ID событий.
+func (s *TripService) anprEventsForPlate(ctx context.Context, plateNumber string, from, to time.Time, direction string) iter.Seq2[client.ANPREvent, error] {
+	return func(yield func(client.ANPREvent, error) bool) {
+		variants := plate.Variants(plateNumber)
+		if len(variants) > maxANPRPlateVariants {
+			variants = variants[:maxANPRPlateVariants]
+		}
+		seen := make(map[string]struct{})
+		for _, variant := range variants {
+			for event, err := range s.anprSource.Events(ctx, variant, from, to, &direction) {
+				if err != nil {
+					yield(client.ANPREvent{}, err)
+					return
+				}
+				if _, ok := seen[event.ID]; ok {
+					continue
+				}
+				seen[event.ID] = struct{}{}
+				if event.NormalizedPlate != "" && !plate.Matches(event.NormalizedPlate, plateNumber) {
+					continue
+				}
+				if !yield(event, nil) {
+					return
+				}
+			}
 		}
-		return nil, fmt.Errorf("failed to get assignment: %w", err)
-	}
-
-	// Проверяем, что рейс был начат и завершен
-	if assignment.TripStartedAt == nil {
-		return nil, fmt.Errorf("trip not started")
-	}
-	if assignment.TripFinishedAt == nil {
-		return nil, fmt.Errorf("trip not finished (trip_finished_at is nil)")
 	}
+}
 
-	// Рассчитываем объем (передаем уже полученное assignment)
-	totalVolume, err := s.CalculateVolumeForAssignment(ctx, assignment)
-	if err != nil {
-		s.log.Error().
-			Err(err).
-			Str("assignment_id", assignmentID.String()).
-			Msg("failed to calculate volume, completing trip with volume 0")
-		// Продолжаем выполнение, но с объемом 0
-		// Это нормальная ситуация - если ANPR недоступен или события отсутствуют
-		totalVolume = 0
+// CompleteRound создает trip по завершенному рейсу назначения и ставит расчет объема в очередь.
+// Объем рассчитывается асинхронно (см. HandleVolumeJob), до этого у рейса
+// volume_calculation_status = PENDING. Рейс и задача расчета сохраняются одной транзакцией,
+// чтобы рейс не остался в PENDING без задачи. Если trip по этому рейсу уже создан (повторный вызов),
+// он обновляется.
+func (s *TripService) CompleteRound(ctx context.Context, assignment *model.TicketAssignment, round *model.AssignmentRound) (*model.Trip, error) {
+	if round.FinishedAt == nil {
+		return nil, fmt.Errorf("round %d not finished (finished_at is nil)", round.Number)
 	}
 
-	// Получаем номер машины для записи в trip
-	plateNumber, err := s.assignmentRepo.GetVehiclePlateNumber(ctx, assignment.VehicleID)
-	if err != nil {
-		s.log.Warn().
-			Err(err).
-			Str("assignment_id", assignmentID.String()).
-			Msg("failed to get vehicle plate number for trip, using empty string")
-		plateNumber = ""
-	}
-	normalizedPlate := utils.NormalizePlate(plateNumber)
+	pending := model.VolumeCalculationPending
 
-	// Ищем существующий trip для этого назначения
-	existingTrip, err := s.tripRepo.FindByAssignmentID(ctx, assignmentID)
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, fmt.Errorf("failed to find existing trip: %w", err)
+	var trip *model.Trip
+	if round.TripID != nil {
+		existing, err := s.tripRepo.GetByID(ctx, round.TripID.String())
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to find round trip: %w", err)
+		}
+		trip = existing
 	}
 
-	if existingTrip != nil {
+	if trip != nil {
 		// Обновляем существующий trip
-		existingTrip.ExitAt = assignment.TripFinishedAt
-		existingTrip.TotalVolumeM3 = &totalVolume
-		existingTrip.Status = model.TripStatusOK
-		existingTrip.AutoCreated = true
+		trip.ExitAt = round.FinishedAt
+		trip.AutoCreated = true
+		trip.VolumeCalculationStatus = &pending
+		trip.VolumeCalculationError = nil
+
+		job, err := s.newVolumeJob(trip)
+		if err != nil {
+			return nil, err
+		}
 
-		if err := s.tripRepo.Update(ctx, existingTrip); err != nil {
+		findings, err := s.evaluateViolations(ctx, trip)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.tripRepo.UpdateWithJob(ctx, trip, job); err != nil {
 			return nil, fmt.Errorf("failed to update trip: %w", err)
 		}
+		if err := s.syncViolations(ctx, trip, findings); err != nil {
+			return nil, err
+		}
+	} else {
+		// Получаем номер машины для записи в trip
+		plateNumber, err := s.assignmentRepo.GetVehiclePlateNumber(ctx, assignment.VehicleID)
+		if err != nil {
+			s.log.Warn().
+				Err(err).
+				Str("assignment_id", assignment.ID.String()).
+				Msg("failed to get vehicle plate number for trip, using empty string")
+			plateNumber = ""
+		}
+		normalizedPlate, _ := plate.Canonical(plateNumber)
+
+		// Создаем новый trip; ID задается заранее, он нужен задаче расчета объема
+		assignmentID := assignment.ID
+		trip = &model.Trip{
+			ID:                      uuid.New(),
+			TicketID:                &assignment.TicketID,
+			TicketAssignmentID:      &assignmentID,
+			DriverID:                &assignment.DriverID,
+			VehicleID:               &assignment.VehicleID,
+			VehiclePlateNumber:      normalizedPlate,
+			EntryAt:                 round.StartedAt,
+			ExitAt:                  round.FinishedAt,
+			AutoCreated:             true,
+			VolumeCalculationStatus: &pending,
+		}
 
-		s.log.Info().
-			Str("trip_id", existingTrip.ID.String()).
-			Str("assignment_id", assignmentID.String()).
-			Float64("total_volume_m3", totalVolume).
-			Msg("updated existing trip with calculated volume")
+		job, err := s.newVolumeJob(trip)
+		if err != nil {
+			return nil, err
+		}
 
-		return existingTrip, nil
-	}
+		findings, err := s.evaluateViolations(ctx, trip)
+		if err != nil {
+			return nil, err
+		}
 
-	// Создаем новый trip
-	trip := &model.Trip{
-		TicketID:           &assignment.TicketID,
-		TicketAssignmentID: &assignmentID,
-		DriverID:           &assignment.DriverID,
-		VehicleID:          &assignment.VehicleID,
-		VehiclePlateNumber: normalizedPlate,
-		EntryAt:            *assignment.TripStartedAt,
-		ExitAt:             assignment.TripFinishedAt,
-		TotalVolumeM3:      &totalVolume,
-		AutoCreated:        true,
-		Status:             model.TripStatusOK,
-	}
+		if err := s.tripRepo.CreateWithJob(ctx, trip, job); err != nil {
+			return nil, fmt.Errorf("failed to create trip: %w", err)
+		}
 
-	if err := s.tripRepo.Create(ctx, trip); err != nil {
-		return nil, fmt.Errorf("failed to create trip: %w", err)
-	}
+		if err := s.assignmentRepo.SetRoundTrip(ctx, round.ID, trip.ID); err != nil {
+			return nil, fmt.Errorf("failed to link trip to round: %w", err)
+		}
+		round.TripID = &trip.ID
 
-	s.log.Info().
-		Str("trip_id", trip.ID.String()).
-		Str("assignment_id", assignmentID.String()).
-		Float64("total_volume_m3", totalVolume).
-		Msg("created new trip with calculated volume")
+		if err := s.syncViolations(ctx, trip, findings); err != nil {
+			return nil, err
+		}
 
-	// Автоматический переход статуса тикета при создании рейса
-	if s.ticketService != nil {
-		if err := s.ticketService.OnTripCreated(ctx, assignment.TicketID); err != nil {
-			s.log.Warn().
-				Err(err).
-				Str("ticket_id", assignment.TicketID.String()).
-				Msg("failed to update ticket status on trip creation")
+		// Автоматический переход статуса тикета при создании рейса
+		if s.ticketService != nil {
+			if err := s.ticketService.OnTripCreated(ctx, assignment.TicketID); err != nil {
+				s.log.Warn().
+					Err(err).
+					Str("ticket_id", assignment.TicketID.String()).
+					Msg("failed to update ticket status on trip creation")
+			}
 		}
 	}
 
+	s.log.Info().
+		Str("trip_id", trip.ID.String()).
+		Str("assignment_id", assignment.ID.String()).
+		Int("round", round.Number).
+		Msg("trip completed, volume calculation queued")
+
 	return trip, nil
 }
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/jobs"
+	"ticket-service/internal/model"
+)
+
+// VolumeJobPayload - данные задачи расчета объема рейса.
+// Задачи, поставленные до появления рейсов назначения, содержат только AssignmentID.
+type VolumeJobPayload struct {
+	AssignmentID uuid.UUID `json:"assignment_id"`
+	TripID       uuid.UUID `json:"trip_id"`
+}
+
+// EnqueueVolumeCalculation ставит расчет объема рейса в очередь.
+// Повторная постановка, пока предыдущая задача не выполнена, ничего не делает и возвращает false.
+func (s *TripService) EnqueueVolumeCalculation(ctx context.Context, trip *model.Trip) (bool, error) {
+	job, err := s.newVolumeJob(trip)
+	if err != nil {
+		return false, err
+	}
+	queued, err := s.jobQueue.Add(ctx, job)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue volume calculation: %w", err)
+	}
+	return queued, nil
+}
+
+// newVolumeJob собирает задачу расчета объема рейса; у нового рейса ID должен быть уже заполнен
+func (s *TripService) newVolumeJob(trip *model.Trip) (*model.Job, error) {
+	if s.jobQueue == nil {
+		return nil, fmt.Errorf("job queue is not configured")
+	}
+	if trip.TicketAssignmentID == nil {
+		return nil, fmt.Errorf("%w: trip has no assignment", ErrInvalidInput)
+	}
+	payload := VolumeJobPayload{AssignmentID: *trip.TicketAssignmentID, TripID: trip.ID}
+	return s.jobQueue.NewJob(model.JobTypeTripVolume, trip.ID.String(), payload)
+}
+
+// HandleVolumeJob рассчитывает объем по событиям ANPR и сохраняет его в рейсе назначения.
+// Ошибка ANPR возвращается как есть, чтобы задача была повторена.
+func (s *TripService) HandleVolumeJob(ctx context.Context, job *model.Job) error {
+	var payload VolumeJobPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+
+	trip, err := s.volumeJobTrip(ctx, payload)
+	if err != nil {
+		return err
+	}
+	if trip == nil {
+		return jobs.Permanent(fmt.Errorf("trip for volume job %s not found", job.ID))
+	}
+
+	result, err := s.RecalculateTripVolume(ctx, trip, true)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) || errors.Is(err, ErrNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	s.log.Info().
+		Str("trip_id", trip.ID.String()).
+		Str("assignment_id", payload.AssignmentID.String()).
+		Float64("total_volume_m3", result.NewVolumeM3).
+		Int("attempt", job.Attempts).
+		Msg("updated trip with calculated volume")
+
+	return nil
+}
+
+// OnVolumeJobDead отмечает рейс как FAILED, когда расчет объема исчерпал попытки.
+// Меняются только поля состояния расчета: объем и нарушения рейса при этом не пересчитываются.
+func (s *TripService) OnVolumeJobDead(ctx context.Context, job *model.Job, jobErr error) {
+	var payload VolumeJobPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return
+	}
+
+	trip, err := s.volumeJobTrip(ctx, payload)
+	if err != nil || trip == nil {
+		return
+	}
+
+	reason := jobErr.Error()
+	if err := s.tripRepo.SetVolumeCalculationStatus(ctx, trip.ID, model.VolumeCalculationFailed, &reason); err != nil {
+		s.log.Error().
+			Err(err).
+			Str("trip_id", trip.ID.String()).
+			Msg("failed to mark trip volume calculation as failed")
+	}
+}
+
+// volumeJobTrip находит рейс задачи расчета объема; nil - рейс не найден
+func (s *TripService) volumeJobTrip(ctx context.Context, payload VolumeJobPayload) (*model.Trip, error) {
+	if payload.TripID == uuid.Nil {
+		return s.tripRepo.FindByAssignmentID(ctx, payload.AssignmentID)
+	}
+	trip, err := s.tripRepo.GetByID(ctx, payload.TripID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return trip, err
+}
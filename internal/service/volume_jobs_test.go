@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"ticket-service/internal/anprfake"
+	"ticket-service/internal/client"
+	"ticket-service/internal/config"
+	"ticket-service/internal/jobs"
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+	"ticket-service/internal/violations"
+)
+
+// volumeFixture - назначение машины 123ABC02 и рейс по fixture-событиям cmd/anpr-fake/fixtures
+type volumeFixture struct {
+	service *TripService
+	mock    sqlmock.Sqlmock
+	anpr    *anprfake.Server
+
+	assignment *model.TicketAssignment
+	trip       *model.Trip
+}
+
+func newVolumeFixture(t *testing.T) *volumeFixture {
+	t.Helper()
+
+	events, err := anprfake.LoadFixtures("../../cmd/anpr-fake/fixtures")
+	if err != nil {
+		t.Fatalf("load fixtures: %v", err)
+	}
+	fake := anprfake.New(events, "")
+	server := httptest.NewServer(fake.Handler())
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.ExternalServices.ANPRServiceURL = server.URL
+	cfg.ExternalServices.ANPRTimeout = 2 * time.Second
+	cfg.ExternalServices.ANPRMaxAttempts = 3
+	cfg.ExternalServices.ANPRRetryBaseDelay = time.Millisecond
+	cfg.ExternalServices.ANPRRetryMaxDelay = 5 * time.Millisecond
+	cfg.ExternalServices.ANPRPageSize = 1
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+
+	service := NewTripService(
+		repository.NewTripRepository(db),
+		repository.NewTicketRepository(db),
+		repository.NewAssignmentRepository(db),
+		nil,
+		client.NewANPRClient(cfg),
+		repository.NewPolygonAccessRepository(db),
+		repository.NewContractSettingsRepository(db),
+		repository.NewTripViolationRepository(db),
+		jobs.NewQueue(repository.NewJobRepository(db), 3),
+		// Без правил: тест проверяет расчет объема, а не нарушения
+		violations.NewEngine(),
+		violations.Thresholds{},
+		model.VolumeStrategyANPREntrySum,
+		zerolog.Nop(),
+	)
+
+	assignment := &model.TicketAssignment{
+		ID:        uuid.New(),
+		TicketID:  uuid.New(),
+		DriverID:  uuid.New(),
+		VehicleID: uuid.New(),
+		IsActive:  true,
+	}
+	entryAt := time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)
+	exitAt := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	pending := model.VolumeCalculationPending
+	trip := &model.Trip{
+		ID:                      uuid.New(),
+		TicketAssignmentID:      &assignment.ID,
+		DriverID:                &assignment.DriverID,
+		VehicleID:               &assignment.VehicleID,
+		EntryAt:                 entryAt,
+		ExitAt:                  &exitAt,
+		AutoCreated:             true,
+		VolumeCalculationStatus: &pending,
+	}
+
+	return &volumeFixture{service: service, mock: mock, anpr: fake, assignment: assignment, trip: trip}
+}
+
+// expectVolumeQueries - запросы CalculateVolumeForTrip: номер машины и журнал статусов водителя
+func (f *volumeFixture) expectVolumeQueries() {
+	f.mock.ExpectQuery(`SELECT "plate_number" FROM "vehicles"`).
+		WillReturnRows(sqlmock.NewRows([]string{"plate_number"}).AddRow("123 ABC 02"))
+	f.mock.ExpectQuery(`SELECT \* FROM "driver_status_events"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func (f *volumeFixture) expectTripByID() {
+	rows := sqlmock.NewRows([]string{"id", "ticket_assignment_id", "driver_id", "vehicle_id", "entry_at", "exit_at", "auto_created", "volume_calculation_status", "status"}).
+		AddRow(f.trip.ID, f.assignment.ID, f.assignment.DriverID, f.assignment.VehicleID, f.trip.EntryAt, *f.trip.ExitAt, true, string(*f.trip.VolumeCalculationStatus), string(model.TripStatusOK))
+	f.mock.ExpectQuery(`SELECT \* FROM "trips"`).WillReturnRows(rows)
+}
+
+func (f *volumeFixture) expectAssignmentByID() {
+	rows := sqlmock.NewRows([]string{"id", "ticket_id", "driver_id", "vehicle_id", "is_active"}).
+		AddRow(f.assignment.ID, f.assignment.TicketID, f.assignment.DriverID, f.assignment.VehicleID, true)
+	f.mock.ExpectQuery(`SELECT \* FROM "ticket_assignments"`).WillReturnRows(rows)
+}
+
+func (f *volumeFixture) volumeJob(t *testing.T) *model.Job {
+	t.Helper()
+	payload, err := json.Marshal(VolumeJobPayload{AssignmentID: f.assignment.ID, TripID: f.trip.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &model.Job{ID: uuid.New(), Type: model.JobTypeTripVolume, Payload: string(payload), Attempts: 1}
+}
+
+func TestCalculateVolumeForTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		fault      anprfake.Fault
+		late       []client.ANPREvent
+		wantVolume float64
+		wantErr    bool
+	}{
+		{
+			// 14.5 + 15.2 и событие 13.9, номер которого камера прочитала как 123ABCO2;
+			// событие выезда не учитывается
+			name:       "sums entry events including misread plates",
+			wantVolume: 43.6,
+		},
+		{
+			name:       "recovers after transient ANPR failures",
+			fault:      anprfake.Fault{FailNext: 2},
+			wantVolume: 43.6,
+		},
+		{
+			name: "includes late events",
+			late: []client.ANPREvent{{
+				ID:              "late-1",
+				NormalizedPlate: "123ABC02",
+				EventTime:       time.Date(2025, 1, 15, 11, 55, 0, 0, time.UTC),
+				Direction:       ptr("entry"),
+				SnowVolumeM3:    ptr(6.4),
+			}},
+			wantVolume: 50,
+		},
+		{
+			name:    "fails when ANPR stays unavailable",
+			fault:   anprfake.Fault{FailNext: 10},
+			wantErr: true,
+		},
+		{
+			name:    "fails on ANPR client error",
+			fault:   anprfake.Fault{FailNext: 1, ErrorStatus: http.StatusBadRequest},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newVolumeFixture(t)
+			f.anpr.SetFault(tt.fault)
+			f.anpr.AddEvents(tt.late...)
+			f.expectVolumeQueries()
+
+			volume, err := f.service.CalculateVolumeForTrip(context.Background(), f.assignment, f.trip)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got volume %v", volume)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if math.Abs(volume-tt.wantVolume) > 1e-9 {
+					t.Errorf("got volume %v, want %v", volume, tt.wantVolume)
+				}
+			}
+			if err := f.mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCalculateVolumeForUnfinishedTrip(t *testing.T) {
+	f := newVolumeFixture(t)
+	f.trip.ExitAt = nil
+
+	_, err := f.service.CalculateVolumeForTrip(context.Background(), f.assignment, f.trip)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("got error %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestHandleVolumeJob(t *testing.T) {
+	f := newVolumeFixture(t)
+	f.expectTripByID()
+	f.expectAssignmentByID()
+	f.expectVolumeQueries()
+
+	// Сохранение рейса через TripService.Update: пересчет нарушений и запись объема
+	f.expectAssignmentByID()
+	f.mock.ExpectQuery(`SELECT \* FROM "trip_violations"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	f.mock.ExpectBegin()
+	f.mock.ExpectExec(`UPDATE "trips" SET .*"total_volume_m3"=\$\d+.*"volume_calculation_status"=\$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	f.mock.ExpectCommit()
+	f.mock.ExpectBegin()
+	f.mock.ExpectQuery(`SELECT \* FROM "trip_violations"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	f.mock.ExpectCommit()
+	f.mock.ExpectQuery(`SELECT \* FROM "trip_violations"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if err := f.service.HandleVolumeJob(context.Background(), f.volumeJob(t)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandleVolumeJobANPRUnavailable(t *testing.T) {
+	f := newVolumeFixture(t)
+	f.anpr.SetFault(anprfake.Fault{FailNext: 10})
+	f.expectTripByID()
+	f.expectAssignmentByID()
+	f.expectVolumeQueries()
+
+	err := f.service.HandleVolumeJob(context.Background(), f.volumeJob(t))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// Задача повторяется очередью, рейс остается в PENDING
+	if jobs.IsPermanent(err) {
+		t.Errorf("got permanent error %v, want retryable", err)
+	}
+	var statusErr *client.ANPRStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got error %v, want ANPR status 503", err)
+	}
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandleVolumeJobInvalidPayload(t *testing.T) {
+	f := newVolumeFixture(t)
+
+	err := f.service.HandleVolumeJob(context.Background(), &model.Job{ID: uuid.New(), Payload: "not json"})
+	if !jobs.IsPermanent(err) {
+		t.Errorf("got error %v, want permanent", err)
+	}
+}
+
+func TestRequestVolumeRecalculation(t *testing.T) {
+	principal := model.Principal{UserID: uuid.New(), Role: model.UserRoleAkimatAdmin}
+	input := RecalculateVolumeInput{DateFrom: ptr("2025-01-01T00:00:00Z"), DateTo: ptr("2025-01-31T00:00:00Z")}
+	tripRows := func(f *volumeFixture, n int) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "ticket_assignment_id", "entry_at", "auto_created"})
+		for i := 0; i < n; i++ {
+			rows.AddRow(uuid.New(), f.assignment.ID, f.trip.EntryAt, true)
+		}
+		return rows
+	}
+
+	t.Run("enqueues in one transaction", func(t *testing.T) {
+		f := newVolumeFixture(t)
+		f.mock.ExpectQuery(`SELECT tr\.\* FROM trips tr .* LIMIT \$\d+`).
+			WillReturnRows(tripRows(f, 2))
+		f.mock.ExpectBegin()
+		f.mock.ExpectQuery(`INSERT INTO "jobs" .* ON CONFLICT DO NOTHING`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		// Задача по второму рейсу уже ожидает выполнения
+		f.mock.ExpectQuery(`INSERT INTO "jobs" .* ON CONFLICT DO NOTHING`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		f.mock.ExpectExec(`UPDATE "trips" SET .*"volume_calculation_status"=\$\d+.*WHERE id IN`).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		f.mock.ExpectCommit()
+
+		result, err := f.service.RequestVolumeRecalculation(context.Background(), principal, input)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Queued != 1 || result.AlreadyQueued != 1 || len(result.TripIDs) != 2 {
+			t.Errorf("got queued %d, already queued %d, trips %d; want 1, 1, 2", result.Queued, result.AlreadyQueued, len(result.TripIDs))
+		}
+		if err := f.mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("rejects too many trips", func(t *testing.T) {
+		f := newVolumeFixture(t)
+		f.mock.ExpectQuery(`SELECT tr\.\* FROM trips tr .* LIMIT \$\d+`).
+			WillReturnRows(tripRows(f, maxRecalculationTrips+1))
+
+		_, err := f.service.RequestVolumeRecalculation(context.Background(), principal, input)
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("got error %v, want ErrInvalidInput", err)
+		}
+		// Ни одна задача не поставлена
+		if err := f.mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/repository"
+)
+
+const (
+	// maxRecalculationRange - максимальный период пересчета объема одним запросом
+	maxRecalculationRange = 31 * 24 * time.Hour
+	// maxRecalculationTrips - максимальное количество рейсов в одном запросе пересчета;
+	// для больших объемов есть команда backfill-volume
+	maxRecalculationTrips = 1000
+)
+
+// RecalculateVolumeInput - что пересчитать: одно назначение, тикет или период (по entry_at рейса).
+// Указывается ровно один вариант.
+type RecalculateVolumeInput struct {
+	AssignmentID *string
+	TicketID     *string
+	DateFrom     *string
+	DateTo       *string
+}
+
+type RecalculateVolumeResult struct {
+	// Queued - количество рейсов, поставленных в очередь на пересчет
+	Queued int `json:"queued"`
+	// AlreadyQueued - рейсы, пересчет которых уже ожидает выполнения
+	AlreadyQueued int         `json:"already_queued"`
+	TripIDs       []uuid.UUID `json:"trip_ids"`
+}
+
+// VolumeRecalculation - результат пересчета объема одного рейса
+type VolumeRecalculation struct {
+	TripID       uuid.UUID
+	AssignmentID uuid.UUID
+	TicketID     *uuid.UUID
+	EntryAt      time.Time
+	OldVolumeM3  *float64
+	NewVolumeM3  float64
+}
+
+// Changed проверяет, что пересчет изменил объем рейса
+func (r VolumeRecalculation) Changed() bool {
+	return r.OldVolumeM3 == nil || *r.OldVolumeM3 != r.NewVolumeM3
+}
+
+// RequestVolumeRecalculation ставит в очередь пересчет объема автоматически созданных рейсов,
+// например после того как в ANPR появились опоздавшие или исправленные события
+func (s *TripService) RequestVolumeRecalculation(ctx context.Context, principal model.Principal, input RecalculateVolumeInput) (*RecalculateVolumeResult, error) {
+	if !principal.IsKgu() && !principal.IsAkimat() {
+		return nil, ErrPermissionDenied
+	}
+
+	filter, err := s.recalculationFilter(ctx, principal, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Лишний рейс сверх лимита показывает, что запрос слишком широкий
+	filter.Limit = maxRecalculationTrips + 1
+	trips, err := s.tripRepo.ListForVolumeRecalculation(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if filter.AssignmentID != nil && len(trips) == 0 {
+		return nil, ErrNotFound
+	}
+	if len(trips) > maxRecalculationTrips {
+		return nil, fmt.Errorf("%w: more than %d trips to recalculate, narrow the period or use backfill-volume", ErrInvalidInput, maxRecalculationTrips)
+	}
+
+	result := &RecalculateVolumeResult{TripIDs: make([]uuid.UUID, 0, len(trips))}
+	volumeJobs := make([]*model.Job, 0, len(trips))
+	for i := range trips {
+		job, err := s.newVolumeJob(&trips[i])
+		if err != nil {
+			return nil, err
+		}
+		volumeJobs = append(volumeJobs, job)
+		result.TripIDs = append(result.TripIDs, trips[i].ID)
+	}
+
+	// Задачи и статус PENDING сохраняются одной транзакцией: пакет не может остаться поставленным наполовину
+	queued, err := s.tripRepo.MarkVolumePendingWithJobs(ctx, result.TripIDs, volumeJobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue volume calculation: %w", err)
+	}
+	result.Queued = queued
+	result.AlreadyQueued = len(volumeJobs) - queued
+
+	s.log.Info().
+		Str("user_id", principal.UserID.String()).
+		Int("queued", result.Queued).
+		Int("already_queued", result.AlreadyQueued).
+		Msg("volume recalculation requested")
+
+	return result, nil
+}
+
+func (s *TripService) recalculationFilter(ctx context.Context, principal model.Principal, input RecalculateVolumeInput) (repository.VolumeRecalculationFilter, error) {
+	var filter repository.VolumeRecalculationFilter
+
+	variants := 0
+	if input.AssignmentID != nil {
+		variants++
+	}
+	if input.TicketID != nil {
+		variants++
+	}
+	if input.DateFrom != nil || input.DateTo != nil {
+		variants++
+	}
+	if variants != 1 {
+		return filter, ErrInvalidInput
+	}
+
+	// KGU пересчитывает только рейсы своих тикетов
+	if principal.IsKgu() {
+		orgID := principal.OrgID
+		filter.CreatedByOrgID = &orgID
+	}
+
+	switch {
+	case input.AssignmentID != nil:
+		id, err := uuid.Parse(*input.AssignmentID)
+		if err != nil {
+			return filter, ErrInvalidInput
+		}
+		assignment, err := s.assignmentRepo.GetByID(ctx, id.String())
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return filter, ErrNotFound
+			}
+			return filter, err
+		}
+		if err := s.checkRecalculationAccess(ctx, principal, assignment.TicketID); err != nil {
+			return filter, err
+		}
+		filter.AssignmentID = &id
+
+	case input.TicketID != nil:
+		id, err := uuid.Parse(*input.TicketID)
+		if err != nil {
+			return filter, ErrInvalidInput
+		}
+		if err := s.checkRecalculationAccess(ctx, principal, id); err != nil {
+			return filter, err
+		}
+		filter.TicketID = &id
+
+	default:
+		if input.DateFrom == nil || input.DateTo == nil {
+			return filter, ErrInvalidInput
+		}
+		from, err := time.Parse(time.RFC3339, *input.DateFrom)
+		if err != nil {
+			return filter, ErrInvalidInput
+		}
+		to, err := time.Parse(time.RFC3339, *input.DateTo)
+		if err != nil {
+			return filter, ErrInvalidInput
+		}
+		if !to.After(from) || to.Sub(from) > maxRecalculationRange {
+			return filter, ErrInvalidInput
+		}
+		filter.EntryFrom = &from
+		filter.EntryTo = &to
+	}
+
+	return filter, nil
+}
+
+func (s *TripService) checkRecalculationAccess(ctx context.Context, principal model.Principal, ticketID uuid.UUID) error {
+	ticket, err := s.ticketRepo.GetByID(ctx, ticketID.String())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if principal.IsKgu() && ticket.CreatedByOrgID != principal.OrgID {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// TripsForVolumeBackfill перебирает автоматически созданные рейсы, въехавшие в период [from, to),
+// загружая их из БД страницами по batchSize (0 - одним запросом)
+func (s *TripService) TripsForVolumeBackfill(ctx context.Context, from, to time.Time, batchSize int) iter.Seq2[model.Trip, error] {
+	return func(yield func(model.Trip, error) bool) {
+		filter := repository.VolumeRecalculationFilter{
+			EntryFrom: &from,
+			EntryTo:   &to,
+			Limit:     batchSize,
+		}
+		for {
+			trips, err := s.tripRepo.ListForVolumeRecalculation(ctx, filter)
+			if err != nil {
+				yield(model.Trip{}, err)
+				return
+			}
+			for _, trip := range trips {
+				if !yield(trip, nil) {
+					return
+				}
+			}
+			if batchSize <= 0 || len(trips) < batchSize {
+				return
+			}
+			last := trips[len(trips)-1]
+			filter.AfterEntryAt = &last.EntryAt
+			filter.AfterID = last.ID
+		}
+	}
+}
+
+// RecalculateTripVolume синхронно пересчитывает объем автоматически созданного рейса по событиям ANPR.
+// При save=false результат только возвращается (пробный прогон).
+func (s *TripService) RecalculateTripVolume(ctx context.Context, trip *model.Trip, save bool) (*VolumeRecalculation, error) {
+	if trip.TicketAssignmentID == nil {
+		return nil, fmt.Errorf("%w: trip has no assignment", ErrInvalidInput)
+	}
+
+	assignment, err := s.assignmentRepo.GetByID(ctx, trip.TicketAssignmentID.String())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	totalVolume, err := s.CalculateVolumeForTrip(ctx, assignment, trip)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VolumeRecalculation{
+		TripID:       trip.ID,
+		AssignmentID: assignment.ID,
+		TicketID:     trip.TicketID,
+		EntryAt:      trip.EntryAt,
+		OldVolumeM3:  trip.TotalVolumeM3,
+		NewVolumeM3:  totalVolume,
+	}
+	if !save {
+		return result, nil
+	}
+
+	calculated := model.VolumeCalculationCalculated
+	trip.TotalVolumeM3 = &totalVolume
+	trip.VolumeCalculationStatus = &calculated
+	trip.VolumeCalculationError = nil
+
+	if err := s.Update(ctx, trip); err != nil {
+		return nil, fmt.Errorf("failed to update trip: %w", err)
+	}
+
+	return result, nil
+}
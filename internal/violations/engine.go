@@ -0,0 +1,125 @@
+package violations
+
+import (
+	"strings"
+	"time"
+
+	"ticket-service/internal/model"
+)
+
+// Severity - серьезность нарушения, определяет итоговый статус рейса при нескольких нарушениях
+type Severity = model.ViolationSeverity
+
+const (
+	SeverityLow    = model.ViolationSeverityLow
+	SeverityMedium = model.ViolationSeverityMedium
+	SeverityHigh   = model.ViolationSeverityHigh
+)
+
+// Thresholds - пороги проверок. Значение 0 отключает соответствующую проверку.
+type Thresholds struct {
+	// MaxTripVolumeM3 - вместимость кузова, больше - OVER_CAPACITY
+	MaxTripVolumeM3 float64
+	// MinTripVolumeM3 - минимальный правдоподобный объем завершенного рейса, меньше - SUSPICIOUS_VOLUME
+	MinTripVolumeM3 float64
+	// MaxExitVolumeM3 - допустимый остаток в кузове на выезде с полигона, больше - SUSPICIOUS_VOLUME
+	MaxExitVolumeM3 float64
+	// ContractVolumeLimitM3 - лимит объема по договору, превышение - OVER_CONTRACT_LIMIT
+	ContractVolumeLimitM3 float64
+	// RequirePolygonAccess - требовать явный доступ подрядчика к полигону (иначе FOREIGN_AREA)
+	RequirePolygonAccess bool
+}
+
+// Input - все данные, необходимые правилам. Заполняется сервисом перед оценкой,
+// правила не ходят в БД сами.
+type Input struct {
+	Trip       *model.Trip
+	Ticket     *model.Ticket
+	Assignment *model.TicketAssignment
+	Thresholds Thresholds
+	// ContractVolumeM3 - объем, уже учтенный по договору без текущего рейса
+	ContractVolumeM3 float64
+	// HasPolygonAccess - есть ли у подрядчика действующий доступ к полигону рейса
+	HasPolygonAccess bool
+	Now              time.Time
+}
+
+// Finding - нарушение, обнаруженное правилом
+type Finding struct {
+	Status   model.TripStatus `json:"status"`
+	Severity Severity         `json:"severity"`
+	Reason   string           `json:"reason"`
+	Rule     string           `json:"rule"`
+}
+
+// Rule - проверка одного вида нарушения
+type Rule interface {
+	Name() string
+	Evaluate(in *Input) *Finding
+}
+
+// Result - итог оценки рейса
+type Result struct {
+	Status   model.TripStatus
+	Reason   *string
+	Findings []Finding
+}
+
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine создает движок с указанными правилами. Порядок правил задает приоритет
+// при одинаковой серьезности нарушений.
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// NewDefaultEngine создает движок со всеми встроенными правилами
+func NewDefaultEngine() *Engine {
+	return NewEngine(DefaultRules()...)
+}
+
+// Evaluate прогоняет рейс через все правила. Статус рейса - нарушение с наибольшей серьезностью,
+// причина - объединение объяснений всех найденных нарушений.
+func (e *Engine) Evaluate(in *Input) Result {
+	if in.Now.IsZero() {
+		in.Now = time.Now()
+	}
+
+	var findings []Finding
+	for _, rule := range e.rules {
+		if finding := rule.Evaluate(in); finding != nil {
+			if finding.Rule == "" {
+				finding.Rule = rule.Name()
+			}
+			findings = append(findings, *finding)
+		}
+	}
+
+	return Summarize(findings)
+}
+
+// Summarize вычисляет итоговый статус по списку нарушений: нарушение с наибольшей серьезностью
+// (при равной - первое по порядку), причина - объединение объяснений всех нарушений.
+func Summarize(findings []Finding) Result {
+	if len(findings) == 0 {
+		return Result{Status: model.TripStatusOK}
+	}
+
+	primary := findings[0]
+	reasons := make([]string, 0, len(findings))
+	for _, f := range findings {
+		if f.Severity.Weight() > primary.Severity.Weight() {
+			primary = f
+		}
+		reasons = append(reasons, f.Reason)
+	}
+
+	reason := strings.Join(reasons, "; ")
+	return Result{
+		Status:   primary.Status,
+		Reason:   &reason,
+		Findings: findings,
+	}
+}
@@ -0,0 +1,225 @@
+package violations
+
+import (
+	"fmt"
+
+	"ticket-service/internal/model"
+	"ticket-service/internal/plate"
+	"ticket-service/internal/volume"
+)
+
+// DefaultRules возвращает встроенные правила в порядке приоритета
+func DefaultRules() []Rule {
+	return []Rule{
+		NoAssignmentRule{},
+		ForeignAreaRule{},
+		ReportedViolationRule{},
+		MismatchPlateRule{},
+		OverCapacityRule{},
+		OverContractLimitRule{},
+		SuspiciousVolumeRule{},
+		NoAreaWorkRule{},
+	}
+}
+
+// MeasuredVolume возвращает принятый объем рейса. Если он еще не рассчитан,
+// используется стратегия учета по умолчанию (см. пакет volume).
+func MeasuredVolume(trip *model.Trip) (float64, bool) {
+	if trip.AcceptedVolumeM3 != nil {
+		return *trip.AcceptedVolumeM3, true
+	}
+	result, ok := volume.Accept(trip, volume.DefaultStrategy)
+	return result.VolumeM3, ok
+}
+
+// NoAssignmentRule - рейс не привязан ни к одному тикету/назначению
+type NoAssignmentRule struct{}
+
+func (NoAssignmentRule) Name() string { return "no_assignment" }
+
+func (NoAssignmentRule) Evaluate(in *Input) *Finding {
+	if in.Trip.TicketID != nil {
+		return nil
+	}
+	return &Finding{
+		Status:   model.TripStatusNoAssignment,
+		Severity: SeverityHigh,
+		Reason:   "рейс не сопоставлен ни с одним активным назначением",
+	}
+}
+
+// ForeignAreaRule - подрядчик выгрузил снег на полигон, к которому у него нет доступа.
+// Проверка включается порогом RequirePolygonAccess.
+type ForeignAreaRule struct{}
+
+func (ForeignAreaRule) Name() string { return "foreign_area" }
+
+func (ForeignAreaRule) Evaluate(in *Input) *Finding {
+	if !in.Thresholds.RequirePolygonAccess || in.Trip.PolygonID == nil || in.Ticket == nil {
+		return nil
+	}
+	if in.HasPolygonAccess {
+		return nil
+	}
+	return &Finding{
+		Status:   model.TripStatusForeignArea,
+		Severity: SeverityHigh,
+		Reason:   fmt.Sprintf("у подрядчика нет доступа к полигону %s", in.Trip.PolygonID),
+	}
+}
+
+// ReportedViolationRule сохраняет нарушение, переданное вышестоящим сервисом
+// (например, ROUTE_VIOLATION от сервиса контроля маршрутов по GPS-треку),
+// которое невозможно проверить по данным этого сервиса
+type ReportedViolationRule struct{}
+
+func (ReportedViolationRule) Name() string { return "reported" }
+
+func (ReportedViolationRule) Evaluate(in *Input) *Finding {
+	if in.Trip.ReportedStatus == nil {
+		return nil
+	}
+	status := *in.Trip.ReportedStatus
+	switch status {
+	case model.TripStatusOK, model.TripStatusNoAssignment:
+		return nil
+	}
+
+	reason := fmt.Sprintf("нарушение %s зафиксировано внешней системой", status)
+	if status == model.TripStatusRouteViolation {
+		reason = "отклонение от маршрута по данным GPS-контроля"
+	}
+	return &Finding{
+		Status:   status,
+		Severity: SeverityHigh,
+		Reason:   reason,
+	}
+}
+
+// MismatchPlateRule - распознанный камерой номер не совпадает с номером машины в назначении.
+// Ошибки распознавания (кириллица/латиница, O/0, B/8, I/1) нарушением не считаются.
+type MismatchPlateRule struct{}
+
+func (MismatchPlateRule) Name() string { return "mismatch_plate" }
+
+func (MismatchPlateRule) Evaluate(in *Input) *Finding {
+	if in.Trip.VehiclePlateNumber == "" || in.Trip.DetectedPlateNumber == "" {
+		return nil
+	}
+	if plate.Matches(in.Trip.VehiclePlateNumber, in.Trip.DetectedPlateNumber) {
+		return nil
+	}
+	similarity := plate.Similarity(in.Trip.VehiclePlateNumber, in.Trip.DetectedPlateNumber)
+
+	expected, _ := plate.Canonical(in.Trip.VehiclePlateNumber)
+	detected, _ := plate.Canonical(in.Trip.DetectedPlateNumber)
+	return &Finding{
+		Status:   model.TripStatusMismatchPlate,
+		Severity: SeverityMedium,
+		Reason:   fmt.Sprintf("распознан номер %s, в назначении %s (сходство %.0f%%)", detected, expected, similarity*100),
+	}
+}
+
+// OverCapacityRule - объем рейса превышает вместимость кузова
+type OverCapacityRule struct{}
+
+func (OverCapacityRule) Name() string { return "over_capacity" }
+
+func (OverCapacityRule) Evaluate(in *Input) *Finding {
+	if in.Thresholds.MaxTripVolumeM3 <= 0 {
+		return nil
+	}
+	volume, ok := MeasuredVolume(in.Trip)
+	if !ok || volume <= in.Thresholds.MaxTripVolumeM3 {
+		return nil
+	}
+	return &Finding{
+		Status:   model.TripStatusOverCapacity,
+		Severity: SeverityHigh,
+		Reason:   fmt.Sprintf("объем %.2f м³ превышает вместимость %.2f м³", volume, in.Thresholds.MaxTripVolumeM3),
+	}
+}
+
+// OverContractLimitRule - рейс выводит суммарный объем по договору за лимит
+type OverContractLimitRule struct{}
+
+func (OverContractLimitRule) Name() string { return "over_contract_limit" }
+
+func (OverContractLimitRule) Evaluate(in *Input) *Finding {
+	if in.Thresholds.ContractVolumeLimitM3 <= 0 || in.Ticket == nil {
+		return nil
+	}
+	volume, ok := MeasuredVolume(in.Trip)
+	if !ok {
+		return nil
+	}
+	total := in.ContractVolumeM3 + volume
+	if total <= in.Thresholds.ContractVolumeLimitM3 {
+		return nil
+	}
+	return &Finding{
+		Status:   model.TripStatusOverContractLimit,
+		Severity: SeverityHigh,
+		Reason:   fmt.Sprintf("объем по договору %.2f м³ превышает лимит %.2f м³", total, in.Thresholds.ContractVolumeLimitM3),
+	}
+}
+
+// SuspiciousVolumeRule - объем завершенного рейса неправдоподобен:
+// слишком мал, кузов не разгружен на выезде или на выезде объем больше, чем на въезде
+type SuspiciousVolumeRule struct{}
+
+func (SuspiciousVolumeRule) Name() string { return "suspicious_volume" }
+
+func (SuspiciousVolumeRule) Evaluate(in *Input) *Finding {
+	trip := in.Trip
+	if trip.DetectedVolumeEntry != nil && trip.DetectedVolumeExit != nil && *trip.DetectedVolumeExit > *trip.DetectedVolumeEntry {
+		return &Finding{
+			Status:   model.TripStatusSuspiciousVolume,
+			Severity: SeverityMedium,
+			Reason: fmt.Sprintf("объем на выезде %.2f м³ больше объема на въезде %.2f м³",
+				*trip.DetectedVolumeExit, *trip.DetectedVolumeEntry),
+		}
+	}
+
+	if in.Thresholds.MaxExitVolumeM3 > 0 && trip.DetectedVolumeExit != nil && *trip.DetectedVolumeExit > in.Thresholds.MaxExitVolumeM3 {
+		return &Finding{
+			Status:   model.TripStatusSuspiciousVolume,
+			Severity: SeverityMedium,
+			Reason:   fmt.Sprintf("кузов не разгружен: на выезде %.2f м³", *trip.DetectedVolumeExit),
+		}
+	}
+
+	// Минимальный объем проверяем только у завершенных рейсов
+	if in.Thresholds.MinTripVolumeM3 > 0 && trip.ExitAt != nil {
+		volume, ok := MeasuredVolume(trip)
+		if ok && volume < in.Thresholds.MinTripVolumeM3 {
+			return &Finding{
+				Status:   model.TripStatusSuspiciousVolume,
+				Severity: SeverityMedium,
+				Reason:   fmt.Sprintf("объем %.2f м³ меньше минимального %.2f м³", volume, in.Thresholds.MinTripVolumeM3),
+			}
+		}
+	}
+
+	return nil
+}
+
+// NoAreaWorkRule - машина приехала на полигон, но водитель не отметил начало работы на участке
+type NoAreaWorkRule struct{}
+
+func (NoAreaWorkRule) Name() string { return "no_area_work" }
+
+func (NoAreaWorkRule) Evaluate(in *Input) *Finding {
+	if in.Assignment == nil {
+		return nil
+	}
+	startedAt := in.Assignment.TripStartedAt
+	if startedAt != nil && !in.Trip.EntryAt.Before(*startedAt) {
+		return nil
+	}
+	return &Finding{
+		Status:   model.TripStatusNoAreaWork,
+		Severity: SeverityMedium,
+		Reason:   "въезд на полигон без отметки о начале работы на участке",
+	}
+}
@@ -0,0 +1,84 @@
+// Package volume - единый учет принятого объема снега по рейсу.
+// Объем может быть измерен камерой объема на въезде/выезде или рассчитан по событиям ANPR;
+// стратегия, выбранная для договора, определяет, какое измерение считается принятым.
+package volume
+
+import "ticket-service/internal/model"
+
+// DefaultStrategy - стратегия, если она не задана ни в конфигурации, ни для договора
+const DefaultStrategy = model.VolumeStrategyNetEntryExit
+
+// Result - принятый объем и стратегия, по которой он получен
+type Result struct {
+	VolumeM3 float64
+	Strategy model.VolumeStrategy
+}
+
+// fallbacks - если для выбранной стратегии у рейса нет данных, используется следующая по списку.
+// Так рейсы от камер и рейсы, созданные по отметке водителя, учитываются при любой стратегии договора.
+var fallbacks = map[model.VolumeStrategy][]model.VolumeStrategy{
+	model.VolumeStrategyNetEntryExit: {model.VolumeStrategyNetEntryExit, model.VolumeStrategyANPREntrySum},
+	model.VolumeStrategyANPREntrySum: {model.VolumeStrategyANPREntrySum, model.VolumeStrategyNetEntryExit},
+	model.VolumeStrategyMaxBodyFill:  {model.VolumeStrategyMaxBodyFill},
+}
+
+// Accept рассчитывает принятый объем рейса по стратегии. Возвращает false, если у рейса
+// нет ни одного измерения, подходящего для стратегии.
+func Accept(trip *model.Trip, strategy model.VolumeStrategy) (Result, bool) {
+	if !strategy.IsValid() {
+		strategy = DefaultStrategy
+	}
+
+	for _, candidate := range fallbacks[strategy] {
+		if volume, ok := measure(trip, candidate); ok {
+			return Result{VolumeM3: volume, Strategy: candidate}, true
+		}
+	}
+	return Result{}, false
+}
+
+// Apply записывает принятый объем и стратегию в рейс
+func Apply(trip *model.Trip, strategy model.VolumeStrategy) {
+	result, ok := Accept(trip, strategy)
+	if !ok {
+		trip.AcceptedVolumeM3 = nil
+		trip.VolumeStrategy = nil
+		return
+	}
+	trip.AcceptedVolumeM3 = &result.VolumeM3
+	trip.VolumeStrategy = &result.Strategy
+}
+
+func measure(trip *model.Trip, strategy model.VolumeStrategy) (float64, bool) {
+	switch strategy {
+	case model.VolumeStrategyNetEntryExit:
+		if trip.DetectedVolumeEntry == nil {
+			return 0, false
+		}
+		volume := *trip.DetectedVolumeEntry
+		if trip.DetectedVolumeExit != nil {
+			volume -= *trip.DetectedVolumeExit
+		}
+		return max(volume, 0), true
+
+	case model.VolumeStrategyANPREntrySum:
+		if trip.TotalVolumeM3 == nil {
+			return 0, false
+		}
+		return max(*trip.TotalVolumeM3, 0), true
+
+	case model.VolumeStrategyMaxBodyFill:
+		var (
+			volume float64
+			found  bool
+		)
+		for _, v := range []*float64{trip.DetectedVolumeEntry, trip.TotalVolumeM3} {
+			if v != nil {
+				volume = max(volume, *v)
+				found = true
+			}
+		}
+		return volume, found
+	}
+	return 0, false
+}
@@ -0,0 +1,164 @@
+package volume
+
+import (
+	"testing"
+
+	"ticket-service/internal/model"
+)
+
+func TestAccept(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    *float64
+		exit     *float64
+		total    *float64
+		strategy model.VolumeStrategy
+		want     float64
+		// wantStrategy - пусто, если объем не принят
+		wantStrategy model.VolumeStrategy
+	}{
+		{
+			name:         "net entry minus exit",
+			entry:        ptr(18.5),
+			exit:         ptr(2.5),
+			strategy:     model.VolumeStrategyNetEntryExit,
+			want:         16,
+			wantStrategy: model.VolumeStrategyNetEntryExit,
+		},
+		{
+			name:         "net without exit",
+			entry:        ptr(18.5),
+			strategy:     model.VolumeStrategyNetEntryExit,
+			want:         18.5,
+			wantStrategy: model.VolumeStrategyNetEntryExit,
+		},
+		{
+			// Камера на выезде намерила больше, чем на въезде
+			name:         "net below zero clamps to zero",
+			entry:        ptr(3),
+			exit:         ptr(4.5),
+			strategy:     model.VolumeStrategyNetEntryExit,
+			want:         0,
+			wantStrategy: model.VolumeStrategyNetEntryExit,
+		},
+		{
+			name:         "net falls back to ANPR sum",
+			total:        ptr(43.6),
+			strategy:     model.VolumeStrategyNetEntryExit,
+			want:         43.6,
+			wantStrategy: model.VolumeStrategyANPREntrySum,
+		},
+		{
+			name:         "ANPR sum",
+			entry:        ptr(18.5),
+			total:        ptr(43.6),
+			strategy:     model.VolumeStrategyANPREntrySum,
+			want:         43.6,
+			wantStrategy: model.VolumeStrategyANPREntrySum,
+		},
+		{
+			name:         "ANPR sum below zero clamps to zero",
+			total:        ptr(-1),
+			strategy:     model.VolumeStrategyANPREntrySum,
+			want:         0,
+			wantStrategy: model.VolumeStrategyANPREntrySum,
+		},
+		{
+			name:         "ANPR sum falls back to net",
+			entry:        ptr(18.5),
+			exit:         ptr(2.5),
+			strategy:     model.VolumeStrategyANPREntrySum,
+			want:         16,
+			wantStrategy: model.VolumeStrategyNetEntryExit,
+		},
+		{
+			name:         "max body fill takes larger measurement",
+			entry:        ptr(18.5),
+			total:        ptr(14.5),
+			strategy:     model.VolumeStrategyMaxBodyFill,
+			want:         18.5,
+			wantStrategy: model.VolumeStrategyMaxBodyFill,
+		},
+		{
+			name:         "max body fill with only entry",
+			entry:        ptr(18.5),
+			exit:         ptr(2.5),
+			strategy:     model.VolumeStrategyMaxBodyFill,
+			want:         18.5,
+			wantStrategy: model.VolumeStrategyMaxBodyFill,
+		},
+		{
+			name:         "max body fill with only ANPR sum",
+			total:        ptr(14.5),
+			strategy:     model.VolumeStrategyMaxBodyFill,
+			want:         14.5,
+			wantStrategy: model.VolumeStrategyMaxBodyFill,
+		},
+		{
+			// Для MAX_BODY_FILL запасной стратегии нет
+			name:     "max body fill without data",
+			exit:     ptr(2.5),
+			strategy: model.VolumeStrategyMaxBodyFill,
+		},
+		{
+			name:         "unknown strategy uses default",
+			entry:        ptr(18.5),
+			exit:         ptr(2.5),
+			total:        ptr(43.6),
+			strategy:     model.VolumeStrategy("UNKNOWN"),
+			want:         16,
+			wantStrategy: DefaultStrategy,
+		},
+		{
+			name:     "no measurements",
+			strategy: model.VolumeStrategyNetEntryExit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trip := &model.Trip{
+				DetectedVolumeEntry: tt.entry,
+				DetectedVolumeExit:  tt.exit,
+				TotalVolumeM3:       tt.total,
+			}
+
+			result, ok := Accept(trip, tt.strategy)
+			if ok != (tt.wantStrategy != "") {
+				t.Fatalf("got accepted %v, want %v", ok, tt.wantStrategy != "")
+			}
+			if result.VolumeM3 != tt.want || result.Strategy != tt.wantStrategy {
+				t.Errorf("got %v by %s, want %v by %s", result.VolumeM3, result.Strategy, tt.want, tt.wantStrategy)
+			}
+
+			// Apply записывает в рейс то же, что возвращает Accept
+			Apply(trip, tt.strategy)
+			if !ok {
+				if trip.AcceptedVolumeM3 != nil || trip.VolumeStrategy != nil {
+					t.Errorf("got accepted volume %v by %v, want none", trip.AcceptedVolumeM3, trip.VolumeStrategy)
+				}
+				return
+			}
+			if trip.AcceptedVolumeM3 == nil || *trip.AcceptedVolumeM3 != tt.want {
+				t.Errorf("got accepted volume %v, want %v", trip.AcceptedVolumeM3, tt.want)
+			}
+			if trip.VolumeStrategy == nil || *trip.VolumeStrategy != tt.wantStrategy {
+				t.Errorf("got strategy %v, want %s", trip.VolumeStrategy, tt.wantStrategy)
+			}
+		})
+	}
+}
+
+// Пересчет без данных сбрасывает ранее принятый объем
+func TestApplyClearsAcceptedVolume(t *testing.T) {
+	strategy := model.VolumeStrategyANPREntrySum
+	trip := &model.Trip{AcceptedVolumeM3: ptr(10.0), VolumeStrategy: &strategy}
+
+	Apply(trip, strategy)
+	if trip.AcceptedVolumeM3 != nil || trip.VolumeStrategy != nil {
+		t.Errorf("got accepted volume %v by %v, want none", trip.AcceptedVolumeM3, trip.VolumeStrategy)
+	}
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}